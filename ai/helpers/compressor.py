@@ -3,14 +3,14 @@ import sys
 from pathlib import Path
 
 from helpers.generator import generate_files
-from helpers.files import download_file, CompressionResult, discover, FileData
+from helpers.files import generate_corpus, CompressionResult, discover, FileData
 
 # Add the engine directory to the path of importable directories
 engine_path = os.getcwd() + "/engine"
 sys.path.append(engine_path)
 from engine import engine
 
-def benchmark(training_set, downloads, algorithms, generate=False, download=False, fresh=False, delete_at_end=False):
+def benchmark(training_set, corpus_settings, algorithms, generate=False, corpus=False, fresh=False, delete_at_end=False):
     Path("files").mkdir(parents=True, exist_ok=True)
     os.chdir("files")
 
@@ -24,11 +24,8 @@ def benchmark(training_set, downloads, algorithms, generate=False, download=Fals
     if generate:
         filenames += generate_files(training_set)
 
-    if download:
-        for to_download in downloads:
-            url = to_download[0]
-            unzip = to_download[1]
-            filenames += download_file(url, unzip=unzip)
+    if corpus:
+        filenames += generate_corpus(**corpus_settings)
 
     files = []
     for filename in filenames:
@@ -1,8 +1,7 @@
-import zipfile
 import os
 import math
+import subprocess
 
-import requests
 import magic
 
 import pandas as pd
@@ -80,23 +79,8 @@ class CompressionResult():
 def discover(path="."):
     return [f for f in os.listdir(path) if os.path.isfile(os.path.join(path, f))]
 
-def download_file(url, unzip=False):
-    files = []
-    local_filename = url.split('/')[-1]
-    with requests.get(url, stream=True) as r:
-        r.raise_for_status()
-        with open(local_filename, 'wb') as f:
-            for chunk in r.iter_content(chunk_size=8192): 
-                f.write(chunk)
-        if not unzip:
-            files.append(local_filename)
-    
-    if unzip:
-        with zipfile.ZipFile(local_filename, 'r') as zip_ref:
-            for name in zip_ref.namelist():
-                zip_ref.extract(name, ".")
-                files.append(name)
-        os.remove(local_filename)
-    
-
-    return files
\ No newline at end of file
+def generate_corpus(size=262144, seed=1):
+    """ Writes the synthetic corpus of `raisin corpus generate` to the current directory and returns its filenames. """
+    output = subprocess.run(["raisin", "corpus", "generate", "-dir=.", f"-size={size}", f"-seed={seed}"],
+                            check=True, capture_output=True, text=True).stdout
+    return [os.path.basename(path) for path in output.split()]
@@ -11,19 +11,17 @@ training_set = {
     (generate_jpg, 5, generate_jpg_params),
 }
 
-downloads = {
-    ("http://corpus.canterbury.ac.nz/resources/large.zip", True),
-    ("http://corpus.canterbury.ac.nz/resources/cantrbry.zip", True),
-    ("http://corpus.canterbury.ac.nz/resources/artificl.zip", True),
-    ("http://corpus.canterbury.ac.nz/resources/misc.zip", True),
-    ("http://corpus.canterbury.ac.nz/resources/calgary.zip", True),
+# Passed to `raisin corpus generate`, the same size and seed always give the same files
+corpus = {
+    "size": 1048576,
+    "seed": 1,
 }
 
 algorithms = ["arithmetic", "lzss", "flate", "gzip", "lzw", "zlib"]
 
 benchmark_params = {
     "generate": False, 
-    "download": True, 
+    "corpus": True, 
     "fresh": False, 
     "delete_at_end": False,
 }
@@ -34,7 +32,7 @@ def main(load_data=False, save_data=False, json_file="data.json"):
             data = json.load(f)
     else:
         from helpers.compressor import benchmark
-        data = benchmark(training_set, downloads, algorithms, **benchmark_params)
+        data = benchmark(training_set, corpus, algorithms, **benchmark_params)
 
     if save_data:
         with open(json_file, 'w') as f:
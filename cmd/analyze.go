@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	engine "github.com/go-compression/raisin/engine"
+	"os"
+	"strings"
+)
+
+// analyzeCommand handles "raisin analyze", printing the entropy and match statistics of files before compressing them.
+func analyzeCommand(args []string) {
+	defaults := engine.NewAnalyzeSettings()
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	order := flags.Int("order", defaults.MaxOrder, fmt.Sprintf("Highest order of conditional entropy to compute, at most %d", engine.MaxAnalyzeOrder))
+	window := flags.Int("window", defaults.WindowSize, fmt.Sprintf("Size in bytes of the blocks the windowed entropy is computed over, 0 disables it"))
+	matchWindow := flags.Int("match-window", defaults.MatchWindow, fmt.Sprintf("How many bytes back to search for matches"))
+	format := flags.String("format", "text", fmt.Sprintf("Output format, choices include: \n\ttext, json"))
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		errorWithMsg("Usage: raisin analyze [-order=3] [-window=4096] [-match-window=32768] [-format=text] file[,file...]\n")
+	}
+	if *format != "text" && *format != "json" {
+		errorWithMsg(fmt.Sprintf("'%s' is not a valid format, possible formats include: \n\ttext, json\n", *format))
+	}
+	if *order < 0 || *order > engine.MaxAnalyzeOrder {
+		errorWithMsg(fmt.Sprintf("Please provide an order between 0 and %d\n", engine.MaxAnalyzeOrder))
+	}
+	if *window < 0 || *matchWindow < 0 {
+		errorWithMsg("Please provide a non-negative window and match window\n")
+	}
+
+	settings := engine.NewAnalyzeSettings()
+	settings.MaxOrder = *order
+	settings.WindowSize = *window
+	settings.MatchWindow = *matchWindow
+
+	for i, file := range strings.Split(flags.Arg(0), ",") {
+		analysis, err := engine.AnalyzeFile(strings.TrimSpace(file), settings)
+		if err != nil {
+			errorWithMsg(fmt.Sprintf("Could not analyze %s: %s\n", file, err))
+		}
+		if i > 0 && *format == "text" {
+			fmt.Fprintln(os.Stdout)
+		}
+		err = engine.WriteAnalysis(os.Stdout, analysis, *format)
+		check(err)
+	}
+}
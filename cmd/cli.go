@@ -1,18 +1,22 @@
 package cmd
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	engine "github.com/go-compression/raisin/engine"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 	// "github.com/pkg/profile" // Profiling package
 )
 
 // Commands represents all possible commands that can be used durinv CLI invocation
-var Commands = [...]string{"compress", "decompress", "benchmark", "help"}
+var Commands = [...]string{"compress", "decompress", "benchmark", "test", "info", "corpus", "analyze", "dict", "help"}
 
 // MainBehavior represents the main behavior function of the command line. This includes processing of flags and invoking of compression algorithms.
 func MainBehavior() []engine.Result {
@@ -22,11 +26,29 @@ func MainBehavior() []engine.Result {
 
 	application := os.Args[0]
 
+	// Subcommands without a leading dash have their own flag sets
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "corpus":
+			corpusCommand(os.Args[2:])
+			return nil
+		case "analyze":
+			analyzeCommand(os.Args[2:])
+			return nil
+		case "dict":
+			dictCommand(os.Args[2:])
+			return nil
+		}
+	}
+
 	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 
 	compressCmd := flag.Bool("compress", false, "Compress file")
 	decompressCmd := flag.Bool("decompress", false, "Decompress file")
 	benchmarkCmd := flag.Bool("benchmark", false, "Benchmark file")
+	testCmd := flag.Bool("test", false, "Verify compressed file against its checksum")
+	infoCmd := flag.Bool("info", false, "Inspect compressed file")
+	workerCmd := flag.Bool("benchmark-worker", false, "Run a single isolated benchmark (used internally by -benchmark -isolate)")
 	helpCmd := flag.Bool("help", false, "Help")
 
 	commandArgs := make([]string, len(os.Args))
@@ -35,7 +57,7 @@ func MainBehavior() []engine.Result {
 		commandArgs = append(commandArgs[1:2], "")
 	}
 	if commandArgs[0] == "-compress" || commandArgs[0] == "-decompress" ||
-		commandArgs[0] == "-benchmark" || commandArgs[0] == "-help" {
+		commandArgs[0] == "-benchmark" || commandArgs[0] == "-test" || commandArgs[0] == "-info" || commandArgs[0] == "-benchmark-worker" || commandArgs[0] == "-help" {
 		flag.CommandLine.Parse(commandArgs)
 	}
 
@@ -44,7 +66,7 @@ func MainBehavior() []engine.Result {
 		generateHTML = flag.Bool("generate", false, "Compile benchmark results as an html file")
 	}
 
-	commandsSelected := boolsTrue([]bool{*compressCmd, *decompressCmd, *benchmarkCmd, *helpCmd})
+	commandsSelected := boolsTrue([]bool{*compressCmd, *decompressCmd, *benchmarkCmd, *testCmd, *infoCmd, *workerCmd, *helpCmd})
 
 	if commandsSelected > 1 {
 		errorWithMsg(fmt.Sprintf(
@@ -80,8 +102,12 @@ func MainBehavior() []engine.Result {
 	if file == "" && !strings.Contains(file, ",") {
 		if *compressCmd {
 			errorWithMsg("Please provide a file to be compressed\n")
-		} else if *benchmarkCmd {
+		} else if *benchmarkCmd || *workerCmd {
 			errorWithMsg("Please provide a file to be benchmarked\n")
+		} else if *testCmd {
+			errorWithMsg("Please provide a file to be tested\n")
+		} else if *infoCmd {
+			errorWithMsg("Please provide a file to be inspected\n")
 		} else {
 			errorWithMsg("Please provide a file to be decompressed\n")
 		}
@@ -112,6 +138,12 @@ func MainBehavior() []engine.Result {
 		}
 
 		deleteAfter := flag.Bool("delete", false, fmt.Sprintf("Delete file after compression"))
+		checksum := flag.String("checksum", "crc32", fmt.Sprintf("Checksum stored to verify decompression, choices include: \n\tnone, crc32, xxhash"))
+		autoDefaults := engine.NewAutoSettings()
+		autoDepth := flag.Int("auto-depth", autoDefaults.MaxDepth, fmt.Sprintf("Maximum number of layers tried with -algorithm=auto"))
+		autoSample := flag.Int("auto-sample", autoDefaults.SampleSize, fmt.Sprintf("Number of bytes of the file each chain is tried on with -algorithm=auto, 0 uses the whole file"))
+		autoBudget := flag.Duration("auto-budget", autoDefaults.TimeBudget, fmt.Sprintf("Time budget for choosing the algorithms with -algorithm=auto"))
+		dictionary := flag.String("dictionary", "", fmt.Sprintf("Preset dictionary file to compress with, used by: \n\t%s", strings.Join(dictionaryAlgorithms(), ", ")))
 
 		flag.Parse()
 
@@ -120,10 +152,38 @@ func MainBehavior() []engine.Result {
 			algorithms[i] = strings.TrimSpace(algorithms[i])
 		}
 
+		settings := engine.NewFileSettings()
+		checksumType, ok := engine.Checksums[*checksum]
+		if !ok {
+			errorWithMsg(fmt.Sprintf("'%s' is not a valid checksum, possible checksums include: \n\tnone, crc32, xxhash\n", *checksum))
+		}
+		settings.Checksum = checksumType
+		settings.Auto.MaxDepth = *autoDepth
+		settings.Auto.SampleSize = *autoSample
+		settings.Auto.TimeBudget = *autoBudget
+		if *autoDepth < 1 || *autoSample < 0 || *autoBudget < 0 {
+			errorWithMsg("Please provide a positive auto depth and a non-negative auto sample size and budget\n")
+		}
+		for _, algorithm := range algorithms {
+			if engine.IsReadOnly(algorithm) {
+				errorWithMsg(fmt.Sprintf("'%s' can only decompress, it can't be used to compress files\n", algorithm))
+			}
+		}
+		if *dictionary != "" {
+			supported := engine.IsAuto(algorithms)
+			for _, algorithm := range algorithms {
+				supported = supported || engine.SupportsDictionary(algorithm)
+			}
+			if !supported {
+				errorWithMsg(fmt.Sprintf("None of the algorithms can use a dictionary, algorithms that can include: \n\t%s\n", strings.Join(dictionaryAlgorithms(), ", ")))
+			}
+			settings.Dictionary = loadDictionaries(*dictionary)[0]
+		}
+
 		if len(files) > 1 {
-			engine.CompressFiles(algorithms, files, "."+*outputExtension)
+			engine.CompressFiles(algorithms, files, "."+*outputExtension, settings)
 		} else {
-			engine.CompressFile(algorithms, file, *output)
+			engine.CompressFile(algorithms, file, *output, settings)
 		}
 
 		if *deleteAfter {
@@ -148,6 +208,8 @@ func MainBehavior() []engine.Result {
 		}
 
 		deleteAfter := flag.Bool("delete", true, fmt.Sprintf("Delete file after compression"))
+		maxSize := flag.Int("max-size", engine.DefaultMaxDecompressedSize, fmt.Sprintf("Most bytes a file may decompress to before giving up, 0 for no limit"))
+		dictionary := flag.String("dictionary", "", fmt.Sprintf("Preset dictionary files the files may have been compressed with, separated by commas"))
 
 		flag.Parse()
 
@@ -156,18 +218,128 @@ func MainBehavior() []engine.Result {
 			algorithms[i] = strings.TrimSpace(algorithms[i])
 		}
 
+		if *maxSize < 0 {
+			errorWithMsg("Please provide a non-negative max size\n")
+		}
+		settings := engine.NewDecompressSettings()
+		settings.MaxSize = *maxSize
+		if *dictionary != "" {
+			settings.Dictionaries = loadDictionaries(*dictionary)
+		}
+
+		failed := false
 		if len(files) > 1 {
-			engine.DecompressFiles(algorithms, files, "."+*outputExtension)
-		} else {
-			engine.DecompressFile(algorithms, file, *output)
+			for _, filename := range files {
+				if err := engine.DecompressFiles(algorithms, []string{filename}, "."+*outputExtension, settings); err != nil {
+					fmt.Printf("%s: FAILED (%s)\n", filename, err)
+					failed = true
+				}
+			}
+		} else if _, err := engine.DecompressFile(algorithms, file, *output, settings); err != nil {
+			fmt.Printf("%s: FAILED (%s)\n", file, err)
+			failed = true
+		}
+		if failed {
+			os.Exit(1)
 		}
 
 		if *deleteAfter {
 			deleteFiles(files)
 		}
+	} else if *testCmd {
+		algorithm := flag.String("algorithm", "lzss,arithmetic",
+			fmt.Sprintf("Which algorithm(s) to use, choices include: \n\t%s", strings.Join(engine.Engines[:], ", ")))
+
+		maxSize := flag.Int("max-size", engine.DefaultMaxDecompressedSize, fmt.Sprintf("Most bytes a file may decompress to before giving up, 0 for no limit"))
+		dictionary := flag.String("dictionary", "", fmt.Sprintf("Preset dictionary files the files may have been compressed with, separated by commas"))
+
+		flag.Parse()
+
+		algorithms := strings.Split(*algorithm, ",")
+		for i := range algorithms {
+			algorithms[i] = strings.TrimSpace(algorithms[i])
+		}
+
+		if *maxSize < 0 {
+			errorWithMsg("Please provide a non-negative max size\n")
+		}
+		settings := engine.NewDecompressSettings()
+		settings.MaxSize = *maxSize
+		if *dictionary != "" {
+			settings.Dictionaries = loadDictionaries(*dictionary)
+		}
+
+		failed := false
+		for _, filename := range strings.Split(file, ",") {
+			filename = strings.TrimSpace(filename)
+			if err := engine.TestFile(algorithms, filename, settings); err != nil {
+				fmt.Printf("%s: FAILED (%s)\n", filename, err)
+				failed = true
+			} else {
+				fmt.Printf("%s: OK\n", filename)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	} else if *infoCmd {
+		algorithm := flag.String("algorithm", "lzss,arithmetic",
+			fmt.Sprintf("Which algorithm(s) to assume for files that don't record them, choices include: \n\t%s", strings.Join(engine.Engines[:], ", ")))
+		format := flag.String("format", "text", fmt.Sprintf("Output format, choices include: \n\ttext, json"))
+		dictionary := flag.String("dictionary", "", fmt.Sprintf("Preset dictionary files the files may have been compressed with, separated by commas"))
+
+		flag.Parse()
+
+		algorithms := strings.Split(*algorithm, ",")
+		for i := range algorithms {
+			algorithms[i] = strings.TrimSpace(algorithms[i])
+		}
+		var dictionaries [][]byte
+		if *dictionary != "" {
+			dictionaries = loadDictionaries(*dictionary)
+		}
+
+		for _, filename := range strings.Split(file, ",") {
+			info, err := engine.InspectFile(algorithms, strings.TrimSpace(filename), dictionaries...)
+			if err != nil {
+				errorWithMsg(fmt.Sprintf("Could not inspect %s: %s\n", filename, err))
+			}
+			err = engine.WriteInfo(os.Stdout, info, *format)
+			if err != nil {
+				errorWithMsg(fmt.Sprintf("%s\n", err))
+			}
+		}
+	} else if *workerCmd {
+		algorithm := flag.String("algorithm", "lzss,arithmetic", fmt.Sprintf("Algorithm chain to benchmark"))
+		runs := flag.Int("runs", 1, fmt.Sprintf("Number of timed runs"))
+		warmup := flag.Int("warmup", 0, fmt.Sprintf("Number of untimed warm-up runs"))
+		memory := flag.Bool("memory", true, fmt.Sprintf("Measure memory usage"))
+		output := flag.String("out", "", fmt.Sprintf("File to write the json result to"))
+
+		flag.Parse()
+
+		settings := engine.NewSuiteSettings()
+		settings.Runs = *runs
+		settings.WarmupRuns = *warmup
+		settings.MeasureMemory = *memory
+		benchmarkWorker(strings.Split(*algorithm, ","), file, settings, *output)
 	} else if *benchmarkCmd {
 		algorithm := flag.String("algorithm", "lzss,arithmetic,huffman,[lzss,arithmetic],gzip",
 			fmt.Sprintf("Which algorithm(s) to use, choices include: \n\t%s", strings.Join(engine.Engines[:], ", ")))
+		format := flag.String("format", "table",
+			fmt.Sprintf("Output format of the results, choices include: \n\t%s", strings.Join(engine.Formats[:], ", ")))
+		output := flag.String("out", "", fmt.Sprintf("File to write json, csv or markdown results to (defaults to stdout)"))
+		runs := flag.Int("runs", 1, fmt.Sprintf("Number of timed compression and decompression runs per algorithm"))
+		warmup := flag.Int("warmup", 0, fmt.Sprintf("Number of untimed warm-up runs per algorithm"))
+		memory := flag.Bool("memory", true, fmt.Sprintf("Measure peak heap and allocated bytes of each phase in an extra untimed run"))
+		isolate := flag.Bool("isolate", false, fmt.Sprintf("Run every algorithm in its own process for accurate memory and peak RSS measurements"))
+		timeout := flag.Duration("timeout", time.Minute, fmt.Sprintf("Abort an algorithm that takes longer than this on a file, 0 disables the timeout"))
+		concurrency := flag.Int("concurrency", 0, fmt.Sprintf("Maximum number of algorithms benchmarked at once, 0 runs them all at once"))
+		baseline := flag.String("baseline", "", fmt.Sprintf("Baseline file to compare the results against, exits with status 1 on regressions"))
+		saveBaseline := flag.String("save-baseline", "", fmt.Sprintf("File to save the results to as a baseline for later runs"))
+		threshold := flag.Float64("threshold", 10, fmt.Sprintf("Percentage the ratio or speed of an algorithm may worsen by before it counts as a regression"))
+		htmlTemplate := flag.String("template", "", fmt.Sprintf("Html template used with -generate instead of the built in one"))
+		htmlOutput := flag.String("html-out", "index.html", fmt.Sprintf("File the html report is written to with -generate"))
 
 		flag.Parse()
 
@@ -184,11 +356,91 @@ func MainBehavior() []engine.Result {
 			files[i] = strings.TrimSpace(files[i])
 		}
 
-		output, results := engine.BenchmarkSuite(files, algorithms, *generateHTML)
+		settings := engine.NewBenchmarkSettings()
+		settings.GenerateHTML = *generateHTML
+		settings.Format = *format
+		settings.Runs = *runs
+		settings.WarmupRuns = *warmup
+		settings.MeasureMemory = *memory
+		settings.Timeout = *timeout
+		settings.MaxConcurrency = *concurrency
+		if *isolate {
+			settings.Runner = isolatedBenchmarkFile
+		}
+		if *runs < 1 || *warmup < 0 {
+			errorWithMsg("Please provide at least one run and a non-negative number of warm-up runs\n")
+		}
+		if *timeout < 0 || *concurrency < 0 {
+			errorWithMsg("Please provide a non-negative timeout and concurrency\n")
+		}
+		if *threshold < 0 {
+			errorWithMsg("Please provide a non-negative regression threshold\n")
+		}
+		if *htmlTemplate != "" {
+			tmpl, err := engine.LoadTemplate(*htmlTemplate)
+			if err != nil {
+				errorWithMsg(fmt.Sprintf("Could not load template: %s\n", err))
+			}
+			settings.Template = tmpl
+		}
+		var baselineResults []engine.Result
+		if *baseline != "" {
+			var err error
+			baselineResults, err = engine.LoadBaseline(*baseline)
+			check(err)
+		}
+		if !stringInSlice(*format, engine.Formats[:]) {
+			errorWithMsg(fmt.Sprintf("'%s' is not a valid format, possible formats include: \n\t%s\n", *format, strings.Join(engine.Formats[:], ", ")))
+		}
+		if *format != "table" && *output == "" {
+			// Keep stdout clean for the machine-readable results
+			settings.Output = os.Stderr
+		}
+
+		// Cancel the running benchmarks on the first interrupt, a second one exits as usual
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		go func() {
+			if _, ok := <-interrupt; ok {
+				fmt.Fprintln(os.Stderr, "Interrupted, cancelling benchmarks")
+				signal.Stop(interrupt)
+				cancel()
+			}
+		}()
+		defer signal.Stop(interrupt)
+
+		html, results := engine.BenchmarkSuiteContext(ctx, files, algorithms, settings)
 		if *generateHTML {
-			err := ioutil.WriteFile("index.html", []byte(output), 0644)
+			err := ioutil.WriteFile(*htmlOutput, []byte(html), 0644)
+			check(err)
+			fmt.Fprintln(settings.Output, "Wrote table to", *htmlOutput)
+		}
+		if *format != "table" {
+			writer := os.Stdout
+			if *output != "" {
+				f, err := os.Create(*output)
+				check(err)
+				defer f.Close()
+				writer = f
+			}
+			err := engine.WriteResults(writer, results, *format)
 			check(err)
-			fmt.Println("Wrote table to index.html")
+		}
+		if *saveBaseline != "" {
+			err := engine.SaveBaseline(*saveBaseline, results)
+			check(err)
+			fmt.Fprintln(settings.Output, "Saved baseline to", *saveBaseline)
+		}
+		if *baseline != "" {
+			comparisons := engine.CompareResults(baselineResults, results, *threshold)
+			fmt.Fprintln(settings.Output, "Compared to baseline", *baseline)
+			engine.WriteComparison(settings.Output, comparisons)
+			if engine.Regressed(comparisons) {
+				fmt.Fprintf(os.Stderr, "Regressions found compared to %s\n", *baseline)
+				os.Exit(1)
+			}
 		}
 		return results
 	} else {
@@ -230,6 +482,40 @@ func parseAlgorithms(algorithmString string) (algorithms [][]string) {
 	return algorithms
 }
 
+// loadDictionaries reads the comma-separated dictionary files in paths, exiting if any can't be read
+func loadDictionaries(paths string) [][]byte {
+	var files []string
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			files = append(files, path)
+		}
+	}
+	dictionaries, err := engine.LoadDictionaries(files)
+	if err != nil {
+		errorWithMsg(fmt.Sprintf("Couldn't read dictionary: %s\n", err))
+	}
+	if len(dictionaries) == 0 {
+		errorWithMsg("Please provide a dictionary file\n")
+	}
+	return dictionaries
+}
+
+// dictionaryAlgorithms returns the algorithms that can use a preset dictionary in alphabetical order
+func dictionaryAlgorithms() []string {
+	algorithms := append([]string{}, engine.DictionaryAlgorithms...)
+	sort.Strings(algorithms)
+	return algorithms
+}
+
+func stringInSlice(a string, list []string) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
+
 func deleteFiles(files []string) {
 	for _, file := range files {
 		err := os.Remove(file)
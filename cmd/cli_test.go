@@ -41,6 +41,12 @@ func testContents(t *testing.T, contents []byte, path string) {
 
 	for _, algorithm := range algorithms {
 		os.Args = []string{"raisin", "-algorithm=" + algorithm, path}
+		if !stringInSlice(algorithm, losslessAlgorithms) {
+			// Lossy algorithms can only be compressed, even without a checksum the container's size check fails on decompression
+			os.Args = append(os.Args[:1], append([]string{"-checksum=none"}, os.Args[1:]...)...)
+			MainBehavior()
+			continue
+		}
 		MainBehavior()
 
 		os.Args = []string{"raisin", "-decompress", "-algorithm=" + algorithm, "-out=out.decompressed", path + ".rsn"}
@@ -57,6 +63,14 @@ func testContents(t *testing.T, contents []byte, path string) {
 		err = os.Remove("out.decompressed")
 		check(err)
 	}
+
+	for _, algorithm := range losslessAlgorithms {
+		os.Args = []string{"raisin", "-algorithm=" + algorithm, "-checksum=xxhash", path}
+		MainBehavior()
+
+		os.Args = []string{"raisin", "-test", "-algorithm=" + algorithm, path + ".rsn"}
+		MainBehavior()
+	}
 }
 
 func BenchmarkMainBehavior(b *testing.B) {
@@ -81,6 +95,9 @@ func BenchmarkMainBehavior(b *testing.B) {
 
 		for _, algorithm := range algorithms {
 			os.Args = []string{"raisin", "-compress", "-algorithm=" + algorithm, path}
+			if !stringInSlice(algorithm, losslessAlgorithms) {
+				os.Args = []string{"raisin", "-compress", "-algorithm=" + algorithm, "-checksum=none", path}
+			}
 			MainBehavior()
 
 			os.Args = []string{"raisin", "-decompress", "-algorithm=" + algorithm, "-out=out.decompressed", path + ".rsn"}
@@ -103,15 +120,6 @@ func BenchmarkMainBehavior(b *testing.B) {
 	// os.Stderr = realErr
 }
 
-func stringInSlice(a string, list []string) bool {
-	for _, b := range list {
-		if b == a {
-			return true
-		}
-	}
-	return false
-}
-
 const samIAm = `"GREEN EGGS AND HAM" (by Doctor Seuss) 
 
 I AM SAM. I AM SAM. SAM I AM.
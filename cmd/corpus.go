@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	corpus "github.com/go-compression/raisin/corpus"
+	"os"
+	"strings"
+)
+
+// corpusCommand handles "raisin corpus generate", writing a synthetic test corpus so benchmarks can run offline.
+func corpusCommand(args []string) {
+	if len(args) < 1 || args[0] != "generate" {
+		errorWithMsg("Usage: raisin corpus generate [-dir=corpus] [-size=262144] [-seed=1] [-classes=text,json,...]\n")
+	}
+
+	defaults := corpus.NewSettings()
+	flags := flag.NewFlagSet("corpus generate", flag.ExitOnError)
+	dir := flags.String("dir", "corpus", fmt.Sprintf("Directory to write the generated files to"))
+	size := flags.Int("size", defaults.Size, fmt.Sprintf("Size of every generated file in bytes"))
+	seed := flags.Int64("seed", defaults.Seed, fmt.Sprintf("Seed to generate the files from, the same seed always gives the same files"))
+	classes := flags.String("classes", strings.Join(corpus.Classes[:], ","),
+		fmt.Sprintf("Which classes of file to generate, choices include: \n\t%s", strings.Join(corpus.Classes[:], ", ")))
+	flags.Parse(args[1:])
+
+	settings := corpus.NewSettings()
+	settings.Size = *size
+	settings.Seed = *seed
+	settings.Classes = strings.Split(*classes, ",")
+	for i, class := range settings.Classes {
+		settings.Classes[i] = strings.TrimSpace(class)
+		if !stringInSlice(settings.Classes[i], corpus.Classes[:]) {
+			errorWithMsg(fmt.Sprintf("'%s' is not a valid class, possible classes include: \n\t%s\n", class, strings.Join(corpus.Classes[:], ", ")))
+		}
+	}
+	if *size < 0 {
+		errorWithMsg("Please provide a non-negative size\n")
+	}
+
+	paths, err := corpus.Write(*dir, settings)
+	check(err)
+	for _, path := range paths {
+		fmt.Fprintln(os.Stdout, path)
+	}
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	engine "github.com/go-compression/raisin/engine"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// dictCommand handles "raisin dict train", training a preset dictionary on a directory of samples and reporting
+// how much it shrinks the samples held out from training.
+func dictCommand(args []string) {
+	usage := "Usage: raisin dict train [-out=dictionary] [-size=16384] [-segment=128] [-dmer=6] [-holdout=10] [-algorithm=lzss,flate,zlib] dir[,dir...]\n"
+	if len(args) < 1 || args[0] != "train" {
+		errorWithMsg(usage)
+	}
+
+	defaults := engine.NewTrainSettings()
+	flags := flag.NewFlagSet("dict train", flag.ExitOnError)
+	out := flags.String("out", "dictionary", fmt.Sprintf("File to write the dictionary to"))
+	size := flags.Int("size", defaults.Size, fmt.Sprintf("Most bytes the dictionary may take up"))
+	segment := flags.Int("segment", defaults.SegmentSize, fmt.Sprintf("Length in bytes of the pieces of samples the dictionary is built from"))
+	dmer := flags.Int("dmer", defaults.DmerSize, fmt.Sprintf("Length in bytes of the substrings counted to pick pieces, at most %d", engine.MaxDmerSize))
+	holdOut := flags.Int("holdout", defaults.HoldOut, fmt.Sprintf("Percentage of samples kept back from training to measure the dictionary on"))
+	algorithm := flags.String("algorithm", "lzss,flate,zlib", fmt.Sprintf("Algorithms to measure the dictionary with, used by: \n\t%s", strings.Join(dictionaryAlgorithms(), ", ")))
+	flags.Parse(args[1:])
+
+	if flags.NArg() < 1 {
+		errorWithMsg(usage)
+	}
+	if *holdOut < 0 || *holdOut > 99 {
+		errorWithMsg("Please provide a hold out percentage between 0 and 99\n")
+	}
+	algorithms := parseAlgorithms(*algorithm)
+	for _, chain := range algorithms {
+		for _, algorithm := range chain {
+			if !stringInSlice(algorithm, engine.Engines[3:]) || engine.IsReadOnly(algorithm) {
+				errorWithMsg(fmt.Sprintf("'%s' can't be used to measure a dictionary\n", algorithm))
+			}
+		}
+	}
+
+	settings := engine.NewTrainSettings()
+	settings.Size = *size
+	settings.SegmentSize = *segment
+	settings.DmerSize = *dmer
+	settings.HoldOut = *holdOut
+
+	files, err := engine.SampleFiles(strings.Split(flags.Arg(0), ","))
+	if err != nil {
+		errorWithMsg(fmt.Sprintf("Couldn't read samples: %s\n", err))
+	}
+	train, test := engine.SplitSamples(files, settings.HoldOut)
+	fmt.Fprintf(os.Stdout, "Training on %d samples, holding out %d\n", len(train), len(test))
+
+	dict, err := engine.TrainFiles(train, settings)
+	if err != nil {
+		errorWithMsg(fmt.Sprintf("Couldn't train dictionary: %s\n", err))
+	}
+	err = ioutil.WriteFile(*out, dict, 0644)
+	check(err)
+	fmt.Fprintf(os.Stdout, "Wrote %d byte dictionary %08x to %s\n", len(dict), engine.DictionaryID(dict), *out)
+
+	if len(test) == 0 {
+		return
+	}
+	benchmarkSettings := engine.NewBenchmarkSettings()
+	benchmarkSettings.Output = ioutil.Discard
+	benchmarkSettings.Format = "json"
+	benchmarkSettings.MeasureMemory = false
+	results := engine.EvaluateDictionary(test, algorithms, dict, benchmarkSettings)
+	fmt.Fprintf(os.Stdout, "Compressing the %d held out samples:\n", len(test))
+	engine.WriteDictionaryResults(os.Stdout, results)
+}
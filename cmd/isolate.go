@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	engine "github.com/go-compression/raisin/engine"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// isolatedBenchmarkFile has the same signature as engine.BenchmarkFileContext but runs the benchmark in a child process
+// using the hidden -benchmark-worker command, so its memory usage and peak RSS aren't mixed with other algorithms.
+// The child process is killed once ctx is done.
+func isolatedBenchmarkFile(ctx context.Context, algorithms []string, fileString string, settings engine.Settings) (engine.Result, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return engine.Result{}, err
+	}
+
+	resultFile, err := ioutil.TempFile("", "raisin-result-*.json")
+	if err != nil {
+		return engine.Result{}, err
+	}
+	resultFile.Close()
+	defer os.Remove(resultFile.Name())
+
+	worker := exec.CommandContext(ctx, executable, "-benchmark-worker",
+		"-algorithm="+strings.Join(algorithms, ","),
+		fmt.Sprintf("-runs=%d", settings.Runs),
+		fmt.Sprintf("-warmup=%d", settings.WarmupRuns),
+		fmt.Sprintf("-memory=%t", settings.MeasureMemory),
+		"-out="+resultFile.Name(),
+		fileString)
+	worker.Stdout = settings.Output
+	worker.Stderr = settings.Output
+	err = worker.Run()
+	if ctx.Err() != nil {
+		return engine.Result{}, ctx.Err()
+	}
+	if err != nil {
+		return engine.Result{}, fmt.Errorf("benchmark worker failed: %s", err)
+	}
+
+	encoded, err := ioutil.ReadFile(resultFile.Name())
+	if err != nil {
+		return engine.Result{}, err
+	}
+	var result engine.Result
+	err = json.Unmarshal(encoded, &result)
+	if err != nil {
+		return engine.Result{}, err
+	}
+
+	result.PeakRSSBytes = peakRSS(worker.ProcessState)
+	return result, nil
+}
+
+// benchmarkWorker runs a single benchmark for isolatedBenchmarkFile and writes the result as json to output.
+func benchmarkWorker(algorithms []string, fileString string, settings engine.Settings, output string) {
+	settings.Output = os.Stderr
+	settings.PrintStatus = false
+	result := engine.BenchmarkFile(algorithms, fileString, settings)
+
+	encoded, err := json.Marshal(result)
+	check(err)
+	err = ioutil.WriteFile(output, encoded, 0644)
+	check(err)
+}
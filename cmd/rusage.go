@@ -0,0 +1,23 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package cmd
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// peakRSS returns the maximum resident set size in bytes of an exited process, or 0 if it is unknown.
+func peakRSS(state *os.ProcessState) int64 {
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	if runtime.GOOS == "darwin" {
+		// macOS reports bytes rather than kilobytes
+		return int64(usage.Maxrss)
+	}
+	return int64(usage.Maxrss) * 1024
+}
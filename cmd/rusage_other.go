@@ -0,0 +1,13 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package cmd
+
+import (
+	"os"
+)
+
+// peakRSS returns 0 as the maximum resident set size isn't available on this platform.
+func peakRSS(state *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,294 @@
+// Package ans implements static asymmetric numeral systems entropy coders, rANS with interleaved states and table-based tANS.
+// Content is split into blocks, each coded with a frequency table of its own bytes normalized to a power of 2.
+// Besides the io.Reader and io.Writer used by the engine, Compress and DecompressLimit work on any slice of bytes,
+// which lets other codecs such as lz.CompressLiterals use it as the entropy coder for their literals.
+package ans
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	codec "github.com/go-compression/raisin/compressor/codec"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	// RANS codes with range variant ANS, which does arithmetic on the states and can interleave several of them
+	RANS = iota
+	// TANS codes with table-based ANS, which looks every step up in a table like the FSE coder in Zstandard
+	TANS
+)
+
+// Settings represents an object that can be used to modify how content is coded
+// Method is RANS or TANS, TableLog is the log2 of the total that frequencies are scaled to, between 5 and 15 (raised when a block has
+// more distinct bytes than that allows), Streams is how many rANS states are interleaved, between 1 and 8, and BlockSize how many bytes share a frequency table.
+type Settings struct {
+	Method    int
+	TableLog  int
+	Streams   int
+	BlockSize int
+}
+
+// NewSettings returns the default settings as a Settings object
+func NewSettings() Settings {
+	s := Settings{}
+	s.Method = RANS
+	s.TableLog = 12
+	s.Streams = 4
+	s.BlockSize = 128 << 10
+	return s
+}
+
+const (
+	rawBlock  = 0
+	runBlock  = 1
+	ransBlock = 2
+	tansBlock = 3
+
+	minTableLog  = 5
+	maxTableLog  = 15
+	maxStreams   = 8
+	maxBlockSize = 1 << 24
+)
+
+var (
+	// ErrCorrupt is returned when decompressing a stream that is malformed
+	ErrCorrupt = errors.New("ans: corrupt input")
+	// ErrTooLarge is returned when a stream decompresses to more than the limit
+	ErrTooLarge = errors.New("ans: decompressed size exceeds limit")
+)
+
+// Compress takes a slice of bytes and returns it as a sequence of coded blocks
+func Compress(content []byte, settings Settings) []byte {
+	blockSize := settings.BlockSize
+	if blockSize <= 0 || blockSize > maxBlockSize {
+		blockSize = maxBlockSize
+	}
+	output := make([]byte, 0, len(content)/2)
+	for start := 0; start < len(content); start += blockSize {
+		end := start + blockSize
+		if end > len(content) {
+			end = len(content)
+		}
+		output = appendBlock(output, content[start:end], settings)
+	}
+	return output
+}
+
+// appendBlock appends a block starting with its type and size. A block of a single repeated byte is stored as a run,
+// and a block that coding would make larger than it is, including its frequency table, is stored as it is.
+func appendBlock(dst []byte, block []byte, settings Settings) []byte {
+	var counts [256]int
+	for _, b := range block {
+		counts[b]++
+	}
+	distinct := 0
+	for _, count := range counts {
+		if count > 0 {
+			distinct++
+		}
+	}
+	if distinct == 1 {
+		return append(appendUvarint(append(dst, runBlock), uint64(len(block))), block[0])
+	}
+
+	log := uint(settings.TableLog)
+	if log < minTableLog {
+		log = minTableLog
+	} else if log > maxTableLog {
+		log = maxTableLog
+	}
+	for 1<<log < distinct {
+		log++
+	}
+	streams := settings.Streams
+	if streams < 1 {
+		streams = 1
+	} else if streams > maxStreams {
+		streams = maxStreams
+	}
+	freqs := normalize(&counts, len(block), log)
+
+	start := len(dst)
+	var payload []byte
+	if settings.Method == TANS {
+		dst = appendUvarint(append(dst, tansBlock), uint64(len(block)))
+		dst = append(dst, byte(log))
+		payload = encodeTANS(nil, block, &freqs, log)
+	} else {
+		dst = appendUvarint(append(dst, ransBlock), uint64(len(block)))
+		dst = append(dst, byte(log), byte(streams))
+		payload = encodeRANS(nil, block, &freqs, log, streams)
+	}
+	dst = appendFreqs(dst, &freqs)
+	dst = appendUvarint(dst, uint64(len(payload)))
+	dst = append(dst, payload...)
+
+	stored := appendUvarint([]byte{rawBlock}, uint64(len(block)))
+	if len(dst)-start > len(stored)+len(block) {
+		dst = append(append(dst[:start], stored...), block...)
+	}
+	return dst
+}
+
+// Decompress takes a sequence of coded blocks and returns the decompressed contents
+func Decompress(content []byte) ([]byte, error) {
+	return DecompressLimit(content, 0)
+}
+
+// DecompressLimit is like Decompress but returns ErrTooLarge once the output would exceed limit bytes, a limit of 0 means no limit.
+func DecompressLimit(content []byte, limit int) ([]byte, error) {
+	return decompress(context.Background(), content, limit)
+}
+
+func decompress(ctx context.Context, content []byte, limit int) ([]byte, error) {
+	output := make([]byte, 0, 2*len(content))
+	for i := 0; i < len(content); {
+		if err := ctx.Err(); err != nil {
+			return output, err
+		}
+		method := content[i]
+		size, n := binary.Uvarint(content[i+1:])
+		if n <= 0 || size > maxBlockSize {
+			return output, ErrCorrupt
+		}
+		i += 1 + n
+		if limit > 0 && len(output)+int(size) > limit {
+			return output, ErrTooLarge
+		}
+
+		switch method {
+		case rawBlock:
+			if i+int(size) > len(content) {
+				return output, ErrCorrupt
+			}
+			output = append(output, content[i:i+int(size)]...)
+			i += int(size)
+		case runBlock:
+			if i >= len(content) {
+				return output, ErrCorrupt
+			}
+			output = append(output, bytes.Repeat(content[i:i+1], int(size))...)
+			i++
+		case ransBlock, tansBlock:
+			header := 1
+			if method == ransBlock {
+				header = 2
+			}
+			if i+header > len(content) {
+				return output, ErrCorrupt
+			}
+			log := uint(content[i])
+			streams := int(content[i+header-1])
+			if log < minTableLog || log > maxTableLog || (method == ransBlock && (streams < 1 || streams > maxStreams)) {
+				return output, ErrCorrupt
+			}
+			i += header
+			freqs, n, err := readFreqs(content[i:], log)
+			if err != nil {
+				return output, err
+			}
+			i += n
+			length, n := binary.Uvarint(content[i:])
+			if n <= 0 || length > uint64(len(content)-i-n) {
+				return output, ErrCorrupt
+			}
+			i += n
+			payload := content[i : i+int(length)]
+			i += int(length)
+			if method == ransBlock {
+				output, err = decodeRANS(output, payload, int(size), &freqs, log, streams)
+			} else {
+				output, err = decodeTANS(output, payload, int(size), &freqs, log)
+			}
+			if err != nil {
+				return output, err
+			}
+		default:
+			return output, ErrCorrupt
+		}
+	}
+	return output, nil
+}
+
+// Writer codes everything written to it, writing out a block every time a block's worth has been written
+type Writer struct {
+	w        io.Writer
+	settings Settings
+	buffer   []byte
+	err      error
+}
+
+// NewWriter creates an io.WriteCloser object with an io.Writer that codes blocks with the default settings
+func NewWriter(w io.Writer) io.WriteCloser {
+	return NewWriterSettings(w, NewSettings())
+}
+
+// NewWriterSettings creates an io.WriteCloser object with an io.Writer that codes blocks with the given settings
+func NewWriterSettings(w io.Writer, settings Settings) io.WriteCloser {
+	if settings.BlockSize <= 0 || settings.BlockSize > maxBlockSize {
+		settings.BlockSize = maxBlockSize
+	}
+	return &Writer{w: w, settings: settings}
+}
+
+func (writer *Writer) Write(data []byte) (n int, err error) {
+	if writer.err != nil {
+		return 0, writer.err
+	}
+	writer.buffer = append(writer.buffer, data...)
+	for len(writer.buffer) >= writer.settings.BlockSize && writer.err == nil {
+		_, writer.err = writer.w.Write(appendBlock(nil, writer.buffer[:writer.settings.BlockSize], writer.settings))
+		writer.buffer = writer.buffer[writer.settings.BlockSize:]
+	}
+	return len(data), writer.err
+}
+
+// Close writes out whatever is left as a final, smaller block
+func (writer *Writer) Close() error {
+	if writer.err == nil && len(writer.buffer) > 0 {
+		_, writer.err = writer.w.Write(appendBlock(nil, writer.buffer, writer.settings))
+		writer.buffer = nil
+	}
+	return writer.err
+}
+
+// Reader decodes blocks, decompressing the whole stream on the first call to Read
+type Reader struct {
+	r            io.Reader
+	ctx          context.Context
+	limit        int
+	decompressed *bytes.Reader
+}
+
+// NewReader creates an io.Reader object that decodes blocks from an io.Reader
+func NewReader(r io.Reader) io.Reader {
+	return NewReaderContext(context.Background(), r, codec.Options{})
+}
+
+// NewReaderContext creates an io.Reader like NewReader that gives up between blocks once ctx is done and fails with ErrTooLarge
+// instead of decompressing more than opts.Limit bytes, a limit of 0 means no limit
+func NewReaderContext(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+	z := new(Reader)
+	z.r = r
+	z.ctx = ctx
+	z.limit = opts.Limit
+	return z
+}
+
+func (r *Reader) Read(content []byte) (n int, err error) {
+	if r.decompressed == nil {
+		compressed, err := ioutil.ReadAll(r.r)
+		if err != nil {
+			return 0, err
+		}
+		decompressed, err := decompress(r.ctx, compressed, r.limit)
+		if err != nil {
+			return 0, err
+		}
+		r.decompressed = bytes.NewReader(decompressed)
+	}
+	return r.decompressed.Read(content)
+}
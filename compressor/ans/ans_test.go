@@ -0,0 +1,136 @@
+package ans
+
+import (
+	"bytes"
+	"github.com/go-compression/raisin/internal/codectest"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func testInputs() map[string][]byte {
+	inputs := codectest.Inputs(5000)
+	r := rand.New(rand.NewSource(1))
+	skewed := make([]byte, 100000)
+	for i := range skewed {
+		// Mostly a few symbols with the odd rare one, so some frequencies round down to the minimum
+		skewed[i] = byte(r.ExpFloat64() * 3)
+	}
+	alphabet := make([]byte, 256*3)
+	for i := range alphabet {
+		alphabet[i] = byte(i)
+	}
+	inputs["two"] = []byte{'a', 'b'}
+	inputs["repeated"] = bytes.Repeat([]byte(codectest.Text), 200)
+	inputs["skewed"] = skewed
+	inputs["alphabet"] = alphabet
+	return inputs
+}
+
+func testSettings() map[string]Settings {
+	return map[string]Settings{
+		"default":      NewSettings(),
+		"rans 1":       {Method: RANS, TableLog: 12, Streams: 1, BlockSize: 128 << 10},
+		"rans 8 log15": {Method: RANS, TableLog: 15, Streams: 8, BlockSize: 128 << 10},
+		"tans":         {Method: TANS, TableLog: 11, BlockSize: 128 << 10},
+		"tans log5":    {Method: TANS, TableLog: 5, BlockSize: 1000},
+		"small blocks": {Method: RANS, TableLog: 10, Streams: 2, BlockSize: 100},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for name, content := range testInputs() {
+		for settingsName, settings := range testSettings() {
+			decompressed, err := Decompress(Compress(content, settings))
+			if err != nil || !bytes.Equal(decompressed, content) {
+				t.Errorf("%s with %s settings was not lossless: %v", name, settingsName, err)
+			}
+		}
+	}
+}
+
+func TestCompressRatio(t *testing.T) {
+	// Both coders should get within 1% of the 37952 bytes the order 0 entropy of the skewed input allows, and never expand random data by more than a block header
+	inputs := testInputs()
+	for _, method := range []int{RANS, TANS} {
+		settings := NewSettings()
+		settings.Method = method
+		if compressed := Compress(inputs["skewed"], settings); len(compressed) > 38330 {
+			t.Errorf("Method %d compressed skewed input to %d bytes", method, len(compressed))
+		}
+		if compressed := Compress(inputs["random"], settings); len(compressed) > len(inputs["random"])+4 {
+			t.Errorf("Method %d expanded %d random bytes to %d", method, len(inputs["random"]), len(compressed))
+		}
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	content := []byte(codectest.Text)
+	for _, settings := range testSettings() {
+		compressed := Compress(content, settings)
+		cases := map[string][]byte{
+			"truncated":        compressed[:len(compressed)-1],
+			"trailing garbage": append(append([]byte{}, compressed...), 7),
+			"unknown method":   append([]byte{9}, compressed[1:]...),
+		}
+		codectest.Corrupt(t, cases, ErrCorrupt, Decompress)
+		// A flipped bit can go unnoticed, since there's no checksum, but must never panic
+		for i := range compressed {
+			corrupt := append([]byte{}, compressed...)
+			corrupt[i] ^= 0x10
+			Decompress(corrupt)
+		}
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	content := testInputs()["repeated"]
+	codectest.Limit(t, content, Compress(content, NewSettings()), ErrTooLarge, DecompressLimit)
+}
+
+func TestWriter(t *testing.T) {
+	// Writes that straddle blocks have to come out the same as compressing everything at once
+	content := testInputs()["skewed"]
+	settings := Settings{Method: TANS, TableLog: 11, BlockSize: 30000}
+	newWriter := func(w io.Writer) io.WriteCloser {
+		return NewWriterSettings(w, settings)
+	}
+	if !bytes.Equal(codectest.Writer(t, content, 777, newWriter, NewReader), Compress(content, settings)) {
+		t.Errorf("Writer split over many writes did not match Compress")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	// Every symbol that occurs must keep a frequency however rare it is, and the total must be exact
+	var counts [256]int
+	counts['a'] = 1000000
+	for i := 0; i < 200; i++ {
+		counts[i+20]++
+	}
+	freqs := normalize(&counts, 1000200, 8)
+	sum := 0
+	for symbol, freq := range freqs {
+		if (freq > 0) != (counts[symbol] > 0) {
+			t.Errorf("Symbol %d has a count of %d but a frequency of %d", symbol, counts[symbol], freq)
+		}
+		sum += freq
+	}
+	if sum != 1<<8 {
+		t.Errorf("Frequencies add up to %d, expected %d", sum, 1<<8)
+	}
+}
+
+// FuzzDecompress checks that no input makes the decoder panic or decompress past its limit
+func FuzzDecompress(f *testing.F) {
+	for _, content := range testInputs() {
+		for _, settings := range testSettings() {
+			f.Add(Compress(content, settings))
+		}
+	}
+	const limit = 1 << 20
+	f.Fuzz(func(t *testing.T, content []byte) {
+		if decompressed, err := DecompressLimit(content, limit); len(decompressed) > limit {
+			t.Fatalf("Decompressed %d bytes past the limit of %d: %v", len(decompressed), limit, err)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package ans
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// bitWriter writes bits from the least significant bit of each byte onwards. Closing it adds a 1 bit after the last bit
+// written, so that a backwardReader can find where the stream ends and read it back in the opposite order.
+type bitWriter struct {
+	out   []byte
+	acc   uint64
+	count uint
+}
+
+func (w *bitWriter) writeBits(value uint64, n uint) {
+	w.acc |= value << w.count
+	w.count += n
+	for w.count >= 8 {
+		w.out = append(w.out, byte(w.acc))
+		w.acc >>= 8
+		w.count -= 8
+	}
+}
+
+func (w *bitWriter) close() []byte {
+	w.writeBits(1, 1)
+	if w.count > 0 {
+		w.out = append(w.out, byte(w.acc))
+	}
+	return w.out
+}
+
+// backwardReader reads the bits of a bitWriter starting from the last one written.
+// pos is the number of bits left to read, reading past the start of the stream returns zeros and leaves pos negative.
+type backwardReader struct {
+	data []byte
+	pos  int
+}
+
+func newBackwardReader(data []byte) (*backwardReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, ErrCorrupt
+	}
+	return &backwardReader{data: data, pos: 8*(len(data)-1) + bits.Len8(data[len(data)-1]) - 1}, nil
+}
+
+// readBits reads n bits, at most 56, with zeros filling in for bits before the start of the stream
+func (r *backwardReader) readBits(n uint) uint64 {
+	if n == 0 {
+		return 0
+	}
+	r.pos -= int(n)
+	start, shift := r.pos, uint(0)
+	if start < 0 {
+		if -start >= int(n) {
+			return 0
+		}
+		start, shift, n = 0, uint(-start), n-uint(-start)
+	}
+	i := start >> 3
+	var word uint64
+	if i+8 <= len(r.data) {
+		word = binary.LittleEndian.Uint64(r.data[i:])
+	} else {
+		for k := len(r.data) - 1; k >= i; k-- {
+			word = word<<8 | uint64(r.data[k])
+		}
+	}
+	return ((word >> uint(start&7)) & (1<<n - 1)) << shift
+}
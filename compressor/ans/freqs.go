@@ -0,0 +1,102 @@
+package ans
+
+import (
+	"encoding/binary"
+)
+
+// normalize scales the counts of a block so that they add up to 1<<log, which is the precision both coders work with.
+// Every symbol that occurs keeps a frequency of at least 1. Whatever rounding leaves over goes to the most frequent
+// symbol, and any excess is taken from the most frequent symbols, where it costs the least.
+func normalize(counts *[256]int, total int, log uint) [256]int {
+	target := 1 << log
+	var freqs [256]int
+	sum := 0
+	largest := 0
+	for symbol, count := range counts {
+		if count == 0 {
+			continue
+		}
+		freq := int(uint64(count) * uint64(target) / uint64(total))
+		if freq == 0 {
+			freq = 1
+		}
+		freqs[symbol] = freq
+		sum += freq
+		if freq > freqs[largest] {
+			largest = symbol
+		}
+	}
+	if sum < target {
+		freqs[largest] += target - sum
+	}
+	for sum > target {
+		largest = 0
+		for symbol, freq := range freqs {
+			if freq > freqs[largest] {
+				largest = symbol
+			}
+		}
+		freqs[largest]--
+		sum--
+	}
+	return freqs
+}
+
+// appendFreqs appends a bitmap of the symbols that occur followed by their frequencies as varints.
+// The last symbol's frequency is left out since it's whatever brings the total up to 1<<log.
+func appendFreqs(dst []byte, freqs *[256]int) []byte {
+	var present [32]byte
+	last := 0
+	for symbol, freq := range freqs {
+		if freq > 0 {
+			present[symbol>>3] |= 1 << uint(symbol&7)
+			last = symbol
+		}
+	}
+	dst = append(dst, present[:]...)
+	for _, freq := range freqs[:last] {
+		if freq > 0 {
+			dst = appendUvarint(dst, uint64(freq-1))
+		}
+	}
+	return dst
+}
+
+// readFreqs reads the frequencies written by appendFreqs, returning them and how many bytes they took up.
+// At least 2 symbols have to occur, a block of a single symbol is stored as a run instead.
+func readFreqs(src []byte, log uint) ([256]int, int, error) {
+	var freqs [256]int
+	if len(src) < 32 {
+		return freqs, 0, ErrCorrupt
+	}
+	var symbols []int
+	for symbol := 0; symbol < 256; symbol++ {
+		if src[symbol>>3]&(1<<uint(symbol&7)) != 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) < 2 {
+		return freqs, 0, ErrCorrupt
+	}
+	i := 32
+	sum := 0
+	for _, symbol := range symbols[:len(symbols)-1] {
+		freq, n := binary.Uvarint(src[i:])
+		if n <= 0 || freq >= 1<<log {
+			return freqs, 0, ErrCorrupt
+		}
+		i += n
+		freqs[symbol] = int(freq) + 1
+		sum += int(freq) + 1
+	}
+	if sum >= 1<<log {
+		return freqs, 0, ErrCorrupt
+	}
+	freqs[symbols[len(symbols)-1]] = 1<<log - sum
+	return freqs, i, nil
+}
+
+func appendUvarint(dst []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(dst, buf[:binary.PutUvarint(buf[:], v)]...)
+}
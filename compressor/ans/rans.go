@@ -0,0 +1,100 @@
+package ans
+
+import (
+	"encoding/binary"
+)
+
+// ransLow is the lower bound of a rANS state. States are kept between ransLow and ransLow<<16 by moving 16 bits at a time
+// between the state and the stream, so that every state fits in 32 bits.
+const ransLow = 1 << 16
+
+// encodeRANS appends the rANS coding of src to dst, with streams states taking turns to code one symbol each.
+// The states are independent so a decoder can work on several at once, but they share a single stream of words.
+// rANS works like a stack, so symbols are coded from last to first and the words are written out reversed,
+// leaving the decoder to read the final states and then the words from front to back.
+func encodeRANS(dst []byte, src []byte, freqs *[256]int, log uint, streams int) []byte {
+	var cumulative [257]uint32
+	for symbol, freq := range freqs {
+		cumulative[symbol+1] = cumulative[symbol] + uint32(freq)
+	}
+	states := make([]uint32, streams)
+	for i := range states {
+		states[i] = ransLow
+	}
+	words := make([]uint16, 0, len(src)/2)
+	for i := len(src) - 1; i >= 0; i-- {
+		symbol := src[i]
+		x := states[i%streams]
+		freq := uint32(freqs[symbol])
+		if uint64(x) >= uint64(freq)<<(32-log) {
+			words = append(words, uint16(x))
+			x >>= 16
+		}
+		states[i%streams] = (x/freq)<<log + x%freq + cumulative[symbol]
+	}
+
+	for _, x := range states {
+		dst = appendUint32(dst, x)
+	}
+	for i := len(words) - 1; i >= 0; i-- {
+		dst = append(dst, byte(words[i]), byte(words[i]>>8))
+	}
+	return dst
+}
+
+// decodeRANS appends the n symbols coded in src by encodeRANS to dst.
+// Once every symbol is decoded the states have to be back where the encoder started and every word has to be used.
+func decodeRANS(dst []byte, src []byte, n int, freqs *[256]int, log uint, streams int) ([]byte, error) {
+	if len(src) < 4*streams {
+		return dst, ErrCorrupt
+	}
+	var cumulative [256]uint32
+	slots := make([]byte, 1<<log)
+	sum := 0
+	for symbol, freq := range freqs {
+		cumulative[symbol] = uint32(sum)
+		for k := 0; k < freq; k++ {
+			slots[sum+k] = byte(symbol)
+		}
+		sum += freq
+	}
+	states := make([]uint32, streams)
+	for i := range states {
+		states[i] = binary.LittleEndian.Uint32(src[4*i:])
+		if states[i] < ransLow {
+			return dst, ErrCorrupt
+		}
+	}
+
+	pos := 4 * streams
+	mask := uint32(1)<<log - 1
+	for i := 0; i < n; i++ {
+		x := states[i%streams]
+		slot := x & mask
+		symbol := slots[slot]
+		x = uint32(freqs[symbol])*(x>>log) + slot - cumulative[symbol]
+		if x < ransLow {
+			if pos+2 > len(src) {
+				return dst, ErrCorrupt
+			}
+			x = x<<16 | uint32(binary.LittleEndian.Uint16(src[pos:]))
+			pos += 2
+		}
+		states[i%streams] = x
+		dst = append(dst, symbol)
+	}
+
+	if pos != len(src) {
+		return dst, ErrCorrupt
+	}
+	for _, x := range states {
+		if x != ransLow {
+			return dst, ErrCorrupt
+		}
+	}
+	return dst, nil
+}
+
+func appendUint32(dst []byte, v uint32) []byte {
+	return append(dst, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
@@ -0,0 +1,90 @@
+package ans
+
+import (
+	"math/bits"
+)
+
+// spread lays out the 1<<log states of a tANS table, giving every symbol as many states as its frequency.
+// Stepping through the table by a fixed odd step spreads each symbol's states far apart, which is what keeps the coder
+// close to the symbols' real probabilities.
+func spread(freqs *[256]int, log uint) []byte {
+	size := 1 << log
+	table := make([]byte, size)
+	step := size>>1 + size>>3 + 3
+	pos := 0
+	for symbol, freq := range freqs {
+		for k := 0; k < freq; k++ {
+			table[pos] = byte(symbol)
+			pos = (pos + step) & (size - 1)
+		}
+	}
+	return table
+}
+
+// encodeTANS appends the tANS coding of src to dst. The state x stays between 1<<log and 2<<log, and a symbol of
+// frequency f is coded by writing just enough low bits of x to bring it between f and 2f, then moving to the state
+// of that symbol's occurrence in the table. Symbols are coded from last to first so the decoder reads them in order.
+func encodeTANS(dst []byte, src []byte, freqs *[256]int, log uint) []byte {
+	size := 1 << log
+	var start, next [256]int
+	sum := 0
+	for symbol, freq := range freqs {
+		start[symbol], next[symbol] = sum, sum
+		sum += freq
+	}
+	states := make([]uint16, size)
+	for pos, symbol := range spread(freqs, log) {
+		states[next[symbol]] = uint16(size + pos)
+		next[symbol]++
+	}
+
+	w := &bitWriter{out: dst}
+	x := size
+	for i := len(src) - 1; i >= 0; i-- {
+		symbol := src[i]
+		freq := freqs[symbol]
+		n := log + 1 - uint(bits.Len(uint(freq)))
+		if x>>n < freq {
+			n--
+		}
+		w.writeBits(uint64(x)&(1<<n-1), n)
+		x = int(states[start[symbol]+x>>n-freq])
+	}
+	w.writeBits(uint64(x-size), log)
+	return w.close()
+}
+
+type tansEntry struct {
+	symbol byte
+	bits   uint8
+	base   uint16
+}
+
+// decodeTANS appends the n symbols coded in src by encodeTANS to dst.
+// Every state of the table knows the symbol it stands for and how to get back to the state before it.
+func decodeTANS(dst []byte, src []byte, n int, freqs *[256]int, log uint) ([]byte, error) {
+	size := 1 << log
+	next := *freqs
+	entries := make([]tansEntry, size)
+	for pos, symbol := range spread(freqs, log) {
+		y := next[symbol]
+		next[symbol]++
+		width := log + 1 - uint(bits.Len(uint(y)))
+		entries[pos] = tansEntry{symbol: symbol, bits: uint8(width), base: uint16(y<<width - size)}
+	}
+
+	r, err := newBackwardReader(src)
+	if err != nil {
+		return dst, err
+	}
+	state := r.readBits(log)
+	for i := 0; i < n; i++ {
+		entry := entries[state]
+		dst = append(dst, entry.symbol)
+		state = uint64(entry.base) + r.readBits(uint(entry.bits))
+	}
+	if r.pos != 0 || state != 0 {
+		return dst, ErrCorrupt
+	}
+	return dst, nil
+}
@@ -1,7 +1,10 @@
 package arithmetic
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	codec "github.com/go-compression/raisin/compressor/codec"
 	"io"
 	"io/ioutil"
 	"sort"
@@ -11,25 +14,90 @@ import (
 
 type sortBytes []byte
 
+const (
+	// RangeCoder codes bytes with a carry-less range coder that has a 32-bit range, so its adaptive model can grow to a total frequency of 65536
+	RangeCoder = iota
+	// BitCoder codes bytes with the original 16-bit coder, which writes a bit at a time and freezes its model once the total frequency reaches 16383
+	BitCoder
+)
+
+// Settings represents an object that can be used to modify how content is coded, Coder is RangeCoder or BitCoder
+// Dictionary primes the adaptive model with bytes that are typical of the content, which helps most on short inputs that
+// would otherwise be over before the model has learnt anything. It has to be passed to DecompressDict to decompress.
+type Settings struct {
+	Coder      int
+	Dictionary []byte
+}
+
+// NewSettings returns the default settings as a Settings object
+func NewSettings() Settings {
+	s := Settings{}
+	s.Coder = RangeCoder
+	return s
+}
+
 // Compress takes a slice of bytes and returns a slice of bytes representing the compressed stream
-func Compress(input []byte) []byte {
+// Streams of the range coder start with a zero byte, which a stream of the bit coder never does, so Decompress can tell them apart.
+func Compress(input []byte, settings Settings) []byte {
+	compressed, _ := compress(context.Background(), input, settings)
+	return compressed
+}
+
+// checkInterval is how many symbols are coded between checks of whether the context is done
+const checkInterval = 4096
 
-	bits := encode(input)
+func compress(ctx context.Context, input []byte, settings Settings) ([]byte, error) {
+	if settings.Coder != BitCoder {
+		return rangeEncode(ctx, input, settings.Dictionary)
+	}
+
+	bits, err := encode(ctx, input, settings.Dictionary)
+	if err != nil {
+		return nil, err
+	}
 
 	err, bytes := bits.Pack().AsByteSlice()
 	if err != nil {
 		panic(err)
 	}
-	return bytes
+	return bytes, nil
 }
 
 // Decompress takes a slice of bytes and returns a slice of bytes representing the decompressed stream
+//
+// Deprecated: the error of a corrupt stream is lost along with the rest of its output, use DecompressLimit instead.
 func Decompress(input []byte) []byte {
-	bits := FromByteSlice(input).Unpack()
+	output, _ := DecompressLimit(input, 0)
+	return output
+}
 
-	output := decode(bits)
+var (
+	// ErrCorrupt is returned when decompressing a stream that doesn't decode to a valid sequence of symbols ending in EOF
+	ErrCorrupt = errors.New("arithmetic: corrupt input")
+	// ErrTooLarge is returned when a stream decompresses to more than the limit
+	ErrTooLarge = errors.New("arithmetic: decompressed size exceeds limit")
+)
 
-	return output
+// DecompressLimit is like Decompress but returns ErrCorrupt for malformed streams and ErrTooLarge once the output would exceed limit bytes.
+// A limit of 0 means no limit.
+func DecompressLimit(input []byte, limit int) ([]byte, error) {
+	return DecompressDict(input, nil, limit)
+}
+
+// DecompressDict is like DecompressLimit for a stream compressed with Settings.Dictionary set to dict
+func DecompressDict(input []byte, dict []byte, limit int) ([]byte, error) {
+	return decompress(context.Background(), input, dict, limit)
+}
+
+func decompress(ctx context.Context, input []byte, dict []byte, limit int) ([]byte, error) {
+	if len(input) > 0 && input[0] == rangeMarker {
+		return rangeDecode(ctx, input, dict, limit)
+	}
+	bits, err := FromByteSlice(input).unpack()
+	if err != nil {
+		return nil, err
+	}
+	return decode(ctx, bits, dict, limit)
 }
 
 const (
@@ -41,37 +109,47 @@ const (
 	maxFreq       = 16383
 )
 
-func decode(bits BitSlice) []byte {
+func decode(ctx context.Context, bits BitSlice, dict []byte, limit int) ([]byte, error) {
 	var output []byte
 	var high, low, value uint32
 	high = maxCode
 	bits = append(bits, []bool{true, false}...)
+	// The decoder reads codeValueBits ahead of the encoder, any further past the end and the stream can't be valid.
+	// Without this check garbage input would decode forever.
+	maxReads := len(bits) + codeValueBits
+	reads := codeValueBits
 
+	// Short streams (such as an empty input) have fewer bits than the code value, missing bits are read as zeros
 	for i := 0; i < codeValueBits; i++ {
 		value <<= 1
-		if bits[i] {
-			value++
-		}
+		var nextBit uint32
+		nextBit, bits = GetNextBit(bits)
+		value += nextBit
 	}
 
-	// val, err := strconv.ParseInt(bits[:codeValueBits], 2, 32)
-	// if err != nil { panic(err) }
-	// value = uint32(val)
-	bits = bits[codeValueBits:]
-
 	model := newModel()
+	model.prime(dict)
 
 	for {
 		difference := high - low + 1
 		scaledValue := ((value-low+1)*model.getCount() - 1) / difference
 
 		char, lower, upper, count := model.getChar(scaledValue)
+		if count == 0 {
+			return output, ErrCorrupt
+		}
 
 		if char == 256 {
 			// EOF char
 			break
 		}
 
+		if limit > 0 && len(output) >= limit {
+			return output, ErrTooLarge
+		}
+		if len(output)%checkInterval == 0 && ctx.Err() != nil {
+			return output, ctx.Err()
+		}
 		output = append(output, byte(char))
 
 		// if count == 0 {
@@ -103,16 +181,20 @@ func decode(bits BitSlice) []byte {
 			var nextBit uint32
 			nextBit, bits = GetNextBit(bits)
 			value += nextBit
+			reads++
+			if reads > maxReads {
+				return output, ErrCorrupt
+			}
 			// if value > 65536 {
 			// 	fmt.Println("uh oh")
 			// }
 			// fmt.Println(strconv.FormatUint(uint64(value), 2), len(strconv.FormatUint(uint64(value), 2)))
 		}
 	}
-	return output
+	return output, nil
 }
 
-func encode(input []byte) BitSlice {
+func encode(ctx context.Context, input []byte, dict []byte) (BitSlice, error) {
 	var toEncode int
 	var bits BitSlice
 	var pendingBits int
@@ -120,6 +202,7 @@ func encode(input []byte) BitSlice {
 	var high, low uint32
 	high = maxCode
 	model := newModel()
+	model.prime(dict)
 
 	inputChars := make([]int, len(input))
 
@@ -131,6 +214,9 @@ func encode(input []byte) BitSlice {
 	inputChars = append(inputChars, 256)
 
 	for i := 0; i < len(inputChars); i++ {
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		toEncode = inputChars[i]
 
 		difference := (high - low) + 1
@@ -161,7 +247,16 @@ func encode(input []byte) BitSlice {
 			low &= maxCode
 		}
 	}
-	return bits
+	return bits, nil
+}
+
+// ModelConfig returns the precision in bits of the coder that wrote a compressed stream, the total frequency at which its adaptive model
+// freezes (or for the range coder, halves) and the number of symbols it models (including EOF for the bit coder).
+func ModelConfig(compressed []byte) (int, int, int) {
+	if len(compressed) > 0 && compressed[0] == rangeMarker {
+		return 32, rangeMaxTotal, rangeSymbols
+	}
+	return codeValueBits, maxFreq, 257
 }
 
 const denom = uint32(100)
@@ -191,6 +286,16 @@ func (model *Model) update(input int) {
 	}
 }
 
+// prime counts every byte of dict as if it had been coded, until the model freezes
+func (model *Model) prime(dict []byte) {
+	for _, b := range dict {
+		if model.frozen {
+			return
+		}
+		model.update(int(b))
+	}
+}
+
 func (model *Model) getProbability(input int) (uint32, uint32, uint32) {
 	lower, upper, count := model.cumulativeFrequencies[input], model.cumulativeFrequencies[input+1], model.cumulativeFrequencies[257]
 	if !model.frozen {
@@ -310,25 +415,49 @@ func (s sortBytes) Len() int {
 
 // Writer takes an io.Writer to write to when compressing
 type Writer struct {
-	w io.Writer
+	w        io.Writer
+	settings Settings
+	buffer   []byte
+	ctx      context.Context
 }
 
-// NewWriter creates an io.WriteCloser object with an io.Writer
+// NewWriter creates an io.WriteCloser object with an io.Writer that codes with the range coder
 func NewWriter(w io.Writer) io.WriteCloser {
+	return NewWriterSettings(w, NewSettings())
+}
+
+// NewWriterSettings creates an io.WriteCloser object with an io.Writer that codes with the given settings
+func NewWriterSettings(w io.Writer, settings Settings) io.WriteCloser {
 	z := new(Writer)
 	z.w = w
+	z.settings = settings
+	z.ctx = context.Background()
+	return z
+}
+
+// NewWriterContext creates an io.WriteCloser like NewWriter that gives up once ctx is done, its model primed with opts.Dict
+func NewWriterContext(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
+	settings := NewSettings()
+	settings.Dictionary = opts.Dict
+	z := NewWriterSettings(w, settings).(*Writer)
+	z.ctx = ctx
 	return z
 }
 
+// Write buffers data, everything written is compressed as a single stream when the Writer is closed
 func (writer *Writer) Write(data []byte) (n int, err error) {
-	compressed := Compress(data)
-	writer.w.Write(compressed)
-	return len(compressed), nil
+	writer.buffer = append(writer.buffer, data...)
+	return len(data), nil
 }
 
-// Close only exists to satisfy the io.WriteCloser interface
+// Close compresses everything written and writes it out
 func (writer *Writer) Close() error {
-	return nil
+	compressed, err := compress(writer.ctx, writer.buffer, writer.settings)
+	if err != nil {
+		return err
+	}
+	_, err = writer.w.Write(compressed)
+	return err
 }
 
 // Reader takes an io.Reader to read from when decompressing
@@ -337,12 +466,25 @@ type Reader struct {
 	compressed   []byte
 	decompressed []byte
 	pos          int
+	limit        int
+	dict         []byte
+	ctx          context.Context
 }
 
 // NewReader creates an io.Reader object with an io.Reader
 func NewReader(r io.Reader) io.Reader {
+	return NewReaderContext(context.Background(), r, codec.Options{})
+}
+
+// NewReaderContext creates an io.Reader like NewReader that gives up once ctx is done and fails with ErrTooLarge
+// instead of decompressing more than opts.Limit bytes, a limit of 0 means no limit.
+// A stream compressed with opts.Dict is decompressed with the same dictionary
+func NewReaderContext(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
 	z := new(Reader)
 	z.r = r
+	z.limit = opts.Limit
+	z.dict = opts.Dict
+	z.ctx = ctx
 	return z
 }
 
@@ -352,7 +494,10 @@ func (r *Reader) Read(content []byte) (n int, err error) {
 		if err != nil {
 			return 0, err
 		}
-		r.decompressed = Decompress(r.compressed)
+		r.decompressed, err = decompress(r.ctx, r.compressed, r.dict, r.limit)
+		if err != nil {
+			return 0, err
+		}
 	}
 	bytesToWriteOut := len(r.decompressed[r.pos:])
 	if len(content) < bytesToWriteOut {
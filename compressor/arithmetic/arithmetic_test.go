@@ -1,9 +1,14 @@
 package arithmetic
 
 import (
+	"bytes"
+	"context"
+	"github.com/go-compression/raisin/internal/codectest"
+	"io"
+	"math"
+	"math/rand"
 	"testing"
 	// "sort"
-	"math"
 )
 
 func round(num float64) int {
@@ -23,7 +28,7 @@ func TestEncode(t *testing.T) {
 	// }
 	// sort.Sort(keys)
 	input := []byte("2320")
-	_ = encode(input)
+	_, _ = encode(context.Background(), input, nil)
 	// TODO rebuild this test
 	// precision := 3
 	// gotTop, gotBot = toFixed(gotTop, precision), toFixed(gotBot, precision)
@@ -31,3 +36,96 @@ func TestEncode(t *testing.T) {
 	//     t.Errorf("encodeLoop(keys, symFreqsWhole, input) = %f, %f; want 0.425, 0.42", gotTop, gotBot)
 	// }
 }
+
+func TestDecompressLimit(t *testing.T) {
+	content := []byte("I AM SAM. I AM SAM. SAM I AM.")
+	for _, coder := range []int{RangeCoder, BitCoder} {
+		codectest.Limit(t, content, Compress(content, Settings{Coder: coder}), ErrTooLarge, DecompressLimit)
+	}
+}
+
+func TestRangeCoder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := make([]byte, 50000)
+	r.Read(random)
+	// The distribution changes halfway through, which a frozen model can't follow
+	shifting := append(bytes.Repeat([]byte("abcabcaab"), 20000), bytes.Repeat([]byte("xyzzy"), 40000)...)
+	inputs := map[string][]byte{
+		"empty":    {},
+		"single":   {'a'},
+		"zeros":    make([]byte, 100000),
+		"random":   random,
+		"shifting": shifting,
+	}
+	for name, input := range inputs {
+		compressed := Compress(input, NewSettings())
+		decompressed, err := DecompressLimit(compressed, 0)
+		if err != nil || !bytes.Equal(decompressed, input) {
+			t.Errorf("%s was not lossless: %v", name, err)
+		}
+	}
+	if compressed := Compress(random, NewSettings()); len(compressed) > len(random)+len(random)/50 {
+		t.Errorf("Range coder expanded %d random bytes to %d", len(random), len(compressed))
+	}
+	ranged, bits := Compress(shifting, NewSettings()), Compress(shifting, Settings{Coder: BitCoder})
+	if len(ranged) >= len(bits)/2 {
+		t.Errorf("Range coder compressed shifting input to %d bytes, not much better than the bit coder's %d", len(ranged), len(bits))
+	}
+}
+
+func TestRangeCoderCorrupt(t *testing.T) {
+	compressed := Compress([]byte("I AM SAM. I AM SAM. SAM I AM."), NewSettings())
+	cases := map[string][]byte{
+		"truncated":        compressed[:len(compressed)-1],
+		"trailing garbage": append(append([]byte{}, compressed...), 7),
+		"huge length":      {0, 0xff, 0xff, 0xff, 0xff, 0x0f, 1, 2, 3, 4},
+	}
+	for name, corrupt := range cases {
+		if _, err := DecompressLimit(corrupt, 0); err != ErrCorrupt {
+			t.Errorf("Decompressing %s returned %v, expected ErrCorrupt", name, err)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	// Both coders are read by the same Reader, which tells them apart by the stream's first byte
+	for _, coder := range []int{RangeCoder, BitCoder} {
+		settings := Settings{Coder: coder}
+		newWriter := func(w io.Writer) io.WriteCloser {
+			return NewWriterSettings(w, settings)
+		}
+		codectest.Writer(t, []byte("I AM SAM. I AM SAM. SAM I AM."), 10, newWriter, NewReader)
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	if _, err := DecompressLimit([]byte{0, 0, 0}, 0); err != ErrCorrupt {
+		t.Errorf("Expected ErrCorrupt for a stream that is all padding, got %v", err)
+	}
+	// Garbage has to either decode to something or fail, not decode forever
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		garbage := make([]byte, 1+r.Intn(64))
+		r.Read(garbage)
+		if output, err := DecompressLimit(garbage, 0); err == nil && len(output) > 8*len(garbage) {
+			t.Errorf("Decoded %d bytes of garbage to %d bytes", len(garbage), len(output))
+		}
+	}
+}
+
+func TestDictionary(t *testing.T) {
+	// A short message is over before an unprimed model learns which bytes are likely
+	dict := bytes.Repeat([]byte(`{"id": 1, "name": "sam", "status": "active"}`), 20)
+	message := []byte(`{"id": 2, "name": "sam i am", "status": "active"}`)
+	for _, coder := range []int{RangeCoder, BitCoder} {
+		plain := Compress(message, Settings{Coder: coder})
+		primed := Compress(message, Settings{Coder: coder, Dictionary: dict})
+		if len(primed) >= len(plain)*3/4 {
+			t.Errorf("Coder %d compressed to %d bytes with a dictionary, %d without", coder, len(primed), len(plain))
+		}
+		decompressed, err := DecompressDict(primed, dict, 0)
+		if err != nil || !bytes.Equal(decompressed, message) {
+			t.Errorf("Coder %d with a dictionary was not lossless: %v", coder, err)
+		}
+	}
+}
@@ -61,13 +61,22 @@ func (bits BitSlice) Pack() BitSlice {
 
 // Unpack unpacks a BitSlice from it's byte representation into the original BitSlice
 func (bits BitSlice) Unpack() BitSlice {
+	unpacked, err := bits.unpack()
+	if err != nil {
+		panic("Couldn't unpack")
+	}
+	return unpacked
+}
+
+// unpack is like Unpack but returns ErrCorrupt if the padding never ends instead of panicking
+func (bits BitSlice) unpack() (BitSlice, error) {
 	for i := 0; i < len(bits); i++ {
 		bit := bits[i]
 		if bit == true {
-			return bits[i+1:]
+			return bits[i+1:], nil
 		}
 	}
-	panic("Couldn't unpack")
+	return nil, ErrCorrupt
 }
 
 // ByteSize is the size of a byte in bits (typically 8)
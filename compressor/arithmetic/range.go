@@ -0,0 +1,212 @@
+package arithmetic
+
+import (
+	"context"
+	"encoding/binary"
+)
+
+// The range coder is carry-less in the way Subbotin's is: whenever the range gets small while low is about to carry
+// into the byte being shifted out, the range is cut down so that it can't, which costs a little precision but means a
+// byte never changes once it's written. low is kept in 64 bits so low+rng can be compared without overflowing.
+const (
+	rangeMarker = 0x00
+	rangeTop    = 1 << 24
+	rangeBottom = 1 << 16
+
+	// rangeIncrement is how much a symbol's frequency grows every time it's seen
+	rangeIncrement = 32
+	// rangeMaxTotal is the total frequency at which the model halves every frequency. The range never drops below
+	// rangeBottom, so a larger total could leave a symbol with no range at all. Letting it grow further with a wider coder
+	// gained under 1% on generated text, json and random input and lost up to two thirds on input whose statistics shift.
+	rangeMaxTotal = rangeBottom
+	rangeSymbols  = 256
+)
+
+// rangeModel is an adaptive order-0 model. Rather than freezing once the total gets large, every frequency is halved,
+// which keeps the model adapting and gives recent bytes more weight than old ones.
+type rangeModel struct {
+	freqs [rangeSymbols]uint32
+	total uint32
+}
+
+func newRangeModel() *rangeModel {
+	model := new(rangeModel)
+	for i := range model.freqs {
+		model.freqs[i] = 1
+	}
+	model.total = rangeSymbols
+	return model
+}
+
+// cumulative returns the total frequency of the symbols before symbol
+func (model *rangeModel) cumulative(symbol byte) uint32 {
+	var cum uint32
+	for _, freq := range model.freqs[:symbol] {
+		cum += freq
+	}
+	return cum
+}
+
+// find returns the symbol whose cumulative range contains target, along with the start of that range
+func (model *rangeModel) find(target uint32) (byte, uint32) {
+	var cum uint32
+	for symbol, freq := range model.freqs {
+		if target < cum+freq {
+			return byte(symbol), cum
+		}
+		cum += freq
+	}
+	return 0, 0
+}
+
+// prime counts every byte of dict as if it had been coded
+func (model *rangeModel) prime(dict []byte) {
+	for _, b := range dict {
+		model.update(b)
+	}
+}
+
+func (model *rangeModel) update(symbol byte) {
+	model.freqs[symbol] += rangeIncrement
+	model.total += rangeIncrement
+	if model.total > rangeMaxTotal {
+		model.total = 0
+		for i, freq := range model.freqs {
+			model.freqs[i] = (freq + 1) / 2
+			model.total += model.freqs[i]
+		}
+	}
+}
+
+type rangeEncoder struct {
+	low uint64
+	rng uint32
+	out []byte
+}
+
+func (e *rangeEncoder) encode(cum, freq, total uint32) {
+	r := e.rng / total
+	e.low += uint64(r * cum)
+	e.rng = r * freq
+	for {
+		if e.low^(e.low+uint64(e.rng)) >= rangeTop {
+			if e.rng >= rangeBottom {
+				break
+			}
+			e.rng = uint32(-e.low) & (rangeBottom - 1)
+		}
+		e.out = append(e.out, byte(e.low>>24))
+		e.low = e.low << 8 & 0xffffffff
+		e.rng <<= 8
+	}
+}
+
+func (e *rangeEncoder) flush() []byte {
+	for i := 0; i < 4; i++ {
+		e.out = append(e.out, byte(e.low>>24))
+		e.low = e.low << 8 & 0xffffffff
+	}
+	return e.out
+}
+
+type rangeDecoder struct {
+	low  uint64
+	code uint64
+	rng  uint32
+	in   []byte
+	pos  int
+}
+
+// next reads the next byte, a decoder that reads past the end gets zeros and is caught by the check that pos ends up at len(in)
+func (d *rangeDecoder) next() uint64 {
+	d.pos++
+	if d.pos > len(d.in) {
+		return 0
+	}
+	return uint64(d.in[d.pos-1])
+}
+
+// target returns where the code falls within a total frequency of total, which is total or more for a corrupt stream
+func (d *rangeDecoder) target(total uint32) uint32 {
+	r := d.rng / total
+	t := (d.code - d.low) / uint64(r)
+	if t > uint64(total) {
+		return total
+	}
+	return uint32(t)
+}
+
+func (d *rangeDecoder) decode(cum, freq, total uint32) {
+	r := d.rng / total
+	d.low += uint64(r * cum)
+	d.rng = r * freq
+	for {
+		if d.low^(d.low+uint64(d.rng)) >= rangeTop {
+			if d.rng >= rangeBottom {
+				break
+			}
+			d.rng = uint32(-d.low) & (rangeBottom - 1)
+		}
+		d.code = (d.code<<8 | d.next()) & 0xffffffff
+		d.low = d.low << 8 & 0xffffffff
+		d.rng <<= 8
+	}
+}
+
+// rangeEncode codes input with the range coder, after a marker byte and the length of input as a varint.
+// The model is primed with dict, which the decoder has to be given as well.
+func rangeEncode(ctx context.Context, input []byte, dict []byte) ([]byte, error) {
+	var header [binary.MaxVarintLen64 + 1]byte
+	header[0] = rangeMarker
+	n := binary.PutUvarint(header[1:], uint64(len(input)))
+	e := &rangeEncoder{rng: 0xffffffff, out: append(make([]byte, 0, len(input)/2), header[:1+n]...)}
+	model := newRangeModel()
+	model.prime(dict)
+	for i, symbol := range input {
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		e.encode(model.cumulative(symbol), model.freqs[symbol], model.total)
+		model.update(symbol)
+	}
+	return e.flush(), nil
+}
+
+func rangeDecode(ctx context.Context, input []byte, dict []byte, limit int) ([]byte, error) {
+	if len(input) == 0 || input[0] != rangeMarker {
+		return nil, ErrCorrupt
+	}
+	length, n := binary.Uvarint(input[1:])
+	if n <= 0 {
+		return nil, ErrCorrupt
+	}
+	if limit > 0 && length > uint64(limit) {
+		return nil, ErrTooLarge
+	}
+	d := &rangeDecoder{rng: 0xffffffff, in: input[1+n:]}
+	for i := 0; i < 4; i++ {
+		d.code = d.code<<8 | d.next()
+	}
+
+	var output []byte
+	model := newRangeModel()
+	model.prime(dict)
+	for uint64(len(output)) < length {
+		if len(output)%checkInterval == 0 && ctx.Err() != nil {
+			return output, ctx.Err()
+		}
+		target := d.target(model.total)
+		// A valid stream never reads past its end, checking as it goes stops garbage with a huge length early
+		if target >= model.total || d.pos > len(d.in) {
+			return output, ErrCorrupt
+		}
+		symbol, cum := model.find(target)
+		d.decode(cum, model.freqs[symbol], model.total)
+		model.update(symbol)
+		output = append(output, symbol)
+	}
+	if d.pos != len(d.in) {
+		return output, ErrCorrupt
+	}
+	return output, nil
+}
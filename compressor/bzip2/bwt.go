@@ -0,0 +1,128 @@
+package bzip2
+
+import (
+	"context"
+)
+
+// sortRotations returns the start of every rotation of block in sorted order.
+// Rotations are sorted by prefix doubling: once they're sorted by their first h bytes, sorting the pairs of
+// classes of the rotations starting at i and i+h sorts them by their first 2h bytes, and counting sorts keep every round linear.
+// The context is checked before every round, a repetitive block can take as many rounds as the log of its size.
+func sortRotations(ctx context.Context, block []byte) ([]int32, error) {
+	n := len(block)
+	order := make([]int32, n)
+	classes := make([]int32, n)
+	if n == 0 {
+		return order, nil
+	}
+
+	var count [256]int
+	for _, b := range block {
+		count[b]++
+	}
+	for i := 1; i < 256; i++ {
+		count[i] += count[i-1]
+	}
+	for i := n - 1; i >= 0; i-- {
+		count[block[i]]--
+		order[count[block[i]]] = int32(i)
+	}
+	classCount := int32(1)
+	for i := 1; i < n; i++ {
+		if block[order[i]] != block[order[i-1]] {
+			classCount++
+		}
+		classes[order[i]] = classCount - 1
+	}
+
+	shifted := make([]int32, n)
+	newClasses := make([]int32, n)
+	counts := make([]int32, n)
+	for h := 1; h < n && int(classCount) < n; h <<= 1 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Sorted by the second half already, so a stable sort by the first half sorts by both
+		for i, start := range order {
+			shifted[i] = start - int32(h)
+			if shifted[i] < 0 {
+				shifted[i] += int32(n)
+			}
+		}
+		for i := range counts[:classCount] {
+			counts[i] = 0
+		}
+		for _, start := range shifted {
+			counts[classes[start]]++
+		}
+		for i := int32(1); i < classCount; i++ {
+			counts[i] += counts[i-1]
+		}
+		for i := n - 1; i >= 0; i-- {
+			class := classes[shifted[i]]
+			counts[class]--
+			order[counts[class]] = shifted[i]
+		}
+
+		second := func(start int32) int32 {
+			return classes[(int(start)+h)%n]
+		}
+		newClasses[order[0]] = 0
+		classCount = 1
+		for i := 1; i < n; i++ {
+			if classes[order[i]] != classes[order[i-1]] || second(order[i]) != second(order[i-1]) {
+				classCount++
+			}
+			newClasses[order[i]] = classCount - 1
+		}
+		classes, newClasses = newClasses, classes
+	}
+	return order, nil
+}
+
+// bwt returns the Burrows-Wheeler transform of block, the last byte of every sorted rotation, along with where the unrotated block ended up.
+func bwt(ctx context.Context, block []byte) ([]byte, int, error) {
+	order, err := sortRotations(ctx, block)
+	if err != nil {
+		return nil, 0, err
+	}
+	n := len(block)
+	last := make([]byte, n)
+	origPtr := 0
+	for i, start := range order {
+		if start == 0 {
+			origPtr = i
+			last[i] = block[n-1]
+		} else {
+			last[i] = block[start-1]
+		}
+	}
+	return last, origPtr, nil
+}
+
+// inverseBWT rebuilds a block from its Burrows-Wheeler transform.
+// The sorted rotations' first bytes are the last bytes sorted, and the nth occurrence of a byte in the first column is
+// the nth occurrence in the last column, which is enough to follow the block from one byte to the next.
+func inverseBWT(last []byte, origPtr int) []byte {
+	var start [256]int
+	for _, b := range last {
+		start[b]++
+	}
+	sum := 0
+	for b, count := range start {
+		start[b] = sum
+		sum += count
+	}
+	next := make([]int32, len(last))
+	for i, b := range last {
+		next[start[b]] = int32(i)
+		start[b]++
+	}
+	block := make([]byte, len(last))
+	p := next[origPtr]
+	for i := range block {
+		block[i] = last[p]
+		p = next[p]
+	}
+	return block
+}
@@ -0,0 +1,505 @@
+// Package bzip2 implements a bzip2 encoder and decoder: the Burrows-Wheeler transform, move-to-front and run length coding,
+// and Huffman coding with up to 6 tables switched between every 50 symbols.
+// Its streams can be read by the bzip2 command line tool and the standard library, and it reads theirs.
+package bzip2
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	codec "github.com/go-compression/raisin/compressor/codec"
+	"io"
+	"io/ioutil"
+
+	huffman "github.com/go-compression/raisin/compressor/huffman"
+)
+
+// Settings represents an object that can be used to modify how Compress encodes a stream
+// Level sets the block size to Level times 100 KB, from 1 to 9, larger blocks compress better but use more memory.
+type Settings struct {
+	Level int
+}
+
+// NewSettings returns the default settings for Compress as a Settings object, the same as bzip2 -9
+func NewSettings() Settings {
+	s := Settings{}
+	s.Level = 9
+	return s
+}
+
+const (
+	blockMagicHigh = 0x314159
+	blockMagicLow  = 0x265359
+	endMagicHigh   = 0x177245
+	endMagicLow    = 0x385090
+
+	runA = 0
+	runB = 1
+	// groupSize is how many symbols are coded with one table before the next selector picks another
+	groupSize     = 50
+	maxTables     = 6
+	maxCodeLength = 17
+	maxDecodeLen  = 20
+	// tableIterations is how many times the tables are refined against the groups that picked them
+	tableIterations = 4
+)
+
+var (
+	// ErrCorrupt is returned when decompressing a stream that is malformed
+	ErrCorrupt = errors.New("bzip2: corrupt input")
+	// ErrChecksum is returned when a block or stream CRC doesn't match
+	ErrChecksum = errors.New("bzip2: checksum mismatch")
+	// ErrTooLarge is returned when a stream decompresses to more than the limit
+	ErrTooLarge = errors.New("bzip2: decompressed size exceeds limit")
+	// ErrRandomized is returned for blocks written by bzip2 0.9.0 and earlier with randomization, which isn't supported
+	ErrRandomized = errors.New("bzip2: randomized blocks are not supported")
+)
+
+// crcTable is the table of the unreflected CRC-32 bzip2 uses, which is why hash/crc32 can't be used
+var crcTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		c := uint32(i) << 24
+		for k := 0; k < 8; k++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04c11db7
+			} else {
+				c <<= 1
+			}
+		}
+		table[i] = c
+	}
+	return table
+}()
+
+func blockCRC(data []byte) uint32 {
+	crc := ^uint32(0)
+	for _, b := range data {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^b]
+	}
+	return ^crc
+}
+
+// combineCRC adds the CRC of a block to the CRC of the stream
+func combineCRC(combined uint32, crc uint32) uint32 {
+	return (combined<<1 | combined>>31) ^ crc
+}
+
+// bitWriter packs bits into bytes starting from the most significant bit, as bzip2 requires.
+type bitWriter struct {
+	out  []byte
+	bits uint64
+	n    uint
+}
+
+func (w *bitWriter) writeBits(value uint32, n uint) {
+	w.bits = w.bits<<n | uint64(value)&(1<<n-1)
+	w.n += n
+	for w.n >= 8 {
+		w.out = append(w.out, byte(w.bits>>(w.n-8)))
+		w.n -= 8
+	}
+}
+
+func (w *bitWriter) flush() []byte {
+	if w.n > 0 {
+		w.out = append(w.out, byte(w.bits<<(8-w.n)))
+		w.n = 0
+	}
+	return w.out
+}
+
+func (s Settings) level() int {
+	if s.Level < 1 {
+		return 1
+	} else if s.Level > 9 {
+		return 9
+	}
+	return s.Level
+}
+
+// Compress takes a slice of bytes and returns it as a bzip2 stream
+func Compress(content []byte, settings Settings) []byte {
+	compressed, _ := compress(context.Background(), content, settings)
+	return compressed
+}
+
+func compress(ctx context.Context, content []byte, settings Settings) ([]byte, error) {
+	level := settings.level()
+	w := &bitWriter{out: []byte{'B', 'Z', 'h', byte('0' + level)}}
+	// The reference encoder keeps every block 19 bytes under the size in the header
+	maxBlock := level*100000 - 19
+	combined := uint32(0)
+	for len(content) > 0 {
+		block, consumed := runLengthEncode(content, maxBlock)
+		crc := blockCRC(content[:consumed])
+		combined = combineCRC(combined, crc)
+		if err := writeBlock(ctx, w, block, crc); err != nil {
+			return nil, err
+		}
+		content = content[consumed:]
+	}
+	w.writeBits(endMagicHigh, 24)
+	w.writeBits(endMagicLow, 24)
+	w.writeBits(combined, 32)
+	return w.flush(), nil
+}
+
+// runLengthEncode replaces runs of 4 to 255 identical bytes with 4 of them and a count of the rest, stopping before the result is larger than maxBlock.
+// It returns the encoded block and how much of content it covers.
+func runLengthEncode(content []byte, maxBlock int) ([]byte, int) {
+	size := len(content)
+	if size > maxBlock {
+		size = maxBlock
+	}
+	block := make([]byte, 0, size)
+	i := 0
+	for i < len(content) {
+		b := content[i]
+		run := 1
+		for run < 255 && i+run < len(content) && content[i+run] == b {
+			run++
+		}
+		if run >= 4 {
+			if len(block)+5 > maxBlock {
+				break
+			}
+			block = append(block, b, b, b, b, byte(run-4))
+		} else {
+			if len(block)+run > maxBlock {
+				break
+			}
+			for k := 0; k < run; k++ {
+				block = append(block, b)
+			}
+		}
+		i += run
+	}
+	return block, i
+}
+
+// moveToFront returns the symbols for the Burrows-Wheeler transform of a block: the move-to-front index of every byte, counting only the bytes
+// that are used, plus one, with runs of zeros written in bijective base 2 as RUNA and RUNB and an end of block symbol after the largest index.
+// It also returns which bytes are used.
+func moveToFront(last []byte) ([]uint16, [256]bool) {
+	var inUse [256]bool
+	for _, b := range last {
+		inUse[b] = true
+	}
+	var toSeq [256]byte
+	var list []byte
+	for b := 0; b < 256; b++ {
+		if inUse[b] {
+			toSeq[b] = byte(len(list))
+			list = append(list, byte(len(list)))
+		}
+	}
+	endOfBlock := uint16(len(list) + 1)
+
+	symbols := make([]uint16, 0, len(last)/2+1)
+	zeros := 0
+	flushZeros := func() {
+		for zeros > 0 {
+			zeros--
+			symbols = append(symbols, uint16(zeros&1))
+			zeros >>= 1
+		}
+	}
+	for _, b := range last {
+		seq := toSeq[b]
+		if list[0] == seq {
+			zeros++
+			continue
+		}
+		flushZeros()
+		j := 1
+		for list[j] != seq {
+			j++
+		}
+		copy(list[1:j+1], list[:j])
+		list[0] = seq
+		symbols = append(symbols, uint16(j+1))
+	}
+	flushZeros()
+	return append(symbols, endOfBlock), inUse
+}
+
+// codeLengths returns the code lengths of a table, every symbol needs a code so unused symbols are counted once
+func codeLengths(freqs []int) []int {
+	weights := make([]int, len(freqs))
+	for symbol, freq := range freqs {
+		weights[symbol] = freq
+		if freq == 0 {
+			weights[symbol] = 1
+		}
+	}
+	return huffman.LimitedCodeLengths(weights, maxCodeLength)
+}
+
+// canonicalCodes assigns codes in order of length and then of symbol, the same order the decoder rebuilds them in
+func canonicalCodes(lengths []int) []uint32 {
+	codes := make([]uint32, len(lengths))
+	code := uint32(0)
+	for length := 1; length <= maxDecodeLen; length++ {
+		for symbol, l := range lengths {
+			if l == length {
+				codes[symbol] = code
+				code++
+			}
+		}
+		code <<= 1
+	}
+	return codes
+}
+
+// chooseTables splits the symbols into groups of 50 and builds the Huffman tables to code them with, returning the tables and which one each group uses.
+// The tables start out covering slices of the alphabet with roughly equal frequency and are then refined by letting every group pick
+// the table that codes it smallest and rebuilding each table from the groups that picked it.
+func chooseTables(symbols []uint16, alphaSize int) ([][]int, []int) {
+	freqs := make([]int, alphaSize)
+	for _, symbol := range symbols {
+		freqs[symbol]++
+	}
+	tableCount := 6
+	switch {
+	case len(symbols) < 200:
+		tableCount = 2
+	case len(symbols) < 600:
+		tableCount = 3
+	case len(symbols) < 1200:
+		tableCount = 4
+	case len(symbols) < 2400:
+		tableCount = 5
+	}
+
+	tables := make([][]int, tableCount)
+	remaining := len(symbols)
+	start := 0
+	for part := tableCount; part > 0; part-- {
+		target := remaining / part
+		end := start - 1
+		sum := 0
+		for sum < target && end < alphaSize-1 {
+			end++
+			sum += freqs[end]
+		}
+		if end > start && part != tableCount && part != 1 && (tableCount-part)%2 == 1 {
+			sum -= freqs[end]
+			end--
+		}
+		lengths := make([]int, alphaSize)
+		for symbol := range lengths {
+			if symbol < start || symbol > end {
+				lengths[symbol] = 15
+			}
+		}
+		tables[part-1] = lengths
+		remaining -= sum
+		start = end + 1
+	}
+
+	selectors := make([]int, (len(symbols)+groupSize-1)/groupSize)
+	for iteration := 0; iteration < tableIterations; iteration++ {
+		tableFreqs := make([][]int, tableCount)
+		for t := range tableFreqs {
+			tableFreqs[t] = make([]int, alphaSize)
+		}
+		for g := range selectors {
+			group := symbols[g*groupSize:]
+			if len(group) > groupSize {
+				group = group[:groupSize]
+			}
+			best, bestCost := 0, -1
+			for t, lengths := range tables {
+				cost := 0
+				for _, symbol := range group {
+					cost += lengths[symbol]
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = t, cost
+				}
+			}
+			selectors[g] = best
+			for _, symbol := range group {
+				tableFreqs[best][symbol]++
+			}
+		}
+		for t := range tables {
+			tables[t] = codeLengths(tableFreqs[t])
+		}
+	}
+	return tables, selectors
+}
+
+// writeBlock writes a run length encoded block with the CRC of the content it came from
+func writeBlock(ctx context.Context, w *bitWriter, block []byte, crc uint32) error {
+	last, origPtr, err := bwt(ctx, block)
+	if err != nil {
+		return err
+	}
+	symbols, inUse := moveToFront(last)
+	used := 0
+	for _, u := range inUse {
+		if u {
+			used++
+		}
+	}
+	alphaSize := used + 2
+	tables, selectors := chooseTables(symbols, alphaSize)
+
+	w.writeBits(blockMagicHigh, 24)
+	w.writeBits(blockMagicLow, 24)
+	w.writeBits(crc, 32)
+	// Randomized blocks are long deprecated
+	w.writeBits(0, 1)
+	w.writeBits(uint32(origPtr), 24)
+
+	// Which bytes are used is written as a bitmap of which ranges of 16 have any, followed by a bitmap of each of those ranges
+	var ranges uint32
+	for i := 0; i < 16; i++ {
+		for j := 0; j < 16; j++ {
+			if inUse[i*16+j] {
+				ranges |= 0x8000 >> uint(i)
+				break
+			}
+		}
+	}
+	w.writeBits(ranges, 16)
+	for i := 0; i < 16; i++ {
+		if ranges&(0x8000>>uint(i)) == 0 {
+			continue
+		}
+		var bitmap uint32
+		for j := 0; j < 16; j++ {
+			if inUse[i*16+j] {
+				bitmap |= 0x8000 >> uint(j)
+			}
+		}
+		w.writeBits(bitmap, 16)
+	}
+
+	// Selectors are move-to-front coded and then written in unary
+	w.writeBits(uint32(len(tables)), 3)
+	w.writeBits(uint32(len(selectors)), 15)
+	order := []int{0, 1, 2, 3, 4, 5}
+	for _, selector := range selectors {
+		j := 0
+		for order[j] != selector {
+			j++
+		}
+		copy(order[1:j+1], order[:j])
+		order[0] = selector
+		for k := 0; k < j; k++ {
+			w.writeBits(1, 1)
+		}
+		w.writeBits(0, 1)
+	}
+
+	// Code lengths are written as the difference from the one before, 10 adds one and 11 takes one away
+	for _, lengths := range tables {
+		current := lengths[0]
+		w.writeBits(uint32(current), 5)
+		for _, length := range lengths {
+			for current < length {
+				w.writeBits(2, 2)
+				current++
+			}
+			for current > length {
+				w.writeBits(3, 2)
+				current--
+			}
+			w.writeBits(0, 1)
+		}
+	}
+
+	codes := make([][]uint32, len(tables))
+	for t, lengths := range tables {
+		codes[t] = canonicalCodes(lengths)
+	}
+	for i, symbol := range symbols {
+		t := selectors[i/groupSize]
+		w.writeBits(codes[t][symbol], uint(tables[t][symbol]))
+	}
+	return nil
+}
+
+// Writer compresses everything written to it as one bzip2 stream on Close
+type Writer struct {
+	w        io.Writer
+	settings Settings
+	buffer   bytes.Buffer
+	ctx      context.Context
+}
+
+// NewWriter creates an io.WriteCloser object with an io.Writer and the default settings
+func NewWriter(w io.Writer) io.WriteCloser {
+	return NewWriterSettings(w, NewSettings())
+}
+
+// NewWriterSettings creates an io.WriteCloser object with an io.Writer that compresses with the given settings
+func NewWriterSettings(w io.Writer, settings Settings) io.WriteCloser {
+	z := new(Writer)
+	z.w = w
+	z.settings = settings
+	z.ctx = context.Background()
+	return z
+}
+
+// NewWriterContext creates an io.WriteCloser like NewWriter that gives up once ctx is done
+func NewWriterContext(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
+	z := NewWriterSettings(w, NewSettings()).(*Writer)
+	z.ctx = ctx
+	return z
+}
+
+func (writer *Writer) Write(data []byte) (n int, err error) {
+	return writer.buffer.Write(data)
+}
+
+// Close compresses everything written so far and writes out the stream
+func (writer *Writer) Close() error {
+	compressed, err := compress(writer.ctx, writer.buffer.Bytes(), writer.settings)
+	if err != nil {
+		return err
+	}
+	_, err = writer.w.Write(compressed)
+	return err
+}
+
+// Reader decompresses everything from an io.Reader on the first call to Read
+type Reader struct {
+	r            io.Reader
+	limit        int
+	ctx          context.Context
+	decompressed *bytes.Reader
+}
+
+// NewReader creates an io.Reader object that decompresses bzip2 streams from an io.Reader
+func NewReader(r io.Reader) io.Reader {
+	return NewReaderContext(context.Background(), r, codec.Options{})
+}
+
+// NewReaderContext creates an io.Reader like NewReader that gives up once ctx is done and fails with ErrTooLarge
+// instead of decompressing more than opts.Limit bytes, a limit of 0 means no limit
+func NewReaderContext(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+	z := new(Reader)
+	z.r = r
+	z.limit = opts.Limit
+	z.ctx = ctx
+	return z
+}
+
+func (r *Reader) Read(content []byte) (n int, err error) {
+	if r.decompressed == nil {
+		compressed, err := ioutil.ReadAll(r.r)
+		if err != nil {
+			return 0, err
+		}
+		decompressed, err := decompress(r.ctx, compressed, r.limit)
+		if err != nil {
+			return 0, err
+		}
+		r.decompressed = bytes.NewReader(decompressed)
+	}
+	return r.decompressed.Read(content)
+}
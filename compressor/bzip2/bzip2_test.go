@@ -0,0 +1,118 @@
+package bzip2
+
+import (
+	"bytes"
+	stdbzip2 "compress/bzip2"
+	"context"
+	"github.com/go-compression/raisin/internal/codectest"
+	"io/ioutil"
+	"os/exec"
+	"sort"
+	"testing"
+)
+
+const samIAm = "I AM SAM. I AM SAM. SAM I AM.\nTHAT SAM-I-AM! THAT SAM-I-AM! I DO NOT LIKE THAT SAM-I-AM!\n"
+
+func testInputs() map[string][]byte {
+	// Level 1 splits the larger inputs into several blocks
+	inputs := codectest.Inputs(150000)
+	inputs["periodic"] = bytes.Repeat([]byte("ab"), 5000)
+	return inputs
+}
+
+func TestCompressStandardLibrary(t *testing.T) {
+	for name, content := range testInputs() {
+		for _, level := range []int{1, 9} {
+			compressed := Compress(content, Settings{Level: level})
+			decompressed, err := ioutil.ReadAll(stdbzip2.NewReader(bytes.NewReader(compressed)))
+			if err != nil {
+				t.Errorf("%s at level %d could not be decoded by compress/bzip2: %v", name, level, err)
+			} else if !bytes.Equal(decompressed, content) {
+				t.Errorf("%s at level %d was not lossless with compress/bzip2", name, level)
+			}
+			decompressed, err = Decompress(compressed)
+			if err != nil || !bytes.Equal(decompressed, content) {
+				t.Errorf("%s at level %d was not lossless: %v", name, level, err)
+			}
+		}
+	}
+}
+
+func TestBzip2Tool(t *testing.T) {
+	bzip2, err := exec.LookPath("bzip2")
+	if err != nil {
+		t.Skip("bzip2 is not installed")
+	}
+	for name, content := range testInputs() {
+		cmd := exec.Command(bzip2, "-dc")
+		cmd.Stdin = bytes.NewReader(Compress(content, NewSettings()))
+		decompressed, err := cmd.Output()
+		if err != nil || !bytes.Equal(decompressed, content) {
+			t.Errorf("bzip2 -d did not decode %s losslessly: %v", name, err)
+		}
+
+		cmd = exec.Command(bzip2, "-1", "-c")
+		cmd.Stdin = bytes.NewReader(content)
+		compressed, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decompressed, err = Decompress(compressed)
+		if err != nil || !bytes.Equal(decompressed, content) {
+			t.Errorf("Failed to decode %s compressed by bzip2: %v", name, err)
+		}
+	}
+}
+
+func TestSortRotations(t *testing.T) {
+	for _, block := range []string{"a", "banana", "abababab", "mississippi", samIAm} {
+		rotation := func(i int32) string {
+			return block[i:] + block[:i]
+		}
+		order, _ := sortRotations(context.Background(), []byte(block))
+		if !sort.SliceIsSorted(order, func(i, j int) bool { return rotation(order[i]) < rotation(order[j]) }) {
+			t.Errorf("Rotations of %q were not sorted: %v", block, order)
+		}
+		last, origPtr, _ := bwt(context.Background(), []byte(block))
+		if string(inverseBWT(last, origPtr)) != block {
+			t.Errorf("Inverse transform of %q did not give it back", block)
+		}
+	}
+}
+
+func TestConcatenatedStreams(t *testing.T) {
+	text := []byte(samIAm)
+	decompressed, err := Decompress(append(Compress(text, NewSettings()), Compress(text, NewSettings())...))
+	if err != nil || !bytes.Equal(decompressed, append(append([]byte{}, text...), text...)) {
+		t.Errorf("Concatenated streams were not decompressed: %v", err)
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	content := codectest.Generate("text", 5000)
+	compressed := Compress(content, NewSettings())
+	cases := map[string][]byte{
+		"empty":            {},
+		"bad magic":        append([]byte("BZx9"), compressed[4:]...),
+		"bad level":        append([]byte("BZh0"), compressed[4:]...),
+		"truncated":        compressed[:len(compressed)/2],
+		"trailing garbage": append(append([]byte{}, compressed...), 1, 2, 3, 4),
+	}
+	codectest.Corrupt(t, cases, ErrCorrupt, Decompress)
+	crc := append(append(append([]byte{}, compressed[:10]...), compressed[10]^1), compressed[11:]...)
+	if _, err := Decompress(crc); err != ErrChecksum {
+		t.Errorf("Decompressing with a bad block crc returned %v, expected ErrChecksum", err)
+	}
+	codectest.Flips(t, content, compressed, 4, 7, Decompress)
+}
+
+func TestDecompressLimit(t *testing.T) {
+	content := testInputs()["run"]
+	compressed := Compress(content, NewSettings())
+	codectest.Limit(t, content, compressed, ErrTooLarge, DecompressLimit)
+	codectest.ReaderLimit(t, content, compressed, ErrTooLarge, NewReaderContext)
+}
+
+func TestWriter(t *testing.T) {
+	codectest.Writer(t, testInputs()["text"], 30000, NewWriter, NewReader)
+}
@@ -0,0 +1,288 @@
+package bzip2
+
+import (
+	"context"
+)
+
+// bitReader reads bits starting from the most significant bit of each byte.
+// Reading past the end returns zeros and sets overrun, which callers check once a structure has been read.
+type bitReader struct {
+	data    []byte
+	pos     int
+	overrun bool
+}
+
+func (r *bitReader) readBit() uint32 {
+	if r.pos >= len(r.data)*8 {
+		r.overrun = true
+		return 0
+	}
+	bit := uint32(r.data[r.pos>>3]>>(7-uint(r.pos&7))) & 1
+	r.pos++
+	return bit
+}
+
+func (r *bitReader) readBits(n uint) uint32 {
+	var value uint32
+	for i := uint(0); i < n; i++ {
+		value = value<<1 | r.readBit()
+	}
+	return value
+}
+
+// decodingTable decodes the canonical Huffman codes of one table a bit at a time.
+// The codes of each length are consecutive, so a code is known once it falls inside the range of its length.
+type decodingTable struct {
+	first   [maxDecodeLen + 1]int
+	count   [maxDecodeLen + 1]int
+	index   [maxDecodeLen + 1]int
+	symbols []uint16
+}
+
+func newDecodingTable(lengths []int) *decodingTable {
+	d := &decodingTable{}
+	for _, length := range lengths {
+		d.count[length]++
+	}
+	code, index := 0, 0
+	for length := 1; length <= maxDecodeLen; length++ {
+		d.first[length] = code
+		d.index[length] = index
+		code = (code + d.count[length]) << 1
+		index += d.count[length]
+	}
+	d.symbols = make([]uint16, 0, len(lengths))
+	for length := 1; length <= maxDecodeLen; length++ {
+		for symbol, l := range lengths {
+			if l == length {
+				d.symbols = append(d.symbols, uint16(symbol))
+			}
+		}
+	}
+	return d
+}
+
+func (d *decodingTable) decode(r *bitReader) (uint16, error) {
+	code := 0
+	for length := 1; length <= maxDecodeLen; length++ {
+		code = code<<1 | int(r.readBit())
+		if offset := code - d.first[length]; offset >= 0 && offset < d.count[length] {
+			return d.symbols[d.index[length]+offset], nil
+		}
+	}
+	return 0, ErrCorrupt
+}
+
+// Decompress takes one or more bzip2 streams and returns their decompressed contents
+func Decompress(content []byte) ([]byte, error) {
+	return DecompressLimit(content, 0)
+}
+
+// DecompressLimit is like Decompress but returns ErrTooLarge once the output would exceed limit bytes, a limit of 0 means no limit.
+// Streams that follow each other, as written by parallel compressors, are decompressed one after the other.
+func DecompressLimit(content []byte, limit int) ([]byte, error) {
+	return decompress(context.Background(), content, limit)
+}
+
+// decompress is DecompressLimit checking the context before every block
+func decompress(ctx context.Context, content []byte, limit int) ([]byte, error) {
+	output := make([]byte, 0, 4*len(content))
+	r := &bitReader{data: content}
+	for {
+		if len(content)-r.pos/8 < 4 || content[r.pos/8] != 'B' || content[r.pos/8+1] != 'Z' || content[r.pos/8+2] != 'h' {
+			return output, ErrCorrupt
+		}
+		level := int(content[r.pos/8+3]) - '0'
+		if level < 1 || level > 9 {
+			return output, ErrCorrupt
+		}
+		r.pos += 32
+
+		combined := uint32(0)
+		for {
+			if err := ctx.Err(); err != nil {
+				return output, err
+			}
+			magicHigh, magicLow := r.readBits(24), r.readBits(24)
+			if magicHigh == endMagicHigh && magicLow == endMagicLow {
+				crc := r.readBits(32)
+				if r.overrun {
+					return output, ErrCorrupt
+				} else if crc != combined {
+					return output, ErrChecksum
+				}
+				break
+			} else if magicHigh != blockMagicHigh || magicLow != blockMagicLow || r.overrun {
+				return output, ErrCorrupt
+			}
+			crc := r.readBits(32)
+			start := len(output)
+			var err error
+			if output, err = decodeBlock(r, output, level*100000, limit); err != nil {
+				return output, err
+			}
+			if blockCRC(output[start:]) != crc {
+				return output, ErrChecksum
+			}
+			combined = combineCRC(combined, crc)
+		}
+
+		// Streams are padded to a whole byte
+		r.pos = (r.pos + 7) / 8 * 8
+		if r.pos/8 == len(content) {
+			return output, nil
+		}
+	}
+}
+
+// decodeBlock decodes the block after its CRC and appends its contents to output.
+// Blocks can't be larger than maxBlock before the run length encoding is undone.
+func decodeBlock(r *bitReader, output []byte, maxBlock int, limit int) ([]byte, error) {
+	if r.readBit() != 0 {
+		return output, ErrRandomized
+	}
+	origPtr := int(r.readBits(24))
+
+	var toByte []byte
+	ranges := r.readBits(16)
+	for i := 0; i < 16; i++ {
+		if ranges&(0x8000>>uint(i)) == 0 {
+			continue
+		}
+		bitmap := r.readBits(16)
+		for j := 0; j < 16; j++ {
+			if bitmap&(0x8000>>uint(j)) != 0 {
+				toByte = append(toByte, byte(i*16+j))
+			}
+		}
+	}
+	if len(toByte) == 0 {
+		return output, ErrCorrupt
+	}
+	alphaSize := len(toByte) + 2
+	endOfBlock := uint16(len(toByte) + 1)
+
+	tableCount := int(r.readBits(3))
+	selectorCount := int(r.readBits(15))
+	if tableCount < 2 || tableCount > maxTables || selectorCount == 0 || r.overrun {
+		return output, ErrCorrupt
+	}
+	selectors := make([]int, selectorCount)
+	order := []int{0, 1, 2, 3, 4, 5}
+	for i := range selectors {
+		j := 0
+		for r.readBit() == 1 {
+			j++
+			if j >= tableCount || r.overrun {
+				return output, ErrCorrupt
+			}
+		}
+		selector := order[j]
+		copy(order[1:j+1], order[:j])
+		order[0] = selector
+		selectors[i] = selector
+	}
+
+	tables := make([]*decodingTable, tableCount)
+	lengths := make([]int, alphaSize)
+	for t := range tables {
+		current := int(r.readBits(5))
+		for symbol := range lengths {
+			for {
+				if current < 1 || current > maxDecodeLen || r.overrun {
+					return output, ErrCorrupt
+				}
+				if r.readBit() == 0 {
+					break
+				}
+				if r.readBit() == 0 {
+					current++
+				} else {
+					current--
+				}
+			}
+			lengths[symbol] = current
+		}
+		tables[t] = newDecodingTable(lengths)
+	}
+
+	// Undo the Huffman coding, the runs of zeros and the move-to-front coding to get back the Burrows-Wheeler transform
+	last := make([]byte, 0, maxBlock)
+	list := make([]byte, 256)
+	for i := range list {
+		list[i] = byte(i)
+	}
+	run, weight := 0, 1
+	for i := 0; ; i++ {
+		if i/groupSize >= len(selectors) {
+			return output, ErrCorrupt
+		}
+		symbol, err := tables[selectors[i/groupSize]].decode(r)
+		if err != nil || r.overrun {
+			return output, ErrCorrupt
+		}
+		if symbol == runA || symbol == runB {
+			run += weight << symbol
+			weight <<= 1
+			if run > maxBlock {
+				return output, ErrCorrupt
+			}
+			continue
+		}
+		if run > 0 {
+			if len(last)+run > maxBlock {
+				return output, ErrCorrupt
+			}
+			b := toByte[list[0]]
+			for k := 0; k < run; k++ {
+				last = append(last, b)
+			}
+			run, weight = 0, 1
+		}
+		if symbol == endOfBlock {
+			break
+		}
+		if len(last) >= maxBlock {
+			return output, ErrCorrupt
+		}
+		j := int(symbol) - 1
+		seq := list[j]
+		copy(list[1:j+1], list[:j])
+		list[0] = seq
+		last = append(last, toByte[seq])
+	}
+	if origPtr >= len(last) {
+		return output, ErrCorrupt
+	}
+
+	return runLengthDecode(output, inverseBWT(last, origPtr), limit)
+}
+
+// runLengthDecode appends block to output, expanding every 4 identical bytes followed by a count back into a run
+func runLengthDecode(output []byte, block []byte, limit int) ([]byte, error) {
+	repeats := 0
+	previous := -1
+	for i := 0; i < len(block); i++ {
+		b := block[i]
+		if repeats == 4 {
+			if limit > 0 && len(output)+int(b) > limit {
+				return output, ErrTooLarge
+			}
+			for k := 0; k < int(b); k++ {
+				output = append(output, byte(previous))
+			}
+			repeats, previous = 0, -1
+			continue
+		}
+		if limit > 0 && len(output) >= limit {
+			return output, ErrTooLarge
+		}
+		output = append(output, b)
+		if int(b) == previous {
+			repeats++
+		} else {
+			repeats, previous = 1, int(b)
+		}
+	}
+	return output, nil
+}
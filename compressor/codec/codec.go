@@ -0,0 +1,11 @@
+// Package codec holds the options taken by the NewWriterContext and NewReaderContext constructors of raisin's codecs.
+package codec
+
+// Options are the options a codec's writer or reader is created with, the zero value compresses and decompresses without either.
+type Options struct {
+	// Limit is the most bytes a reader decompresses before failing with its codec's ErrTooLarge, 0 means no limit. Writers ignore it.
+	Limit int
+	// Dict is a preset dictionary, a stream compressed with one can only be decompressed with the same one.
+	// Codecs that can't use a dictionary ignore it.
+	Dict []byte
+}
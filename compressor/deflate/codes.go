@@ -0,0 +1,207 @@
+package deflate
+
+import (
+	huffman "github.com/go-compression/raisin/compressor/huffman"
+)
+
+// codeLengthOrder is the order the lengths of the code length codes are stored in, most likely to be used first.
+var codeLengthOrder = [...]int{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+
+const maxCodeLengthCodeLength = 7
+
+// fixedLengths returns the code lengths of the fixed Huffman codes defined by RFC 1951.
+func fixedLengths() ([]int, []int) {
+	lit := make([]int, 288)
+	for symbol := range lit {
+		switch {
+		case symbol < 144:
+			lit[symbol] = 8
+		case symbol < 256:
+			lit[symbol] = 9
+		case symbol < 280:
+			lit[symbol] = 7
+		default:
+			lit[symbol] = 8
+		}
+	}
+	dist := make([]int, maxDistCodes)
+	for symbol := range dist {
+		dist[symbol] = 5
+	}
+	return lit, dist
+}
+
+// codeLengths returns the length of the code of every symbol with the given frequencies, none longer than maxLength.
+// At least two symbols are always given a code since some decoders reject a code with just one.
+func codeLengths(freqs []int, maxLength int) []int {
+	padded := append([]int{}, freqs...)
+	used := 0
+	for _, freq := range padded {
+		if freq > 0 {
+			used++
+		}
+	}
+	for symbol := 0; used < 2 && symbol < len(padded); symbol++ {
+		if padded[symbol] == 0 {
+			padded[symbol] = 1
+			used++
+		}
+	}
+	return huffman.LimitedCodeLengths(padded, maxLength)
+}
+
+// canonicalCodes assigns the canonical Huffman code of RFC 1951 to every symbol with a non-zero length.
+// The codes are returned bit reversed since Huffman codes are packed starting from their most significant bit.
+func canonicalCodes(lengths []int) []uint32 {
+	var count [maxCodeLength + 1]int
+	for _, length := range lengths {
+		if length > 0 {
+			count[length]++
+		}
+	}
+	var next [maxCodeLength + 1]uint32
+	code := uint32(0)
+	for length := 1; length <= maxCodeLength; length++ {
+		code = (code + uint32(count[length-1])) << 1
+		next[length] = code
+	}
+	codes := make([]uint32, len(lengths))
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		codes[symbol] = reverse(next[length], uint(length))
+		next[length]++
+	}
+	return codes
+}
+
+func reverse(code uint32, length uint) uint32 {
+	var reversed uint32
+	for i := uint(0); i < length; i++ {
+		reversed = reversed<<1 | code&1
+		code >>= 1
+	}
+	return reversed
+}
+
+// codeLengthSymbol represents one symbol of the run length encoded code lengths in a dynamic block header,
+// 0 to 15 are literal lengths while 16, 17 and 18 repeat with extra bits holding the run length.
+type codeLengthSymbol struct {
+	symbol    int
+	extra     uint32
+	extraBits uint
+}
+
+// dynamicHeader represents the code lengths of a dynamic Huffman block and how they are stored in its header.
+type dynamicHeader struct {
+	litLengths  []int
+	distLengths []int
+	hlit, hdist int
+	hclen       int
+	symbols     []codeLengthSymbol
+	clLengths   []int
+}
+
+func newDynamicHeader(litFreqs []int, distFreqs []int) dynamicHeader {
+	h := dynamicHeader{}
+	h.litLengths = codeLengths(litFreqs, maxCodeLength)
+	h.distLengths = codeLengths(distFreqs, maxCodeLength)
+
+	h.hlit = 257
+	for symbol := range h.litLengths {
+		if h.litLengths[symbol] > 0 && symbol+1 > h.hlit {
+			h.hlit = symbol + 1
+		}
+	}
+	h.hdist = 1
+	for symbol := range h.distLengths {
+		if h.distLengths[symbol] > 0 && symbol+1 > h.hdist {
+			h.hdist = symbol + 1
+		}
+	}
+
+	// The literal/length and distance code lengths are run length encoded as a single sequence
+	lengths := append(append([]int{}, h.litLengths[:h.hlit]...), h.distLengths[:h.hdist]...)
+	h.symbols = runLengthEncode(lengths)
+
+	clFreqs := make([]int, len(codeLengthOrder))
+	for _, s := range h.symbols {
+		clFreqs[s.symbol]++
+	}
+	h.clLengths = codeLengths(clFreqs, maxCodeLengthCodeLength)
+	h.hclen = 4
+	for i, symbol := range codeLengthOrder {
+		if h.clLengths[symbol] > 0 && i+1 > h.hclen {
+			h.hclen = i + 1
+		}
+	}
+	return h
+}
+
+// runLengthEncode encodes a sequence of code lengths with the repeat symbols 16 (previous length 3-6 times),
+// 17 (zero 3-10 times) and 18 (zero 11-138 times).
+func runLengthEncode(lengths []int) []codeLengthSymbol {
+	var symbols []codeLengthSymbol
+	for i := 0; i < len(lengths); {
+		length := lengths[i]
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == length {
+			run++
+		}
+		i += run
+
+		if length == 0 {
+			for run >= 11 {
+				n := run
+				if n > 138 {
+					n = 138
+				}
+				symbols = append(symbols, codeLengthSymbol{18, uint32(n - 11), 7})
+				run -= n
+			}
+			if run >= 3 {
+				symbols = append(symbols, codeLengthSymbol{17, uint32(run - 3), 3})
+				run = 0
+			}
+		} else {
+			symbols = append(symbols, codeLengthSymbol{symbol: length})
+			run--
+			for run >= 3 {
+				n := run
+				if n > 6 {
+					n = 6
+				}
+				symbols = append(symbols, codeLengthSymbol{16, uint32(n - 3), 2})
+				run -= n
+			}
+		}
+		for ; run > 0; run-- {
+			symbols = append(symbols, codeLengthSymbol{symbol: length})
+		}
+	}
+	return symbols
+}
+
+// cost returns the size of the header in bits, not counting the 3 bit block header.
+func (h dynamicHeader) cost() int {
+	cost := 5 + 5 + 4 + 3*h.hclen
+	for _, s := range h.symbols {
+		cost += h.clLengths[s.symbol] + int(s.extraBits)
+	}
+	return cost
+}
+
+func (h dynamicHeader) write(w *bitWriter) {
+	w.writeBits(uint32(h.hlit-257), 5)
+	w.writeBits(uint32(h.hdist-1), 5)
+	w.writeBits(uint32(h.hclen-4), 4)
+	for _, symbol := range codeLengthOrder[:h.hclen] {
+		w.writeBits(uint32(h.clLengths[symbol]), 3)
+	}
+	codes := canonicalCodes(h.clLengths)
+	for _, s := range h.symbols {
+		w.writeBits(codes[s.symbol], uint(h.clLengths[s.symbol]))
+		w.writeBits(s.extra, s.extraBits)
+	}
+}
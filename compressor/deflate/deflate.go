@@ -0,0 +1,315 @@
+// Package deflate implements an RFC 1951 DEFLATE encoder out of raisin's own LZ77 match finder and Huffman tree builder.
+// Its output is decoded by the standard library's compress/flate, which keeps it interoperable with gzip, zlib and zip.
+package deflate
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	codec "github.com/go-compression/raisin/compressor/codec"
+	"io"
+	"io/ioutil"
+
+	lz "github.com/go-compression/raisin/compressor/lz"
+)
+
+// Block types as stored in the BTYPE field of a block header, along with BestBlock which picks whichever is smallest for every block.
+const (
+	BestBlock    = -1
+	StoredBlock  = 0
+	FixedBlock   = 1
+	DynamicBlock = 2
+)
+
+// Settings represents an object that can be used to modify how Compress encodes a stream
+// BlockType forces every block to one type instead of the smallest, BlockSize is how many tokens go in each block
+// and Parse controls the match finder.
+type Settings struct {
+	BlockType int
+	BlockSize int
+	Parse     lz.ParseSettings
+}
+
+// NewSettings returns the default settings for Compress as a Settings object
+func NewSettings() Settings {
+	s := Settings{}
+	s.BlockType = BestBlock
+	s.BlockSize = 16 * 1024
+	s.Parse = lz.NewParseSettings()
+	return s
+}
+
+const (
+	endOfBlock     = 256
+	maxLitLenCodes = 286
+	maxDistCodes   = 30
+	maxCodeLength  = 15
+	maxStoredSize  = 65535
+)
+
+var lengthBase = [...]int{3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31, 35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258}
+var lengthExtra = [...]uint{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0}
+var distBase = [...]int{1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193, 257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577}
+var distExtra = [...]uint{0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13}
+
+// lengthCode returns the index into lengthBase of the code for a match length, the symbol is 257 more than it.
+func lengthCode(length int) int {
+	code := 0
+	for code+1 < len(lengthBase) && lengthBase[code+1] <= length {
+		code++
+	}
+	return code
+}
+
+// distCode returns the distance code for a match distance.
+func distCode(distance int) int {
+	code := 0
+	for code+1 < len(distBase) && distBase[code+1] <= distance {
+		code++
+	}
+	return code
+}
+
+// bitWriter packs bits into bytes starting from the least significant bit, as DEFLATE requires.
+type bitWriter struct {
+	out  []byte
+	bits uint64
+	n    uint
+}
+
+func (w *bitWriter) writeBits(value uint32, n uint) {
+	w.bits |= uint64(value) << w.n
+	w.n += n
+	for w.n >= 8 {
+		w.out = append(w.out, byte(w.bits))
+		w.bits >>= 8
+		w.n -= 8
+	}
+}
+
+// align pads the output with zero bits up to the next byte boundary.
+func (w *bitWriter) align() {
+	if w.n > 0 {
+		w.out = append(w.out, byte(w.bits))
+		w.bits = 0
+		w.n = 0
+	}
+}
+
+// Compress takes a slice of bytes and returns it encoded as a DEFLATE stream.
+// The content is parsed into literals and matches once and then split into blocks of settings.BlockSize tokens,
+// each of which is written as a stored, fixed or dynamic Huffman block.
+func Compress(content []byte, settings Settings) []byte {
+	output, _ := compress(context.Background(), content, settings)
+	return output
+}
+
+func compress(ctx context.Context, content []byte, settings Settings) ([]byte, error) {
+	tokens, err := lz.ParseContext(ctx, content, settings.Parse)
+	if err != nil {
+		return nil, err
+	}
+	blockSize := settings.BlockSize
+	if blockSize <= 0 {
+		blockSize = len(tokens) + 1
+	}
+
+	w := &bitWriter{}
+	start := 0
+	for i := 0; ; i += blockSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		end := i + blockSize
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+		size := 0
+		for _, token := range tokens[i:end] {
+			if token.Length == 0 {
+				size++
+			} else {
+				size += token.Length
+			}
+		}
+		final := end == len(tokens)
+		writeBlock(w, tokens[i:end], content[start:start+size], final, settings.BlockType)
+		start += size
+		if final {
+			break
+		}
+	}
+	w.align()
+	return w.out, nil
+}
+
+// Decompress takes a DEFLATE stream and returns the decompressed contents, using the standard library's decoder.
+func Decompress(content []byte) ([]byte, error) {
+	return ioutil.ReadAll(flate.NewReader(bytes.NewReader(content)))
+}
+
+// writeBlock writes tokens as a single block of the given type, or of whichever type is smallest for BestBlock.
+// raw is the content the tokens represent, needed for stored blocks.
+func writeBlock(w *bitWriter, tokens []lz.Token, raw []byte, final bool, blockType int) {
+	litFreqs := make([]int, maxLitLenCodes)
+	distFreqs := make([]int, maxDistCodes)
+	for _, token := range tokens {
+		if token.Length == 0 {
+			litFreqs[token.Literal]++
+		} else {
+			litFreqs[257+lengthCode(token.Length)]++
+			distFreqs[distCode(token.Distance)]++
+		}
+	}
+	litFreqs[endOfBlock]++
+
+	dynamic := newDynamicHeader(litFreqs, distFreqs)
+	if blockType == BestBlock {
+		fixedLit, fixedDist := fixedLengths()
+		costs := map[int]int{
+			StoredBlock:  storedCost(w, len(raw)),
+			FixedBlock:   3 + dataCost(litFreqs, distFreqs, fixedLit, fixedDist),
+			DynamicBlock: 3 + dynamic.cost() + dataCost(litFreqs, distFreqs, dynamic.litLengths, dynamic.distLengths),
+		}
+		// Ties go to the simplest block
+		blockType = StoredBlock
+		for _, t := range []int{FixedBlock, DynamicBlock} {
+			if costs[t] < costs[blockType] {
+				blockType = t
+			}
+		}
+	}
+
+	var finalBit uint32
+	if final {
+		finalBit = 1
+	}
+	switch blockType {
+	case StoredBlock:
+		writeStored(w, raw, finalBit)
+	case FixedBlock:
+		w.writeBits(finalBit, 1)
+		w.writeBits(FixedBlock, 2)
+		fixedLit, fixedDist := fixedLengths()
+		writeTokens(w, tokens, canonicalCodes(fixedLit), fixedLit, canonicalCodes(fixedDist), fixedDist)
+	default:
+		w.writeBits(finalBit, 1)
+		w.writeBits(DynamicBlock, 2)
+		dynamic.write(w)
+		writeTokens(w, tokens, canonicalCodes(dynamic.litLengths), dynamic.litLengths, canonicalCodes(dynamic.distLengths), dynamic.distLengths)
+	}
+}
+
+// writeStored writes raw as one or more stored blocks, only the last of which carries the final bit.
+func writeStored(w *bitWriter, raw []byte, finalBit uint32) {
+	for {
+		chunk := raw
+		if len(chunk) > maxStoredSize {
+			chunk = chunk[:maxStoredSize]
+		}
+		raw = raw[len(chunk):]
+		if len(raw) > 0 {
+			w.writeBits(0, 1)
+		} else {
+			w.writeBits(finalBit, 1)
+		}
+		w.writeBits(StoredBlock, 2)
+		w.align()
+		w.writeBits(uint32(len(chunk)), 16)
+		w.writeBits(uint32(^uint16(len(chunk))), 16)
+		w.out = append(w.out, chunk...)
+		if len(raw) == 0 {
+			return
+		}
+	}
+}
+
+// storedCost returns the size in bits of raw written as stored blocks from the current position of w.
+func storedCost(w *bitWriter, size int) int {
+	// The first header is padded to a byte from wherever the writer is, every later one sits on a byte boundary
+	cost := 3 + int((8-(w.n+3)%8)%8) + 32 + 8*size
+	for size > maxStoredSize {
+		size -= maxStoredSize
+		cost += 40
+	}
+	return cost
+}
+
+// dataCost returns the size in bits of the symbols and extra bits of a block coded with the given code lengths.
+func dataCost(litFreqs []int, distFreqs []int, litLengths []int, distLengths []int) int {
+	cost := 0
+	for symbol, freq := range litFreqs {
+		cost += freq * litLengths[symbol]
+		if symbol > endOfBlock {
+			cost += freq * int(lengthExtra[symbol-257])
+		}
+	}
+	for symbol, freq := range distFreqs {
+		cost += freq * (distLengths[symbol] + int(distExtra[symbol]))
+	}
+	return cost
+}
+
+func writeTokens(w *bitWriter, tokens []lz.Token, litCodes []uint32, litLengths []int, distCodes []uint32, distLengths []int) {
+	for _, token := range tokens {
+		if token.Length == 0 {
+			w.writeBits(litCodes[token.Literal], uint(litLengths[token.Literal]))
+			continue
+		}
+		code := lengthCode(token.Length)
+		w.writeBits(litCodes[257+code], uint(litLengths[257+code]))
+		w.writeBits(uint32(token.Length-lengthBase[code]), lengthExtra[code])
+		code = distCode(token.Distance)
+		w.writeBits(distCodes[code], uint(distLengths[code]))
+		w.writeBits(uint32(token.Distance-distBase[code]), distExtra[code])
+	}
+	w.writeBits(litCodes[endOfBlock], uint(litLengths[endOfBlock]))
+}
+
+// Writer compresses everything written to it as one DEFLATE stream on Close
+type Writer struct {
+	w        io.Writer
+	ctx      context.Context
+	settings Settings
+	buffer   bytes.Buffer
+}
+
+// NewWriter creates an io.WriteCloser object with an io.Writer and the default settings
+func NewWriter(w io.Writer) io.WriteCloser {
+	return NewWriterSettings(w, NewSettings())
+}
+
+// NewWriterSettings creates an io.WriteCloser object with an io.Writer that compresses with the given settings
+func NewWriterSettings(w io.Writer, settings Settings) io.WriteCloser {
+	z := new(Writer)
+	z.w = w
+	z.ctx = context.Background()
+	z.settings = settings
+	return z
+}
+
+// NewWriterContext creates an io.WriteCloser like NewWriter that gives up once ctx is done
+func NewWriterContext(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
+	z := NewWriterSettings(w, NewSettings()).(*Writer)
+	z.ctx = ctx
+	return z
+}
+
+func (writer *Writer) Write(data []byte) (n int, err error) {
+	return writer.buffer.Write(data)
+}
+
+// Close compresses everything written so far and writes out the stream
+func (writer *Writer) Close() error {
+	compressed, err := compress(writer.ctx, writer.buffer.Bytes(), writer.settings)
+	if err != nil {
+		return err
+	}
+	_, err = writer.w.Write(compressed)
+	return err
+}
+
+// NewReader creates an io.Reader object that decompresses a DEFLATE stream from an io.Reader with the standard library's decoder
+func NewReader(r io.Reader) io.Reader {
+	return flate.NewReader(r)
+}
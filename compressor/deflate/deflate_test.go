@@ -0,0 +1,84 @@
+package deflate
+
+import (
+	"bytes"
+	"compress/flate"
+	"github.com/go-compression/raisin/internal/codectest"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+func testInputs() map[string][]byte {
+	inputs := codectest.Inputs(100000)
+	r := rand.New(rand.NewSource(1))
+	skewed := make([]byte, 100000)
+	for i := range skewed {
+		// Mostly a few symbols with the occasional rare one, which makes for a deep Huffman tree
+		skewed[i] = byte(r.ExpFloat64() * 4)
+	}
+	inputs["skewed"] = skewed
+	return inputs
+}
+
+func TestCompressStandardLibrary(t *testing.T) {
+	for name, content := range testInputs() {
+		for _, blockType := range []int{BestBlock, StoredBlock, FixedBlock, DynamicBlock} {
+			settings := NewSettings()
+			settings.BlockType = blockType
+			compressed := Compress(content, settings)
+			decompressed, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+			if err != nil {
+				t.Errorf("%s with block type %d could not be decoded by compress/flate: %v", name, blockType, err)
+			} else if !bytes.Equal(decompressed, content) {
+				t.Errorf("%s with block type %d was not lossless", name, blockType)
+			}
+		}
+	}
+}
+
+func TestCompressRatio(t *testing.T) {
+	// The best block type should never do worse than storing the input, and be close to the standard library on text
+	for name, content := range testInputs() {
+		settings := NewSettings()
+		compressed := Compress(content, settings)
+		// Every block and every 64 KiB within a stored block has a 5 byte header
+		blocks := len(content)/settings.BlockSize + len(content)/maxStoredSize + 1
+		if len(compressed) > len(content)+5*blocks {
+			t.Errorf("%s compressed to %d bytes, more than stored blocks of %d bytes", name, len(compressed), len(content))
+		}
+	}
+	text := testInputs()["text"]
+	var standard bytes.Buffer
+	w, _ := flate.NewWriter(&standard, flate.DefaultCompression)
+	w.Write(text)
+	w.Close()
+	if compressed := Compress(text, NewSettings()); len(compressed) > 2*standard.Len() {
+		t.Errorf("Compressed text to %d bytes, compress/flate managed %d", len(compressed), standard.Len())
+	}
+}
+
+func TestCodeLengthsLimit(t *testing.T) {
+	// Fibonacci frequencies give the deepest possible Huffman tree
+	freqs := make([]int, 30)
+	a, b := 1, 1
+	for i := range freqs {
+		freqs[i] = a
+		a, b = b, a+b
+	}
+	lengths := codeLengths(freqs, maxCodeLength)
+	kraft := 0.0
+	for _, length := range lengths {
+		if length < 1 || length > maxCodeLength {
+			t.Fatalf("Code length %d outside 1 to %d", length, maxCodeLength)
+		}
+		kraft += 1 / float64(uint(1)<<uint(length))
+	}
+	if kraft > 1 {
+		t.Errorf("Code lengths %v are not a valid prefix code", lengths)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	codectest.Writer(t, testInputs()["text"], 30000, NewWriter, NewReader)
+}
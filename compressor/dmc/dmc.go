@@ -1,7 +1,9 @@
 package dmc
 
 import (
+	"context"
 	"fmt"
+	codec "github.com/go-compression/raisin/compressor/codec"
 	"strings"
 	// "errors"
 	// "bitbucket.org/sheran_gunasekera/leb128"
@@ -11,7 +13,6 @@ import (
 	// "unsafe"
 	"io"
 	"io/ioutil"
-	"runtime"
 )
 
 type MarkovChain struct {
@@ -30,13 +31,22 @@ func buildMarkovChainMoveUp(moveUp int) MarkovChain {
 }
 
 func Compress(fileContents []byte) []byte {
-	var m1, m2 runtime.MemStats
+	compressed, _ := compress(context.Background(), fileContents)
+	return compressed
+}
+
+// checkInterval is how many bytes are modelled between checks of whether the context is done
+const checkInterval = 4096
+
+func compress(ctx context.Context, fileContents []byte) ([]byte, error) {
 	var nodes []MarkovChain
-	runtime.ReadMemStats(&m1)
 	chain := MarkovChain{Nodes: &nodes}
 
 	stack := []MarkovChain{chain}
-	for _, fileByte := range fileContents {
+	for i, fileByte := range fileContents {
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		// print(string(fileByte))
 		valueUpStack := FindValueUpStack(fileByte, stack)
 		if valueUpStack != -1 {
@@ -65,15 +75,15 @@ func Compress(fileContents []byte) []byte {
 			}
 		}
 	}
-	runtime.ReadMemStats(&m2)
-	memUsage(&m1, &m2)
-
 	SortNodesByOccurrences(&chain)
 	// PrintMarkovChain(&chain, 0)
 	// fmt.Println("Total upward travels:", UpwardTravels(&chain))
 	// fmt.Println("Compiling into bits")
 
-	bits := GetBitsFromChain(&chain, fileContents, &[]MarkovChain{})
+	bits := getBitsFromChain(ctx, &chain, fileContents, &[]MarkovChain{})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	// fmt.Println("Length of bits:", len(bits))
 
 	encodeBits := new(bytes.Buffer)
@@ -88,16 +98,18 @@ func Compress(fileContents []byte) []byte {
 	// fmt.Println("Lossless markov:", string(decoded) == string(fileContents))
 	// fmt.Println("Bytes of chain:", unsafe.Sizeof(chain))
 
-	return encodeBits.Bytes()
+	return encodeBits.Bytes(), nil
 }
 
-func memUsage(m1, m2 *runtime.MemStats) {
-	fmt.Println("Alloc:", m2.Alloc-m1.Alloc,
-		"TotalAlloc:", m2.TotalAlloc-m1.TotalAlloc,
-		"HeapAlloc:", m2.HeapAlloc-m1.HeapAlloc)
+func GetBitsFromChain(node *MarkovChain, input []byte, stack *[]MarkovChain) []int {
+	return getBitsFromChain(context.Background(), node, input, stack)
 }
 
-func GetBitsFromChain(node *MarkovChain, input []byte, stack *[]MarkovChain) []int {
+// getBitsFromChain is GetBitsFromChain but stops early once ctx is done, leaving the caller to check ctx
+func getBitsFromChain(ctx context.Context, node *MarkovChain, input []byte, stack *[]MarkovChain) []int {
+	if len(input)%checkInterval == 0 && ctx.Err() != nil {
+		return []int{-2, 0}
+	}
 	if len(input) > 0 {
 		newStack := append(*stack, *node)
 		val := input[0]
@@ -133,13 +145,17 @@ func GetBitsFromChain(node *MarkovChain, input []byte, stack *[]MarkovChain) []i
 		}
 
 		if transition == -1 {
-			bitsFromChain := GetBitsFromChain(lookInNode, input[1:], &newStack)
+			bitsFromChain := getBitsFromChain(ctx, lookInNode, input[1:], &newStack)
 			if len(bitsFromChain) > 1 && bitsFromChain[len(bitsFromChain)-2] == -2 {
 				bitsFromChain[len(bitsFromChain)-1]++
 			}
 			return bitsFromChain
 		}
-		bitsFromChain := GetBitsFromChain(lookInNode, input[1:], &newStack)
+		bitsFromChain := getBitsFromChain(ctx, lookInNode, input[1:], &newStack)
+		if ctx.Err() != nil {
+			// Skip copying the bits on the way back up, they're thrown away
+			return bitsFromChain
+		}
 		if len(bitsFromChain) > 1 && bitsFromChain[len(bitsFromChain)-2] == -2 {
 			bitsFromChain[len(bitsFromChain)-2] = -1
 		}
@@ -292,17 +308,27 @@ func check(e error) {
 }
 
 type Writer struct {
-	w io.Writer
+	w   io.Writer
+	ctx context.Context
 }
 
 func NewWriter(w io.Writer) io.WriteCloser {
+	return NewWriterContext(context.Background(), w, codec.Options{})
+}
+
+// NewWriterContext creates an io.WriteCloser like NewWriter that gives up once ctx is done
+func NewWriterContext(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
 	z := new(Writer)
 	z.w = w
+	z.ctx = ctx
 	return z
 }
 
 func (writer *Writer) Write(data []byte) (n int, err error) {
-	compressed := Compress(data)
+	compressed, err := compress(writer.ctx, data)
+	if err != nil {
+		return 0, err
+	}
 	writer.w.Write(compressed)
 	return len(compressed), nil
 }
@@ -2,13 +2,17 @@ package huffman
 
 import (
 	"container/heap"
+	"context"
+	"errors"
 	"fmt"
+	codec "github.com/go-compression/raisin/compressor/codec"
 	"io"
 	"io/ioutil"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type HuffmanTree interface {
@@ -53,8 +57,6 @@ func (th *treeHeap) Pop() (popped interface{}) {
 }
 func (th treeHeap) Swap(i, j int) { th[i], th[j] = th[j], th[i] }
 
-var estring strings.Builder
-
 func buildTree(symFreqs map[rune]int) HuffmanTree {
 	//fmt.Println("building tree")
 	type sorter struct {
@@ -99,7 +101,12 @@ func buildTree(symFreqs map[rune]int) HuffmanTree {
 
 		heap.Push(&trees, HuffmanNode{a.Freq() + b.Freq(), a, b})
 	}
-	return heap.Pop(&trees).(HuffmanTree)
+	root := heap.Pop(&trees).(HuffmanTree)
+	if leaf, ok := root.(HuffmanLeaf); ok {
+		// A single symbol would get an empty code, so give the tree a second branch to encode it with a single bit
+		return HuffmanNode{leaf.freq, leaf, leaf}
+	}
+	return root
 }
 
 func check(e error) {
@@ -126,30 +133,44 @@ func printCodes(tree HuffmanTree, prefix []byte, vals []rune, bin []string) ([]r
 	return vals, bin
 }
 
-var answer strings.Builder
-
-func findCodes(tree HuffmanTree, og HuffmanTree, data string, i int, max int) string {
-	if i > 900000 {
-		panic("Max recursion depth")
-	}
-	if i <= max {
-		switch huff := tree.(type) {
-		case HuffmanLeaf:
-			fmt.Fprintf(&answer, "%s", string(huff.value))
-			if i < max {
-				return findCodes(og, og, data, i, max)
-			} else {
-				return answer.String()
+// decodeBits walks tree for every bit of data, which is a string of '0' and '1' characters, and returns the symbols of the leaves it reaches.
+// It returns ErrTooLarge once the output would exceed limit bytes and ErrCorrupt if data ends part way through a code.
+// Symbols are written as UTF-8 when runes is set and as single bytes otherwise.
+func decodeBits(ctx context.Context, tree HuffmanTree, data string, limit int, runes bool) ([]byte, error) {
+	var output []byte
+	node := tree
+	inCode := false
+	for i := 0; i < len(data); i++ {
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return output, ctx.Err()
+		}
+		branch, ok := node.(HuffmanNode)
+		if !ok {
+			return output, ErrCorrupt
+		}
+		if data[i] == '0' {
+			node = branch.left
+		} else {
+			node = branch.right
+		}
+		inCode = true
+		if leaf, ok := node.(HuffmanLeaf); ok {
+			symbol := []byte{byte(leaf.value)}
+			if runes {
+				symbol = []byte(string(leaf.value))
 			}
-		case HuffmanNode:
-			if string(data[i]) == "0" {
-				return findCodes(huff.left, og, string(data), i+1, max)
-			} else if string(data[i]) == "1" {
-				return findCodes(huff.right, og, string(data), i+1, max)
+			if limit > 0 && len(output)+len(symbol) > limit {
+				return output, ErrTooLarge
 			}
+			output = append(output, symbol...)
+			node = tree
+			inCode = false
 		}
 	}
-	return answer.String()
+	if inCode {
+		return output, ErrCorrupt
+	}
+	return output, nil
 }
 
 func indexOf(word rune, data []rune) int {
@@ -194,6 +215,11 @@ var decodedTree HuffmanTree
 var treeH treeHeap
 
 func decodeTree(tree string) HuffmanTree {
+	return buildTree(decodeFrequencies(tree, false))
+}
+
+// decodeFrequencies reads a frequency table, whose symbols are UTF-8 runes when runes is set and single bytes otherwise.
+func decodeFrequencies(tree string, runes bool) map[rune]int {
 	symFreqs := make(map[rune]int)
 	var temp strings.Builder
 	var freq int
@@ -207,37 +233,55 @@ func decodeTree(tree string) HuffmanTree {
 			freq, _ = strconv.Atoi(strings.TrimSpace(temp.String()))
 
 			temp.Reset()
-			if string(tree[i+1]) == "\\" && string(tree[i+2]) == "n" {
+			if i+2 < len(tree) && string(tree[i+1]) == "\\" && string(tree[i+2]) == "n" {
 				symFreqs[10] = freq
 				i++
-			} else {
-				for j, c := range tree {
-					if j == i+1 {
-
-						symFreqs[c] = freq
-						break
-					}
-				}
+			} else if runes && i+1 < len(tree) {
+				symbol, _ := utf8.DecodeRuneInString(tree[i+1:])
+				symFreqs[symbol] = freq
+			} else if i+1 < len(tree) {
+				symFreqs[rune(tree[i+1])] = freq
 			}
 			i++
 		}
 	}
 	//fmt.Print(symFreqs)
-	return buildTree(symFreqs)
+	return symFreqs
 }
 
-func encode(tree HuffmanTree, input string) []byte {
+// encodeTable writes the frequency of every byte as its count, a '|' and the byte itself, with a newline written as \n
+// so that the table never holds the "\\\n" separating it from the codes. Bytes are written in order so the table is the same every time.
+func encodeTable(symFreqs map[rune]int) string {
+	var table strings.Builder
+	for symbol := 0; symbol < 256; symbol++ {
+		freq, ok := symFreqs[rune(symbol)]
+		if !ok {
+			continue
+		}
+		if symbol != 10 {
+			fmt.Fprintf(&table, "%s|%s", strconv.Itoa(freq), string([]byte{byte(symbol)}))
+		} else {
+			fmt.Fprintf(&table, "%s|\\n", strconv.Itoa(freq))
+		}
+	}
+	return table.String()
+}
+
+func encode(ctx context.Context, tree HuffmanTree, table string, input []byte) ([]byte, error) {
 	//fmt.Println("encoding")
 	var answer strings.Builder
 	tempV := make([]rune, 0)
 	tempB := make([]string, 0)
 	vals, bin := printCodes(tree, []byte{}, tempV, tempB)
-	for _, c := range input {
-		if indexOf(c, vals) != -1 {
-			fmt.Fprintf(&answer, "%s", bin[indexOf(c, vals)])
-		} else {
-			fmt.Fprintf(&answer, "%s", bin[0])
+	var codes [256]string
+	for i, val := range vals {
+		codes[val] = bin[i]
+	}
+	for i, c := range input {
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
+		answer.WriteString(codes[c])
 	}
 
 	//Println(len(answer))
@@ -252,112 +296,181 @@ func encode(tree HuffmanTree, input string) []byte {
 	final := bits.AsByteSlice()
 	test := append(first, final...)
 
-	return append([]byte(estring.String()), append([]byte("\\\n"), test...)...)
+	return append([]byte(table), append([]byte("\\\n"), test...)...), nil
 }
 
-func decode(fileContents []byte) []byte {
-	//fmt.Println("decoding")
-	file_content := string(fileContents)
-	sections := strings.SplitN(file_content, "\\\n", 2)
-	tree := decodeTree(sections[0])
+var (
+	// ErrCorrupt is returned when decompressing a stream with a missing or invalid code table or truncated codes
+	ErrCorrupt = errors.New("huffman: corrupt input")
+	// ErrTooLarge is returned when a stream decompresses to more than the limit
+	ErrTooLarge = errors.New("huffman: decompressed size exceeds limit")
+)
+
+// checkInterval is how many symbols are coded between checks of whether the context is done
+const checkInterval = 4096
 
+func decode(ctx context.Context, fileContents []byte, limit int, runes bool) ([]byte, error) {
+	sections := strings.SplitN(string(fileContents), "\\\n", 2)
+	if len(sections) != 2 {
+		return nil, ErrCorrupt
+	}
+	if len(sections[0]) == 0 {
+		// An empty input has an empty frequency table and nothing after it
+		if len(sections[1]) != 0 {
+			return nil, ErrCorrupt
+		}
+		return []byte{}, nil
+	}
+	symFreqs := decodeFrequencies(sections[0], runes)
+	if len(symFreqs) == 0 || len(sections[1]) == 0 {
+		return nil, ErrCorrupt
+	}
+	tree := buildTree(symFreqs)
+
+	// The first byte is the number of padding bits at the start of the codes
 	byteArr := []byte(sections[1])
-	content := make([]string, 0)
+	diff := int(byteArr[0])
 	var contentString strings.Builder
-	var diff int64
-	var err error
-	for i, n := range byteArr {
-		if i != 0 {
-			hold := fmt.Sprintf("%08b", n)
-			content = append(content, hold)
-			fmt.Fprintf(&contentString, "%s", hold)
-		} else {
-			hold := fmt.Sprintf("%08b", n)
-			diff, err = strconv.ParseInt(hold, 2, 64)
-			check(err)
+	for i, n := range byteArr[1:] {
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
+		fmt.Fprintf(&contentString, "%08b", n)
 	}
-
-	// tempV := make([]rune, 0)
-	// tempB := make([]string, 0)
-	// vals, bin := printCodes(tree, []byte{}, tempV, tempB)
-
-	// var answerBuilder strings.Builder
-	// var bitSequence string
-	// for _, bit := range contentString.String()[int(diff):] {
-	// 	bitSequence += string(bit)
-	// 	if i := indexOfString(bitSequence, bin); i != -1 {
-	// 		fmt.Fprintf(&answerBuilder, "%s", string(vals[i]))
-	// 		bitSequence = ""
-	// 	}
-	// }
-	answer := findCodes(tree, tree, contentString.String()[int(diff):], 0, len(contentString.String()[int(diff):]))
-	// return []byte(answerBuilder.String())
-	return []byte(answer)
+	if diff > 7 || diff > contentString.Len() {
+		return nil, ErrCorrupt
+	}
+	return decodeBits(ctx, tree, contentString.String()[diff:], limit, runes)
 }
 
 func Compress(fileContents []byte) []byte {
-	estring.Reset()
-	answer.Reset()
+	compressed, _ := compress(context.Background(), fileContents)
+	return compressed
+}
+
+func compress(ctx context.Context, fileContents []byte) ([]byte, error) {
 	newTree := new(HuffmanTree)
 	decodedTree = *newTree
 	newHeap := new(treeHeap)
 	treeH = *newHeap
-	content := string(fileContents)
 	symFreqs := make(map[rune]int)
 
-	for _, c := range content {
-		symFreqs[c]++
+	// Symbols are bytes rather than runes so that content that isn't valid UTF-8 survives
+	for _, c := range fileContents {
+		symFreqs[rune(c)]++
 	}
-	for key, val := range symFreqs {
-		if key != 10 {
-			fmt.Fprintf(&estring, "%s|%s", strconv.Itoa(val), string(key))
-		} else {
-			fmt.Fprintf(&estring, "%s|\\n", strconv.Itoa(val))
-		}
+	if len(symFreqs) == 0 {
+		return []byte("\\\n"), nil
 	}
-	//fmt.Println(estring.String())
 	exampleTree := buildTree(symFreqs)
 
-	out := encode(exampleTree, content)
-
-	return out
+	return encode(ctx, exampleTree, encodeTable(symFreqs), fileContents)
 }
 
+// Decompress takes a slice of bytes and returns the decompressed contents
+//
+// Deprecated: a corrupt stream gives back whatever decoded before the fault and no error, use DecompressLimit instead.
 func Decompress(fileContents []byte) []byte {
-	decoded := decode(fileContents)
+	decoded, _ := decode(context.Background(), fileContents, 0, false)
 	return decoded
 }
 
+// DecompressLimit is like Decompress but returns ErrCorrupt for malformed streams and ErrTooLarge once the output would exceed limit bytes.
+// A limit of 0 means no limit.
+func DecompressLimit(fileContents []byte, limit int) ([]byte, error) {
+	return decode(context.Background(), fileContents, limit, false)
+}
+
+// DecompressRunes is like DecompressLimit for streams written by older versions, which coded UTF-8 runes rather than bytes.
+func DecompressRunes(fileContents []byte, limit int) ([]byte, error) {
+	return decode(context.Background(), fileContents, limit, true)
+}
+
+// CodeTable reads the frequency table stored at the start of a compressed stream.
+// It returns the frequency and the generated code for every symbol along with the size of the table in bytes.
+func CodeTable(fileContents []byte) (map[rune]int, map[rune]string, int, error) {
+	sections := strings.SplitN(string(fileContents), "\\\n", 2)
+	if len(sections) != 2 {
+		return nil, nil, 0, errors.New("huffman: missing code table separator")
+	}
+	symFreqs := decodeFrequencies(sections[0], false)
+	codes := make(map[rune]string, len(symFreqs))
+	if len(symFreqs) > 0 {
+		vals, bin := printCodes(buildTree(symFreqs), []byte{}, make([]rune, 0), make([]string, 0))
+		for i, val := range vals {
+			codes[val] = bin[i]
+		}
+	}
+	return symFreqs, codes, len(sections[0]), nil
+}
+
+// CodeLengths builds the Huffman tree for the given symbol frequencies and returns the length of every symbol's code.
+// These are enough to rebuild canonical codes such as DEFLATE's, a lone symbol gets a code of length 1.
+func CodeLengths(symFreqs map[rune]int) map[rune]int {
+	lengths := make(map[rune]int, len(symFreqs))
+	if len(symFreqs) == 0 {
+		return lengths
+	}
+	vals, bin := printCodes(buildTree(symFreqs), []byte{}, make([]rune, 0), make([]string, 0))
+	for i, val := range vals {
+		lengths[val] = len(bin[i])
+	}
+	return lengths
+}
+
+// LimitedCodeLengths returns the code length of every symbol with the given frequencies, none longer than maxLength.
+// Symbols with a frequency of 0 get no code. If the tree is too deep the frequencies are halved until it fits,
+// which flattens the tree at a small cost to the compression ratio.
+func LimitedCodeLengths(freqs []int, maxLength int) []int {
+	symFreqs := make(map[rune]int)
+	for symbol, freq := range freqs {
+		if freq > 0 {
+			symFreqs[rune(symbol)] = freq
+		}
+	}
+	for {
+		lengths := CodeLengths(symFreqs)
+		longest := 0
+		for _, length := range lengths {
+			if length > longest {
+				longest = length
+			}
+		}
+		if longest <= maxLength {
+			result := make([]int, len(freqs))
+			for symbol, length := range lengths {
+				result[symbol] = length
+			}
+			return result
+		}
+		for symbol, freq := range symFreqs {
+			symFreqs[symbol] = (freq + 1) / 2
+		}
+	}
+}
+
 func main() {
 	//defer profile.Start().Stop()
 	fileContents, err := ioutil.ReadFile("huffman-input.txt")
 	check(err)
-	content := string(fileContents)
 	symFreqs := make(map[rune]int)
 
-	for _, c := range content {
-		symFreqs[c]++
-	}
-	for key, val := range symFreqs {
-		if key != 10 {
-			fmt.Fprintf(&estring, "%s|%s", strconv.Itoa(val), string(key))
-
-		} else {
-			fmt.Fprintf(&estring, "%s|\\n", strconv.Itoa(val))
-		}
+	for _, c := range fileContents {
+		symFreqs[rune(c)]++
 	}
 
 	exampleTree := buildTree(symFreqs)
 
-	out := encode(exampleTree, content)
+	out, err := encode(context.Background(), exampleTree, encodeTable(symFreqs), fileContents)
+	check(err)
 	file, err := os.Create("huffman-compressed.bin")
 	check(err)
 	file.Write(out)
 
 	fileContents, err2 := ioutil.ReadFile("huffman-compressed.bin")
 	check(err2)
-	decoded := decode(fileContents)
+	decoded, err := decode(context.Background(), fileContents, 0, false)
+	check(err)
 
 	file, err = os.Create("decompressed2.txt")
 	check(err)
@@ -366,17 +479,27 @@ func main() {
 }
 
 type Writer struct {
-	w io.Writer
+	w   io.Writer
+	ctx context.Context
 }
 
 func NewWriter(w io.Writer) io.WriteCloser {
+	return NewWriterContext(context.Background(), w, codec.Options{})
+}
+
+// NewWriterContext creates an io.WriteCloser like NewWriter that gives up once ctx is done
+func NewWriterContext(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
 	z := new(Writer)
 	z.w = w
+	z.ctx = ctx
 	return z
 }
 
 func (writer *Writer) Write(data []byte) (n int, err error) {
-	compressed := Compress(data)
+	compressed, err := compress(writer.ctx, data)
+	if err != nil {
+		return 0, err
+	}
 	writer.w.Write(compressed)
 	return len(compressed), nil
 }
@@ -390,11 +513,21 @@ type Reader struct {
 	compressed   []byte
 	decompressed []byte
 	pos          int
+	limit        int
+	ctx          context.Context
 }
 
 func NewReader(r io.Reader) io.Reader {
+	return NewReaderContext(context.Background(), r, codec.Options{})
+}
+
+// NewReaderContext creates an io.Reader like NewReader that gives up once ctx is done and fails with ErrTooLarge
+// instead of decompressing more than opts.Limit bytes, a limit of 0 means no limit
+func NewReaderContext(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
 	z := new(Reader)
 	z.r = r
+	z.limit = opts.Limit
+	z.ctx = ctx
 	return z
 }
 
@@ -404,7 +537,10 @@ func (r *Reader) Read(content []byte) (n int, err error) {
 		if err != nil {
 			return 0, err
 		}
-		r.decompressed = Decompress(r.compressed)
+		r.decompressed, err = decode(r.ctx, r.compressed, r.limit, false)
+		if err != nil {
+			return 0, err
+		}
 	}
 	bytesToWriteOut := len(r.decompressed[r.pos:])
 	if len(content) < bytesToWriteOut {
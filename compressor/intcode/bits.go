@@ -0,0 +1,95 @@
+package intcode
+
+// BitWriter writes bits most significant first into a slice of bytes, the last byte is padded with zeros
+type BitWriter struct {
+	out   []byte
+	acc   uint64
+	count uint
+}
+
+// NewBitWriter creates a BitWriter that appends to dst
+func NewBitWriter(dst []byte) *BitWriter {
+	return &BitWriter{out: dst}
+}
+
+// WriteBit writes a single bit, any non-zero bit counts as a 1
+func (w *BitWriter) WriteBit(bit uint64) {
+	if bit != 0 {
+		bit = 1
+	}
+	w.WriteBits(bit, 1)
+}
+
+// WriteBits writes the low n bits of value, n can be anything up to 64
+func (w *BitWriter) WriteBits(value uint64, n uint) {
+	if n > 32 {
+		w.WriteBits(value>>32, n-32)
+		n = 32
+	}
+	w.acc = w.acc<<n | value&(1<<n-1)
+	w.count += n
+	for w.count >= 8 {
+		w.count -= 8
+		w.out = append(w.out, byte(w.acc>>w.count))
+	}
+}
+
+// Len returns how many bits have been written
+func (w *BitWriter) Len() int {
+	return len(w.out)*8 + int(w.count)
+}
+
+// Bytes pads the bits written so far out to a whole byte and returns them
+func (w *BitWriter) Bytes() []byte {
+	if w.count > 0 {
+		w.WriteBits(0, 8-w.count)
+	}
+	return w.out
+}
+
+// BitReader reads bits most significant first from a slice of bytes
+type BitReader struct {
+	in  []byte
+	pos int
+}
+
+// NewBitReader creates a BitReader that reads from src
+func NewBitReader(src []byte) *BitReader {
+	return &BitReader{in: src}
+}
+
+// ReadBit reads a single bit, returning ErrCorrupt past the end of the input
+func (r *BitReader) ReadBit() (uint64, error) {
+	if r.pos >= len(r.in)*8 {
+		return 0, ErrCorrupt
+	}
+	bit := r.in[r.pos>>3] >> (7 - uint(r.pos&7)) & 1
+	r.pos++
+	return uint64(bit), nil
+}
+
+// ReadBits reads n bits as written by WriteBits, returning ErrCorrupt past the end of the input
+func (r *BitReader) ReadBits(n uint) (uint64, error) {
+	if n > 64 || r.pos+int(n) > len(r.in)*8 {
+		return 0, ErrCorrupt
+	}
+	var value uint64
+	for n > 0 {
+		// Take as many bits as are left in the current byte at once
+		offset := uint(r.pos & 7)
+		take := 8 - offset
+		if take > n {
+			take = n
+		}
+		bits := uint64(r.in[r.pos>>3]) >> (8 - offset - take) & (1<<take - 1)
+		value = value<<take | bits
+		r.pos += int(take)
+		n -= take
+	}
+	return value, nil
+}
+
+// Remaining returns how many bits are left to read, including the padding of the last byte
+func (r *BitReader) Remaining() int {
+	return len(r.in)*8 - r.pos
+}
@@ -0,0 +1,105 @@
+package intcode
+
+import (
+	"math/bits"
+)
+
+// The Elias codes only code integers of 1 and up, coding 0 is a programming error
+func checkPositive(n uint64) {
+	if n == 0 {
+		panic("intcode: Elias codes start at 1")
+	}
+}
+
+// WriteGamma writes n with the Elias gamma code, its length in bits less one as zeros and then n itself, for 2*floor(log2 n)+1 bits
+func (w *BitWriter) WriteGamma(n uint64) {
+	checkPositive(n)
+	length := uint(bits.Len64(n))
+	w.WriteBits(0, length-1)
+	w.WriteBits(n, length)
+}
+
+// ReadGamma reads an integer written by WriteGamma
+func (r *BitReader) ReadGamma() (uint64, error) {
+	zeros := uint(0)
+	for {
+		bit, err := r.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 1 {
+			break
+		}
+		zeros++
+		if zeros > 63 {
+			return 0, ErrCorrupt
+		}
+	}
+	rest, err := r.ReadBits(zeros)
+	if err != nil {
+		return 0, err
+	}
+	return 1<<zeros | rest, nil
+}
+
+// WriteDelta writes n with the Elias delta code, its length in bits with the gamma code and then n without its leading 1,
+// which is shorter than the gamma code for anything above 31
+func (w *BitWriter) WriteDelta(n uint64) {
+	checkPositive(n)
+	length := uint(bits.Len64(n))
+	w.WriteGamma(uint64(length))
+	w.WriteBits(n, length-1)
+}
+
+// ReadDelta reads an integer written by WriteDelta
+func (r *BitReader) ReadDelta() (uint64, error) {
+	length, err := r.ReadGamma()
+	if err != nil {
+		return 0, err
+	}
+	if length > 64 {
+		return 0, ErrCorrupt
+	}
+	rest, err := r.ReadBits(uint(length - 1))
+	if err != nil {
+		return 0, err
+	}
+	return 1<<(length-1) | rest, nil
+}
+
+// WriteOmega writes n with the Elias omega code, n preceded by its length less one, preceded by that length's length less one and
+// so on down to 1, ending with a 0. Every group starts with a 1, which is how the decoder knows to keep going.
+func (w *BitWriter) WriteOmega(n uint64) {
+	checkPositive(n)
+	var groups []uint64
+	for n > 1 {
+		groups = append(groups, n)
+		n = uint64(bits.Len64(n) - 1)
+	}
+	for i := len(groups) - 1; i >= 0; i-- {
+		w.WriteBits(groups[i], uint(bits.Len64(groups[i])))
+	}
+	w.WriteBit(0)
+}
+
+// ReadOmega reads an integer written by WriteOmega
+func (r *BitReader) ReadOmega() (uint64, error) {
+	n := uint64(1)
+	for {
+		bit, err := r.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 0 {
+			return n, nil
+		}
+		if n > 63 {
+			return 0, ErrCorrupt
+		}
+		rest, err := r.ReadBits(uint(n))
+		if err != nil {
+			return 0, err
+		}
+		n = 1<<n | rest
+	}
+}
@@ -0,0 +1,140 @@
+package intcode
+
+import (
+	"math"
+	"math/bits"
+)
+
+// WriteUnary writes n as n ones followed by a zero
+func (w *BitWriter) WriteUnary(n uint64) {
+	for ; n >= 32; n -= 32 {
+		w.WriteBits(1<<32-1, 32)
+	}
+	w.WriteBits(1<<(n+1)-2, uint(n+1))
+}
+
+// ReadUnary reads an integer written by WriteUnary
+func (r *BitReader) ReadUnary() (uint64, error) {
+	var n uint64
+	for {
+		bit, err := r.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 0 {
+			return n, nil
+		}
+		n++
+	}
+}
+
+// WriteRice writes n with the Golomb-Rice code of parameter k, n>>k in unary and then the low k bits of n.
+// It suits integers with a geometric distribution, such as the gaps between sorted IDs, when k is close to log2 of their mean.
+func (w *BitWriter) WriteRice(n uint64, k uint) {
+	w.WriteUnary(n >> k)
+	w.WriteBits(n, k)
+}
+
+// ReadRice reads an integer written by WriteRice with the same k
+func (r *BitReader) ReadRice(k uint) (uint64, error) {
+	q, err := r.ReadUnary()
+	if err != nil {
+		return 0, err
+	}
+	if k > 63 || q > math.MaxUint64>>k {
+		return 0, ErrCorrupt
+	}
+	rest, err := r.ReadBits(k)
+	if err != nil {
+		return 0, err
+	}
+	return q<<k | rest, nil
+}
+
+// WriteGolomb writes n with the Golomb code of parameter m, n/m in unary and then n%m in truncated binary,
+// which spends one bit less on the smallest remainders when m isn't a power of 2
+func (w *BitWriter) WriteGolomb(n uint64, m uint64) {
+	if m == 0 {
+		panic("intcode: Golomb parameter must be at least 1")
+	}
+	w.WriteUnary(n / m)
+	b := uint(bits.Len64(m - 1))
+	cutoff := uint64(1)<<b - m
+	if r := n % m; r < cutoff {
+		w.WriteBits(r, b-1)
+	} else {
+		w.WriteBits(r+cutoff, b)
+	}
+}
+
+// ReadGolomb reads an integer written by WriteGolomb with the same m
+func (r *BitReader) ReadGolomb(m uint64) (uint64, error) {
+	if m == 0 {
+		return 0, ErrCorrupt
+	}
+	q, err := r.ReadUnary()
+	if err != nil {
+		return 0, err
+	}
+	b := uint(bits.Len64(m - 1))
+	cutoff := uint64(1)<<b - m
+	var rem uint64
+	if b > 0 {
+		if rem, err = r.ReadBits(b - 1); err != nil {
+			return 0, err
+		}
+		if rem >= cutoff {
+			bit, err := r.ReadBit()
+			if err != nil {
+				return 0, err
+			}
+			rem = rem<<1 | bit - cutoff
+		}
+	}
+	hi, lo := bits.Mul64(q, m)
+	sum, carry := bits.Add64(lo, rem, 0)
+	if hi != 0 || carry != 0 {
+		return 0, ErrCorrupt
+	}
+	return sum, nil
+}
+
+// EstimateRice returns the parameter k that codes values in the fewest bits with WriteRice
+func EstimateRice(values []uint64) uint {
+	cost := func(k uint) float64 {
+		total := float64(len(values)) * float64(k+1)
+		for _, n := range values {
+			total += float64(n >> k)
+		}
+		return total
+	}
+	// The cost falls as k grows until the unary parts are shorter than the bits added to every value, and rises from there
+	best, bestCost := uint(0), cost(0)
+	for k := uint(1); k < 64; k++ {
+		c := cost(k)
+		if c >= bestCost {
+			break
+		}
+		best, bestCost = k, c
+	}
+	return best
+}
+
+// EstimateGolomb returns a parameter m for WriteGolomb that suits values if they have a geometric distribution,
+// for which the best m is close to ln 2 times their mean
+func EstimateGolomb(values []uint64) uint64 {
+	if len(values) == 0 {
+		return 1
+	}
+	var mean float64
+	for _, n := range values {
+		mean += float64(n) / float64(len(values))
+	}
+	m := math.Ceil(math.Ln2 * mean)
+	if m < 1 {
+		return 1
+	} else if m >= 1<<63 {
+		return 1 << 63
+	}
+	return uint64(m)
+}
@@ -0,0 +1,256 @@
+// Package intcode implements universal codes for integers on top of bit-level I/O: Elias gamma, delta and omega,
+// Golomb and Golomb-Rice with parameter estimation, LEB128 varints and zigzag coding of signed integers.
+// Compress and Decompress use them to code whole lists of integers, such as sorted lists of IDs,
+// so codecs don't need to invent an encoding of their own every time they store numbers.
+package intcode
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	// GolombRice codes every block of integers with the Golomb-Rice parameter that suits it best
+	GolombRice = iota
+	// EliasGamma codes integers with the Elias gamma code, which suits streams of mostly very small integers
+	EliasGamma
+	// EliasDelta codes integers with the Elias delta code
+	EliasDelta
+	// EliasOmega codes integers with the Elias omega code, which is the shortest of the three for very large integers
+	EliasOmega
+	// LEB128 codes integers as varints, which is the quickest to decode but never takes less than a byte
+	LEB128
+)
+
+// Settings represents an object that can be used to modify how integers are coded
+// Code is one of the codes above, Differences codes each integer as its difference from the one before, which makes sorted lists small,
+// and BlockSize is how many integers share a Golomb-Rice parameter.
+type Settings struct {
+	Code        int
+	Differences bool
+	BlockSize   int
+}
+
+// NewSettings returns the default settings as a Settings object
+func NewSettings() Settings {
+	s := Settings{}
+	s.Code = GolombRice
+	s.Differences = true
+	s.BlockSize = 128
+	return s
+}
+
+var (
+	// ErrCorrupt is returned when decoding a stream that is malformed
+	ErrCorrupt = errors.New("intcode: corrupt input")
+	// ErrTooLarge is returned when a stream holds more integers than the limit
+	ErrTooLarge = errors.New("intcode: integer count exceeds limit")
+)
+
+const (
+	flagDifferences = 1 << 0
+	// flagZigzag is set when some integer (or difference) is negative, a list without any, such as sorted IDs, saves the bit zigzag coding would spend on the sign
+	flagZigzag = 1 << 1
+
+	maxBlockSize = 1 << 20
+	// riceEscape is the longest unary part the codec writes, anything larger is written in full after it
+	// so a single outlier can't make a block's Golomb-Rice code enormous
+	riceEscape = 48
+	riceKBits  = 6
+)
+
+// Compress takes a slice of integers and returns them coded as a stream of bits. It starts with the code, a flags byte,
+// the block size and the number of integers. Integers (or their differences) are zigzag coded if any are negative, and a block coded with
+// Golomb-Rice starts with its parameter in 6 bits. The Elias codes can't code the zigzag of math.MinInt64,
+// so if that ever comes up the list is coded with LEB128 instead.
+func Compress(values []int64, settings Settings) []byte {
+	code := settings.Code
+	if code < GolombRice || code > LEB128 {
+		code = GolombRice
+	}
+	blockSize := settings.BlockSize
+	if blockSize <= 0 || blockSize > maxBlockSize {
+		blockSize = maxBlockSize
+	}
+
+	var flags byte
+	if settings.Differences {
+		flags |= flagDifferences
+	}
+	differences := make([]int64, len(values))
+	var previous int64
+	for i, v := range values {
+		if settings.Differences {
+			v, previous = v-previous, v
+		}
+		differences[i] = v
+		if v < 0 {
+			flags |= flagZigzag
+		}
+	}
+	zigzags := make([]uint64, len(values))
+	for i, v := range differences {
+		if flags&flagZigzag == 0 {
+			zigzags[i] = uint64(v)
+		} else {
+			zigzags[i] = Zigzag(v)
+		}
+		if zigzags[i] == math.MaxUint64 && code != GolombRice {
+			code = LEB128
+		}
+	}
+
+	header := AppendUvarint([]byte{byte(code), flags}, uint64(blockSize))
+	header = AppendUvarint(header, uint64(len(values)))
+	w := NewBitWriter(header)
+	for start := 0; start < len(zigzags); start += blockSize {
+		end := start + blockSize
+		if end > len(zigzags) {
+			end = len(zigzags)
+		}
+		block := zigzags[start:end]
+		var k uint
+		if code == GolombRice {
+			k = EstimateRice(block)
+			w.WriteBits(uint64(k), riceKBits)
+		}
+		for _, n := range block {
+			switch code {
+			case GolombRice:
+				if n>>k >= riceEscape {
+					w.WriteUnary(riceEscape)
+					w.WriteBits(n, 64)
+				} else {
+					w.WriteRice(n, k)
+				}
+			case EliasGamma:
+				w.WriteGamma(n + 1)
+			case EliasDelta:
+				w.WriteDelta(n + 1)
+			case EliasOmega:
+				w.WriteOmega(n + 1)
+			case LEB128:
+				for _, b := range AppendUvarint(nil, n) {
+					w.WriteBits(uint64(b), 8)
+				}
+			}
+		}
+	}
+	return w.Bytes()
+}
+
+// Decompress takes a stream written by Compress and returns the integers in it
+func Decompress(content []byte) ([]int64, error) {
+	return DecompressLimit(content, 0)
+}
+
+// DecompressLimit is like Decompress but returns ErrTooLarge if the stream holds more than limit integers, a limit of 0 means no limit.
+func DecompressLimit(content []byte, limit int) ([]int64, error) {
+	if len(content) < 2 {
+		return nil, ErrCorrupt
+	}
+	code, flags := int(content[0]), content[1]
+	blockSize, n := Uvarint(content[2:])
+	if n <= 0 || code > LEB128 || flags&^(flagDifferences|flagZigzag) != 0 || blockSize == 0 || blockSize > maxBlockSize {
+		return nil, ErrCorrupt
+	}
+	i := 2 + n
+	count, n := Uvarint(content[i:])
+	if n <= 0 {
+		return nil, ErrCorrupt
+	}
+	i += n
+	if limit > 0 && count > uint64(limit) {
+		return nil, ErrTooLarge
+	}
+	// Every integer takes at least a bit, which stops a corrupt count from allocating too much
+	if count > uint64(len(content)-i)*8 {
+		return nil, ErrCorrupt
+	}
+
+	r := NewBitReader(content[i:])
+	values := make([]int64, 0, count)
+	var previous int64
+	var k uint
+	for uint64(len(values)) < count {
+		if code == GolombRice && uint64(len(values))%blockSize == 0 {
+			bits, err := r.ReadBits(riceKBits)
+			if err != nil {
+				return values, err
+			}
+			k = uint(bits)
+		}
+		var value uint64
+		var err error
+		switch code {
+		case GolombRice:
+			value, err = r.readEscapedRice(k)
+		case EliasGamma:
+			value, err = r.ReadGamma()
+		case EliasDelta:
+			value, err = r.ReadDelta()
+		case EliasOmega:
+			value, err = r.ReadOmega()
+		case LEB128:
+			value, err = r.readUvarint()
+		}
+		if err != nil {
+			return values, err
+		}
+		if code >= EliasGamma && code <= EliasOmega {
+			value--
+		}
+		v := int64(value)
+		if flags&flagZigzag != 0 {
+			v = Unzigzag(value)
+		}
+		if flags&flagDifferences != 0 {
+			v += previous
+			previous = v
+		}
+		values = append(values, v)
+	}
+	// Only the padding of the last byte may be left over
+	if r.Remaining() >= 8 {
+		return values, ErrCorrupt
+	}
+	return values, nil
+}
+
+// readEscapedRice reads an integer written by Compress with WriteRice, or in full after riceEscape ones
+func (r *BitReader) readEscapedRice(k uint) (uint64, error) {
+	q, err := r.ReadUnary()
+	if err != nil {
+		return 0, err
+	}
+	if q == riceEscape {
+		return r.ReadBits(64)
+	} else if q > riceEscape || k > 63 || q > math.MaxUint64>>k {
+		return 0, ErrCorrupt
+	}
+	rest, err := r.ReadBits(k)
+	if err != nil {
+		return 0, err
+	}
+	return q<<k | rest, nil
+}
+
+// readUvarint reads a LEB128 varint a byte at a time from the bit stream
+func (r *BitReader) readUvarint() (uint64, error) {
+	var buf [10]byte
+	for i := range buf {
+		b, err := r.ReadBits(8)
+		if err != nil {
+			return 0, err
+		}
+		buf[i] = byte(b)
+		if b < 0x80 {
+			v, n := Uvarint(buf[:i+1])
+			if n <= 0 {
+				return 0, ErrCorrupt
+			}
+			return v, nil
+		}
+	}
+	return 0, ErrCorrupt
+}
@@ -0,0 +1,174 @@
+package intcode
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func testValues() map[string][]int64 {
+	r := rand.New(rand.NewSource(1))
+	ids := make([]int64, 10000)
+	for i := range ids {
+		ids[i] = r.Int63n(1 << 30)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	random := make([]int64, 1000)
+	for i := range random {
+		random[i] = int64(r.Uint64())
+	}
+	small := make([]int64, 1000)
+	for i := range small {
+		small[i] = int64(r.ExpFloat64()*4) - 2
+	}
+	return map[string][]int64{
+		"empty":   {},
+		"single":  {42},
+		"ids":     ids,
+		"random":  random,
+		"small":   small,
+		"extreme": {math.MinInt64, math.MaxInt64, 0, math.MinInt64, -1, math.MaxInt64},
+	}
+}
+
+func testSettings() map[string]Settings {
+	return map[string]Settings{
+		"default":      NewSettings(),
+		"rice values":  {Code: GolombRice, BlockSize: 16},
+		"gamma":        {Code: EliasGamma, Differences: true},
+		"delta":        {Code: EliasDelta, Differences: true},
+		"omega":        {Code: EliasOmega},
+		"leb128":       {Code: LEB128, Differences: true},
+		"rice 1 block": {Code: GolombRice, Differences: true, BlockSize: 1},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for name, values := range testValues() {
+		for settingsName, settings := range testSettings() {
+			decompressed, err := Decompress(Compress(values, settings))
+			if err != nil || len(decompressed) != len(values) || (len(values) > 0 && !reflect.DeepEqual(decompressed, values)) {
+				t.Errorf("%s with %s settings was not lossless: %v", name, settingsName, err)
+			}
+		}
+	}
+}
+
+func TestCompressIDs(t *testing.T) {
+	// 10000 sorted IDs under 1<<30 are about 1<<17 apart, which Golomb-Rice codes in under 19 bits each
+	ids := testValues()["ids"]
+	if compressed := Compress(ids, NewSettings()); len(compressed) > 10000*19/8+100 {
+		t.Errorf("Compressed 10000 sorted IDs to %d bytes", len(compressed))
+	}
+}
+
+func TestUniversalCodes(t *testing.T) {
+	values := []uint64{1, 2, 3, 4, 5, 15, 16, 17, 100, 1000, 1 << 31, 1<<63 - 1, 1 << 63, math.MaxUint64}
+	// Lengths from the definitions of the codes
+	gamma := map[uint64]int{1: 1, 2: 3, 4: 5, 17: 9, math.MaxUint64: 127}
+	delta := map[uint64]int{1: 1, 2: 4, 4: 5, 17: 9, math.MaxUint64: 64 + 13 - 1}
+	omega := map[uint64]int{1: 1, 2: 3, 4: 6, 17: 11, 100: 13}
+
+	w := NewBitWriter(nil)
+	for _, n := range values {
+		for code, lengths := range []map[uint64]int{gamma, delta, omega} {
+			before := w.Len()
+			switch code {
+			case 0:
+				w.WriteGamma(n)
+			case 1:
+				w.WriteDelta(n)
+			case 2:
+				w.WriteOmega(n)
+			}
+			if length, ok := lengths[n]; ok && w.Len()-before != length {
+				t.Errorf("Code %d of %d took %d bits, expected %d", code, n, w.Len()-before, length)
+			}
+		}
+		w.WriteRice(n>>40, 5)
+		w.WriteGolomb(n>>40, 7)
+		w.WriteBits(n, 64)
+	}
+	r := NewBitReader(w.Bytes())
+	for _, n := range values {
+		gamma, _ := r.ReadGamma()
+		delta, _ := r.ReadDelta()
+		omega, _ := r.ReadOmega()
+		rice, _ := r.ReadRice(5)
+		golomb, _ := r.ReadGolomb(7)
+		raw, err := r.ReadBits(64)
+		if gamma != n || delta != n || omega != n || rice != n>>40 || golomb != n>>40 || raw != n || err != nil {
+			t.Errorf("Read %d, %d, %d, %d, %d, %d back for %d: %v", gamma, delta, omega, rice, golomb, raw, n, err)
+		}
+	}
+	if r.Remaining() >= 8 {
+		t.Errorf("%d bits left over", r.Remaining())
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	// Geometric values with a mean of about 100 are coded best with k of 6 and m of about 70
+	r := rand.New(rand.NewSource(1))
+	values := make([]uint64, 10000)
+	for i := range values {
+		values[i] = uint64(r.ExpFloat64() * 100)
+	}
+	if k := EstimateRice(values); k != 6 {
+		t.Errorf("Estimated a Rice parameter of %d, expected 6", k)
+	}
+	if m := EstimateGolomb(values); m < 60 || m > 80 {
+		t.Errorf("Estimated a Golomb parameter of %d, expected about 70", m)
+	}
+}
+
+func TestVarint(t *testing.T) {
+	for _, v := range []int64{0, -1, 1, 63, -64, 64, math.MaxInt64, math.MinInt64} {
+		encoded := AppendVarint(nil, v)
+		decoded, n := Varint(encoded)
+		if decoded != v || n != len(encoded) {
+			t.Errorf("Varint of %d decoded to %d after %d of %d bytes", v, decoded, n, len(encoded))
+		}
+	}
+	if encoded := AppendUvarint(nil, 624485); !reflect.DeepEqual(encoded, []byte{0xe5, 0x8e, 0x26}) {
+		t.Errorf("LEB128 of 624485 was %x", encoded)
+	}
+	if _, n := Uvarint([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02}); n >= 0 {
+		t.Errorf("Varint overflowing 64 bits returned a length of %d", n)
+	}
+	if _, n := Uvarint([]byte{0x80}); n != 0 {
+		t.Errorf("Truncated varint returned a length of %d", n)
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	compressed := Compress(testValues()["ids"][:100], NewSettings())
+	cases := map[string][]byte{
+		"empty":            {},
+		"truncated":        compressed[:len(compressed)-1],
+		"trailing garbage": append(append([]byte{}, compressed...), 7),
+		"unknown code":     append([]byte{9}, compressed[1:]...),
+		"huge count":       {GolombRice, 0, 128, 1, 0xff, 0xff, 0xff, 0xff, 0x0f, 0},
+	}
+	for name, corrupt := range cases {
+		if _, err := Decompress(corrupt); err != ErrCorrupt {
+			t.Errorf("Decompressing %s returned %v, expected ErrCorrupt", name, err)
+		}
+	}
+	if _, err := DecompressLimit(compressed, 99); err != ErrTooLarge {
+		t.Errorf("Expected ErrTooLarge one integer over the limit, got %v", err)
+	}
+}
+
+// FuzzDecompress checks that no input makes the decoder panic
+func FuzzDecompress(f *testing.F) {
+	for _, values := range testValues() {
+		for _, settings := range testSettings() {
+			f.Add(Compress(values, settings))
+		}
+	}
+	f.Fuzz(func(t *testing.T, content []byte) {
+		DecompressLimit(content, 1<<16)
+	})
+}
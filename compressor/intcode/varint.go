@@ -0,0 +1,47 @@
+package intcode
+
+// AppendUvarint appends v as an unsigned LEB128 varint, 7 bits at a time from the lowest with the top bit of every byte but the last set
+func AppendUvarint(dst []byte, v uint64) []byte {
+	for v >= 0x80 {
+		dst = append(dst, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(dst, byte(v))
+}
+
+// Uvarint reads an unsigned LEB128 varint from the start of src, returning it and how many bytes it took up.
+// The count is 0 if src ends in the middle of the varint and negative if the varint doesn't fit in 64 bits.
+func Uvarint(src []byte) (uint64, int) {
+	var v uint64
+	for i, b := range src {
+		if i == 9 && b > 1 {
+			return 0, -(i + 1)
+		}
+		v |= uint64(b&0x7f) << (7 * uint(i))
+		if b < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// AppendVarint appends v as a signed LEB128 varint, zigzag coded so that small negative numbers stay short
+func AppendVarint(dst []byte, v int64) []byte {
+	return AppendUvarint(dst, Zigzag(v))
+}
+
+// Varint reads a signed varint written by AppendVarint, returning it and how many bytes it took up like Uvarint
+func Varint(src []byte) (int64, int) {
+	v, n := Uvarint(src)
+	return Unzigzag(v), n
+}
+
+// Zigzag maps signed integers to unsigned ones by magnitude, 0, -1, 1, -2, 2... to 0, 1, 2, 3, 4...
+func Zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// Unzigzag reverses Zigzag
+func Unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
@@ -0,0 +1,164 @@
+package lz
+
+import (
+	"context"
+	"encoding/binary"
+	codec "github.com/go-compression/raisin/compressor/codec"
+	"io"
+)
+
+// LiteralCoder is an entropy coder for the literals of CompressLiterals, for example one wrapping ans.Compress and ans.DecompressLimit.
+// Decode is passed the most literals the stream can hold and should fail rather than decode more than that, a limit of 0 means no limit.
+type LiteralCoder struct {
+	Encode func(literals []byte) []byte
+	Decode func(encoded []byte, limit int) ([]byte, error)
+}
+
+// CompressLiterals takes a slice of bytes and returns it as LZSS tokens, with the literals kept apart so that coder can entropy code them together.
+// A flag bit per token says whether it's a literal or a match, matches are stored as varints of their length and distance,
+// and the literals are stored however coder encodes them. The stream starts with the number of tokens and the sizes of the matches and literals.
+func CompressLiterals(content []byte, settings ParseSettings, coder LiteralCoder) []byte {
+	output, _ := compressLiterals(context.Background(), content, settings, coder)
+	return output
+}
+
+func compressLiterals(ctx context.Context, content []byte, settings ParseSettings, coder LiteralCoder) ([]byte, error) {
+	tokens, err := ParseContext(ctx, content, settings)
+	if err != nil {
+		return nil, err
+	}
+	minMatch := settings.MinMatch
+	if minMatch < 3 {
+		minMatch = 3
+	}
+
+	flags := make([]byte, (len(tokens)+7)/8)
+	var matches, literals []byte
+	for i, token := range tokens {
+		if token.Length == 0 {
+			literals = append(literals, token.Literal)
+			continue
+		}
+		flags[i/8] |= 1 << uint(i%8)
+		matches = appendUvarint(matches, uint64(token.Length-minMatch))
+		matches = appendUvarint(matches, uint64(token.Distance-1))
+	}
+	encoded := coder.Encode(literals)
+
+	output := appendUvarint(nil, uint64(len(tokens)))
+	output = appendUvarint(output, uint64(minMatch))
+	output = appendUvarint(output, uint64(len(matches)))
+	output = appendUvarint(output, uint64(len(encoded)))
+	output = append(output, flags...)
+	output = append(output, matches...)
+	return append(output, encoded...), nil
+}
+
+// DecompressLiterals takes a stream written by CompressLiterals with the same coder and returns the decompressed contents
+func DecompressLiterals(content []byte, coder LiteralCoder) ([]byte, error) {
+	return DecompressLiteralsLimit(content, coder, 0)
+}
+
+// DecompressLiteralsLimit is like DecompressLiterals but returns ErrTooLarge once the output would exceed limit bytes, a limit of 0 means no limit.
+func DecompressLiteralsLimit(content []byte, coder LiteralCoder, limit int) ([]byte, error) {
+	return decompressLiterals(context.Background(), content, coder, limit)
+}
+
+func decompressLiterals(ctx context.Context, content []byte, coder LiteralCoder, limit int) ([]byte, error) {
+	var header [4]uint64
+	i := 0
+	for k := range header {
+		value, n := binary.Uvarint(content[i:])
+		if n <= 0 {
+			return nil, ErrCorrupt
+		}
+		header[k] = value
+		i += n
+	}
+	tokenCount, minMatch, matchesSize, encodedSize := header[0], header[1], header[2], header[3]
+	flagsSize := (tokenCount + 7) / 8
+	if tokenCount > uint64(len(content))*8 || minMatch < 3 || minMatch > 1<<16 ||
+		flagsSize+matchesSize > uint64(len(content)-i) || encodedSize != uint64(len(content)-i)-flagsSize-matchesSize {
+		return nil, ErrCorrupt
+	}
+	flags := content[i : i+int(flagsSize)]
+	matches := content[i+int(flagsSize) : i+int(flagsSize+matchesSize)]
+	literalLimit := int(tokenCount)
+	if limit > 0 && limit < literalLimit {
+		literalLimit = limit
+	}
+	literals, err := coder.Decode(content[i+int(flagsSize+matchesSize):], literalLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]byte, 0, 2*len(content))
+	for t := 0; t < int(tokenCount); t++ {
+		if t%checkInterval == 0 && ctx.Err() != nil {
+			return output, ctx.Err()
+		}
+		if flags[t/8]&(1<<uint(t%8)) == 0 {
+			if len(literals) == 0 {
+				return output, ErrCorrupt
+			}
+			if limit > 0 && len(output) >= limit {
+				return output, ErrTooLarge
+			}
+			output = append(output, literals[0])
+			literals = literals[1:]
+			continue
+		}
+		length, n := binary.Uvarint(matches)
+		if n <= 0 || length > 1<<24 {
+			return output, ErrCorrupt
+		}
+		matches = matches[n:]
+		distance, n := binary.Uvarint(matches)
+		if n <= 0 || distance >= uint64(len(output)) {
+			return output, ErrCorrupt
+		}
+		matches = matches[n:]
+		size, start := int(length+minMatch), len(output)-int(distance)-1
+		if limit > 0 && len(output)+size > limit {
+			return output, ErrTooLarge
+		}
+		// Copied a byte at a time since the match may overlap the bytes it produces
+		for k := 0; k < size; k++ {
+			output = append(output, output[start+k])
+		}
+	}
+	if len(literals) != 0 || len(matches) != 0 {
+		return output, ErrCorrupt
+	}
+	return output, nil
+}
+
+// NewLiteralsWriter creates an io.WriteCloser object with an io.Writer that writes the stream of CompressLiterals when closed
+func NewLiteralsWriter(w io.Writer, settings ParseSettings, coder LiteralCoder) io.WriteCloser {
+	return NewLiteralsWriterContext(context.Background(), w, settings, coder)
+}
+
+// NewLiteralsWriterContext creates an io.WriteCloser like NewLiteralsWriter that gives up once ctx is done
+func NewLiteralsWriterContext(ctx context.Context, w io.Writer, settings ParseSettings, coder LiteralCoder) io.WriteCloser {
+	return &bufferedWriter{w: w, ctx: ctx, compress: func(ctx context.Context, content []byte) ([]byte, error) {
+		return compressLiterals(ctx, content, settings, coder)
+	}}
+}
+
+// NewLiteralsReader creates an io.Reader object that decompresses a stream of CompressLiterals written with the same coder
+func NewLiteralsReader(r io.Reader, coder LiteralCoder) io.Reader {
+	return NewLiteralsReaderContext(context.Background(), r, coder, codec.Options{})
+}
+
+// NewLiteralsReaderContext creates an io.Reader like NewLiteralsReader that gives up once ctx is done and fails with ErrTooLarge
+// instead of decompressing more than opts.Limit bytes, a limit of 0 means no limit
+func NewLiteralsReaderContext(ctx context.Context, r io.Reader, coder LiteralCoder, opts codec.Options) io.Reader {
+	return &bufferedReader{r: r, ctx: ctx, limit: opts.Limit, decompress: func(ctx context.Context, content []byte, limit int) ([]byte, error) {
+		return decompressLiterals(ctx, content, coder, limit)
+	}}
+}
+
+func appendUvarint(dst []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(dst, buf[:binary.PutUvarint(buf[:], v)]...)
+}
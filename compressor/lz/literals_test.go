@@ -0,0 +1,86 @@
+package lz
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/go-compression/raisin/compressor/ans"
+	codec "github.com/go-compression/raisin/compressor/codec"
+	"github.com/go-compression/raisin/internal/codectest"
+)
+
+// storedLiterals keeps literals as they are, so the token layout can be tested apart from any entropy coder
+var storedLiterals = LiteralCoder{
+	Encode: func(literals []byte) []byte { return literals },
+	Decode: func(encoded []byte, limit int) ([]byte, error) {
+		if limit > 0 && len(encoded) > limit {
+			return nil, ErrTooLarge
+		}
+		return encoded, nil
+	},
+}
+
+var ansLiterals = LiteralCoder{
+	Encode: func(literals []byte) []byte { return ans.Compress(literals, ans.NewSettings()) },
+	Decode: ans.DecompressLimit,
+}
+
+func TestLiterals(t *testing.T) {
+	for name, content := range codectest.Inputs(100000) {
+		for coderName, coder := range map[string]LiteralCoder{"stored": storedLiterals, "ans": ansLiterals} {
+			decompressed, err := DecompressLiterals(CompressLiterals(content, NewParseSettings(), coder), coder)
+			if err != nil || !bytes.Equal(decompressed, content) {
+				t.Errorf("%s with %s literals was not lossless: %v", name, coderName, err)
+			}
+		}
+	}
+	// Entropy coding the literals should beat storing them when they're random letters with a skewed distribution
+	r := rand.New(rand.NewSource(1))
+	letters := make([]byte, 20000)
+	for i := range letters {
+		letters[i] = 'a' + byte(r.ExpFloat64()*4)%26
+	}
+	stored := CompressLiterals(letters, NewParseSettings(), storedLiterals)
+	if coded := CompressLiterals(letters, NewParseSettings(), ansLiterals); len(coded) >= len(stored)-1000 {
+		t.Errorf("Coding literals with ans took %d bytes, storing them took %d", len(coded), len(stored))
+	}
+}
+
+func TestLiteralsCorrupt(t *testing.T) {
+	compressed := CompressLiterals([]byte(samIAm), NewParseSettings(), storedLiterals)
+	cases := map[string][]byte{
+		"empty":                  {},
+		"truncated":              compressed[:len(compressed)-1],
+		"trailing garbage":       append(append([]byte{}, compressed...), 7),
+		"short min match":        {1, 2, 0, 1, 0, 'a'},
+		"reference before start": {2, 3, 2, 1, 0x02, 0, 1, 'a'},
+		"sizes past the end":     {1, 3, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01, 1, 0},
+	}
+	codectest.Corrupt(t, cases, ErrCorrupt, func(content []byte) ([]byte, error) {
+		return DecompressLiterals(content, storedLiterals)
+	})
+}
+
+func TestLiteralsLimit(t *testing.T) {
+	content := []byte(samIAm)
+	compressed := CompressLiterals(content, NewParseSettings(), ansLiterals)
+	codectest.Limit(t, content, compressed, ErrTooLarge, func(compressed []byte, limit int) ([]byte, error) {
+		return DecompressLiteralsLimit(compressed, ansLiterals, limit)
+	})
+	codectest.ReaderLimit(t, content, compressed, ErrTooLarge, func(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+		return NewLiteralsReaderContext(ctx, r, ansLiterals, opts)
+	})
+}
+
+func TestLiteralsWriter(t *testing.T) {
+	newWriter := func(w io.Writer) io.WriteCloser {
+		return NewLiteralsWriter(w, NewParseSettings(), ansLiterals)
+	}
+	newReader := func(r io.Reader) io.Reader {
+		return NewLiteralsReader(r, ansLiterals)
+	}
+	codectest.Writer(t, []byte(samIAm), 100, newWriter, newReader)
+}
@@ -0,0 +1,128 @@
+package lz
+
+import (
+	"context"
+	"errors"
+	codec "github.com/go-compression/raisin/compressor/codec"
+	"io"
+)
+
+const (
+	// lz77TripleSize is the size of every triple, a 2 byte distance, a 1 byte length and the next byte
+	lz77TripleSize  = 4
+	lz77MaxDistance = 1<<16 - 1
+	lz77MaxLength   = 1<<8 - 1
+)
+
+var (
+	// ErrCorruptLZ77 is returned when decompressing LZ77 triples that are cut short or refer back past the start of the output
+	ErrCorruptLZ77 = errors.New("lz77: corrupt input")
+	// ErrTooLargeLZ77 is returned when LZ77 triples decompress to more than the limit
+	ErrTooLargeLZ77 = errors.New("lz77: decompressed size exceeds limit")
+)
+
+// CompressLZ77 takes a slice of bytes and returns it encoded as the triples of the original LZ77 algorithm.
+// Every triple is a back reference, which may be empty, followed by the byte after it, so unlike LZSS a literal costs as much as a reference.
+// Matches are found with the same hash chains as Parse, the window is limited to 65535 bytes and matches to 255 bytes so that a triple is 4 bytes.
+func CompressLZ77(content []byte, settings ParseSettings) []byte {
+	output, _ := compressLZ77(context.Background(), content, settings)
+	return output
+}
+
+func compressLZ77(ctx context.Context, content []byte, settings ParseSettings) ([]byte, error) {
+	if settings.WindowSize <= 0 || settings.WindowSize > lz77MaxDistance {
+		settings.WindowSize = lz77MaxDistance
+	}
+	maxMatch := settings.MaxMatch
+	if maxMatch <= 0 || maxMatch > lz77MaxLength {
+		maxMatch = lz77MaxLength
+	}
+
+	m := newMatchFinder(content, settings)
+	output := make([]byte, 0, len(content)/2)
+	for i, checked := 0, 0; i < len(content); {
+		if i-checked >= checkInterval {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			checked = i
+		}
+		// Every triple ends with a byte, so a match can't run to the end of the content
+		maxLength := len(content) - i - 1
+		if maxLength > maxMatch {
+			maxLength = maxMatch
+		}
+		length, distance := m.find(i, maxLength)
+		for k := i; k <= i+length; k++ {
+			m.insert(k)
+		}
+		output = append(output, byte(distance>>8), byte(distance), byte(length), content[i+length])
+		i += length + 1
+	}
+	return output, nil
+}
+
+// DecompressLZ77 takes a stream of LZ77 triples and returns the decompressed contents
+func DecompressLZ77(content []byte) ([]byte, error) {
+	return DecompressLZ77Limit(content, 0)
+}
+
+// DecompressLZ77Limit is like DecompressLZ77 but returns ErrTooLargeLZ77 once the output would exceed limit bytes, a limit of 0 means no limit.
+func DecompressLZ77Limit(content []byte, limit int) ([]byte, error) {
+	return decompressLZ77(context.Background(), content, limit)
+}
+
+func decompressLZ77(ctx context.Context, content []byte, limit int) ([]byte, error) {
+	output := make([]byte, 0, 2*len(content))
+	for i := 0; i+lz77TripleSize <= len(content); i += lz77TripleSize {
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return output, ctx.Err()
+		}
+		distance := int(content[i])<<8 | int(content[i+1])
+		length := int(content[i+2])
+		if (length == 0) != (distance == 0) || distance > len(output) {
+			return output, ErrCorruptLZ77
+		}
+		if limit > 0 && len(output)+length+1 > limit {
+			return output, ErrTooLargeLZ77
+		}
+		// Copied a byte at a time since the reference may overlap the bytes it produces
+		start := len(output) - distance
+		for k := 0; k < length; k++ {
+			output = append(output, output[start+k])
+		}
+		output = append(output, content[i+3])
+	}
+	if len(content)%lz77TripleSize != 0 {
+		return output, ErrCorruptLZ77
+	}
+	return output, nil
+}
+
+// NewLZ77Writer creates an io.WriteCloser object with an io.Writer that writes LZ77 triples with the default ParseSettings when closed
+func NewLZ77Writer(w io.Writer) io.WriteCloser {
+	return NewLZ77WriterSettings(w, NewParseSettings())
+}
+
+// NewLZ77WriterSettings creates an io.WriteCloser object with an io.Writer that finds matches with the given settings
+func NewLZ77WriterSettings(w io.Writer, settings ParseSettings) io.WriteCloser {
+	return NewLZ77WriterContext(context.Background(), w, settings)
+}
+
+// NewLZ77WriterContext creates an io.WriteCloser like NewLZ77WriterSettings that gives up once ctx is done
+func NewLZ77WriterContext(ctx context.Context, w io.Writer, settings ParseSettings) io.WriteCloser {
+	return &bufferedWriter{w: w, ctx: ctx, compress: func(ctx context.Context, content []byte) ([]byte, error) {
+		return compressLZ77(ctx, content, settings)
+	}}
+}
+
+// NewLZ77Reader creates an io.Reader object that decompresses LZ77 triples from an io.Reader
+func NewLZ77Reader(r io.Reader) io.Reader {
+	return NewLZ77ReaderContext(context.Background(), r, codec.Options{})
+}
+
+// NewLZ77ReaderContext creates an io.Reader like NewLZ77Reader that gives up once ctx is done and fails with ErrTooLargeLZ77 instead of
+// decompressing more than opts.Limit bytes, a limit of 0 means no limit
+func NewLZ77ReaderContext(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+	return &bufferedReader{r: r, ctx: ctx, decompress: decompressLZ77, limit: opts.Limit}
+}
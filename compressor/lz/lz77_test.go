@@ -0,0 +1,44 @@
+package lz
+
+import (
+	"bytes"
+	"github.com/go-compression/raisin/internal/codectest"
+	"testing"
+)
+
+func TestLZ77(t *testing.T) {
+	for name, content := range codectest.Inputs(100000) {
+		compressed := CompressLZ77(content, NewParseSettings())
+		if len(compressed)%lz77TripleSize != 0 {
+			t.Errorf("%s compressed to %d bytes, not a whole number of triples", name, len(compressed))
+		}
+		decompressed, err := DecompressLZ77(compressed)
+		if err != nil || !bytes.Equal(decompressed, content) {
+			t.Errorf("%s was not lossless: %v", name, err)
+		}
+	}
+	// A run is one literal and then references that overlap themselves
+	if compressed := CompressLZ77(codectest.Inputs(100000)["run"], NewParseSettings()); len(compressed) > 4*(100000/256+2) {
+		t.Errorf("Run of 100000 bytes compressed to %d bytes", len(compressed))
+	}
+}
+
+func TestLZ77Corrupt(t *testing.T) {
+	cases := map[string][]byte{
+		"reference before start":  {0, 1, 3, 'a'},
+		"distance without length": {0, 0, 0, 'a', 0, 1, 0, 'b'},
+		"truncated":               {0, 0, 0, 'a', 0, 1},
+	}
+	codectest.Corrupt(t, cases, ErrCorruptLZ77, DecompressLZ77)
+}
+
+func TestLZ77Limit(t *testing.T) {
+	content := []byte(samIAm)
+	compressed := CompressLZ77(content, NewParseSettings())
+	codectest.Limit(t, content, compressed, ErrTooLargeLZ77, DecompressLZ77Limit)
+	codectest.ReaderLimit(t, content, compressed, ErrTooLargeLZ77, NewLZ77ReaderContext)
+}
+
+func TestLZ77Writer(t *testing.T) {
+	codectest.Writer(t, []byte(samIAm), 100, NewLZ77Writer, NewLZ77Reader)
+}
@@ -0,0 +1,144 @@
+package lz
+
+import (
+	"context"
+	"errors"
+	codec "github.com/go-compression/raisin/compressor/codec"
+	"io"
+)
+
+// lz78MaxEntries is the size of the LZ78 dictionary, once it's full both sides start over with just the empty phrase
+const lz78MaxEntries = 1 << 16
+
+var (
+	// ErrCorruptLZ78 is returned when decompressing LZ78 pairs that are cut short or name an entry the dictionary doesn't have yet
+	ErrCorruptLZ78 = errors.New("lz78: corrupt input")
+	// ErrTooLargeLZ78 is returned when LZ78 pairs decompress to more than the limit
+	ErrTooLargeLZ78 = errors.New("lz78: decompressed size exceeds limit")
+)
+
+// CompressLZ78 takes a slice of bytes and returns it encoded as the pairs of the LZ78 algorithm.
+// Rather than pointing back into a window, every pair is the index of a phrase in a dictionary followed by the byte that extends it,
+// and the extended phrase becomes the next entry. Entry 0 is the empty phrase.
+// An index is 1 byte while the dictionary has up to 256 entries and 2 bytes after that.
+func CompressLZ78(content []byte) []byte {
+	output, _ := compressLZ78(context.Background(), content)
+	return output
+}
+
+func compressLZ78(ctx context.Context, content []byte) ([]byte, error) {
+	output := make([]byte, 0, len(content)/2)
+	dictionary := make(map[int]int)
+	entries := 1
+	emit := func(index int, next byte) {
+		if entries > 256 {
+			output = append(output, byte(index>>8))
+		}
+		output = append(output, byte(index), next)
+		dictionary[index<<8|int(next)] = entries
+		entries++
+		if entries == lz78MaxEntries {
+			dictionary = make(map[int]int)
+			entries = 1
+		}
+	}
+
+	phrase, previous := 0, 0
+	for i, c := range content {
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if index, ok := dictionary[phrase<<8|int(c)]; ok {
+			previous = phrase
+			phrase = index
+			continue
+		}
+		emit(phrase, c)
+		phrase = 0
+	}
+	if phrase != 0 {
+		// The content ended inside a known phrase, which is written as the phrase before its last byte followed by that byte
+		emit(previous, content[len(content)-1])
+	}
+	return output, nil
+}
+
+// DecompressLZ78 takes a stream of LZ78 pairs and returns the decompressed contents
+func DecompressLZ78(content []byte) ([]byte, error) {
+	return DecompressLZ78Limit(content, 0)
+}
+
+// DecompressLZ78Limit is like DecompressLZ78 but returns ErrTooLargeLZ78 once the output would exceed limit bytes, a limit of 0 means no limit.
+func DecompressLZ78Limit(content []byte, limit int) ([]byte, error) {
+	return decompressLZ78(context.Background(), content, limit)
+}
+
+func decompressLZ78(ctx context.Context, content []byte, limit int) ([]byte, error) {
+	output := make([]byte, 0, 2*len(content))
+	// Every phrase is somewhere in the output already, so an entry is just where it starts and how long it is
+	starts := make([]int, lz78MaxEntries)
+	lengths := make([]int, lz78MaxEntries)
+	entries := 1
+	for i, checked := 0, 0; i < len(content); {
+		if i-checked >= checkInterval {
+			if err := ctx.Err(); err != nil {
+				return output, err
+			}
+			checked = i
+		}
+		index := 0
+		if entries > 256 {
+			if i+3 > len(content) {
+				return output, ErrCorruptLZ78
+			}
+			index = int(content[i])<<8 | int(content[i+1])
+			i += 2
+		} else {
+			if i+2 > len(content) {
+				return output, ErrCorruptLZ78
+			}
+			index = int(content[i])
+			i++
+		}
+		next := content[i]
+		i++
+
+		if index >= entries {
+			return output, ErrCorruptLZ78
+		}
+		if limit > 0 && len(output)+lengths[index]+1 > limit {
+			return output, ErrTooLargeLZ78
+		}
+		start := len(output)
+		output = append(output, output[starts[index]:starts[index]+lengths[index]]...)
+		output = append(output, next)
+		starts[entries] = start
+		lengths[entries] = lengths[index] + 1
+		entries++
+		if entries == lz78MaxEntries {
+			entries = 1
+		}
+	}
+	return output, nil
+}
+
+// NewLZ78Writer creates an io.WriteCloser object with an io.Writer that writes LZ78 pairs when closed
+func NewLZ78Writer(w io.Writer) io.WriteCloser {
+	return NewLZ78WriterContext(context.Background(), w, codec.Options{})
+}
+
+// NewLZ78WriterContext creates an io.WriteCloser like NewLZ78Writer that gives up once ctx is done
+func NewLZ78WriterContext(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
+	return &bufferedWriter{w: w, ctx: ctx, compress: compressLZ78}
+}
+
+// NewLZ78Reader creates an io.Reader object that decompresses LZ78 pairs from an io.Reader
+func NewLZ78Reader(r io.Reader) io.Reader {
+	return NewLZ78ReaderContext(context.Background(), r, codec.Options{})
+}
+
+// NewLZ78ReaderContext creates an io.Reader like NewLZ78Reader that gives up once ctx is done and fails with ErrTooLargeLZ78 instead of
+// decompressing more than opts.Limit bytes, a limit of 0 means no limit
+func NewLZ78ReaderContext(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+	return &bufferedReader{r: r, ctx: ctx, decompress: decompressLZ78, limit: opts.Limit}
+}
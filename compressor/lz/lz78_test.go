@@ -0,0 +1,45 @@
+package lz
+
+import (
+	"bytes"
+	"github.com/go-compression/raisin/internal/codectest"
+	"math/rand"
+	"testing"
+)
+
+func TestLZ78(t *testing.T) {
+	for name, content := range codectest.Inputs(100000) {
+		decompressed, err := DecompressLZ78(CompressLZ78(content))
+		if err != nil || !bytes.Equal(decompressed, content) {
+			t.Errorf("%s was not lossless: %v", name, err)
+		}
+	}
+}
+
+func TestLZ78DictionaryReset(t *testing.T) {
+	// Random bytes over a small alphabet fill the dictionary several times over
+	r := rand.New(rand.NewSource(2))
+	content := make([]byte, 1000000)
+	for i := range content {
+		content[i] = byte('a' + r.Intn(4))
+	}
+	decompressed, err := DecompressLZ78(CompressLZ78(content))
+	if err != nil || !bytes.Equal(decompressed, content) {
+		t.Errorf("Content that fills the dictionary was not lossless: %v", err)
+	}
+}
+
+func TestLZ78Corrupt(t *testing.T) {
+	cases := map[string][]byte{
+		"unknown entry": {0, 'a', 2, 'b'},
+		"truncated":     {0, 'a', 1},
+	}
+	codectest.Corrupt(t, cases, ErrCorruptLZ78, DecompressLZ78)
+}
+
+func TestLZ78Limit(t *testing.T) {
+	content := []byte(samIAm)
+	compressed := CompressLZ78(content)
+	codectest.Limit(t, content, compressed, ErrTooLargeLZ78, DecompressLZ78Limit)
+	codectest.ReaderLimit(t, content, compressed, ErrTooLargeLZ78, NewLZ78ReaderContext)
+}
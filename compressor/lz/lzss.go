@@ -2,8 +2,11 @@ package lz
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	pb "github.com/cheggaaa/pb/v3"
+	codec "github.com/go-compression/raisin/compressor/codec"
 	"io"
 	"io/ioutil"
 	"sort"
@@ -30,6 +33,8 @@ type Writer struct {
 	windowSize     int
 	useProgressBar bool
 	w              io.Writer
+	ctx            context.Context
+	dict           []byte
 }
 
 const DefaultWindowSize = 4096
@@ -39,6 +44,16 @@ func NewWriter(w io.Writer) io.WriteCloser {
 	return z
 }
 
+// NewWriterContext creates an io.WriteCloser like NewWriter that gives up once ctx is done and draws no progress bar. With opts.Dict
+// it starts with the dictionary in its search buffer, so that even the first bytes written can be references into it.
+func NewWriterContext(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
+	z, _ := NewWriterLevel(w, DefaultWindowSize)
+	z.useProgressBar = false
+	z.ctx = ctx
+	z.dict = opts.Dict
+	return z
+}
+
 func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
 	if level < 0 {
 		return nil, fmt.Errorf("lzss: invalid compression level: %d", level)
@@ -47,11 +62,15 @@ func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
 	z.windowSize = level
 	z.useProgressBar = true
 	z.w = w
+	z.ctx = context.Background()
 	return z, nil
 }
 
 func (writer *Writer) Write(data []byte) (n int, err error) {
-	compressed := CompressAsync(data, writer.useProgressBar, writer.windowSize)
+	compressed, err := compressAsync(writer.ctx, data, writer.dict, writer.useProgressBar, writer.windowSize)
+	if err != nil {
+		return 0, err
+	}
 	writer.w.Write(compressed)
 	return len(compressed), nil
 }
@@ -65,6 +84,9 @@ type Reader struct {
 	compressed   []byte
 	decompressed []byte
 	pos          int
+	limit        int
+	dict         []byte
+	ctx          context.Context
 }
 
 // func (r *Reader) Init(r *io.Reader) {
@@ -78,7 +100,10 @@ func (r *Reader) Read(content []byte) (n int, err error) {
 		if err != nil {
 			return 0, err
 		}
-		r.decompressed = Decompress(compressed, true)
+		r.decompressed, err = decompressDict(r.ctx, compressed, r.dict, r.limit)
+		if err != nil {
+			return 0, err
+		}
 	}
 	bytesToWriteOut := len(r.decompressed[r.pos:])
 	if len(content) < bytesToWriteOut {
@@ -96,8 +121,17 @@ func (r *Reader) Read(content []byte) (n int, err error) {
 }
 
 func NewReader(r io.Reader) io.Reader {
+	return NewReaderContext(context.Background(), r, codec.Options{})
+}
+
+// NewReaderContext creates an io.Reader like NewReader that gives up once ctx is done and fails with ErrTooLarge instead of
+// decompressing more than opts.Limit bytes, a limit of 0 means no limit. A stream written with a dictionary needs the same opts.Dict.
+func NewReaderContext(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
 	z := new(Reader)
 	z.r = r
+	z.limit = opts.Limit
+	z.dict = opts.Dict
+	z.ctx = ctx
 	return z
 }
 
@@ -107,18 +141,28 @@ func (r *Reader) Close() error {
 
 // CompressAsync is similar to Compress except that it uses goroutines to run as multi-threaded as possible
 func CompressAsync(fileContents []byte, useProgressBar bool, maxSearchBufferLength int) []byte {
-	fileContents = EncodeOpeningSymbols(fileContents)
+	compressed, _ := compressAsync(context.Background(), fileContents, nil, useProgressBar, maxSearchBufferLength)
+	return compressed
+}
+
+// compressAsync is CompressAsync with a dictionary, which is put in front of the contents so it can be referenced but is never output itself
+func compressAsync(ctx context.Context, fileContents []byte, dict []byte, useProgressBar bool, maxSearchBufferLength int) ([]byte, error) {
+	start := len(EncodeOpeningSymbols(dict))
+	fileContents = EncodeOpeningSymbols(append(append([]byte{}, dict...), fileContents...))
 	var waitgroup sync.WaitGroup
 
-	bar := pb.New(len(fileContents))
-	bar.Set(pb.Bytes, true)
-	bar.Start()
+	bar := pb.New(len(fileContents) - start)
+	if useProgressBar {
+		bar.Set(pb.Bytes, true)
+		bar.Start()
+		defer bar.Finish()
+	}
 
-	output := make([](chan Reference), len(fileContents))
+	output := make([](chan Reference), len(fileContents)-start)
 
-	for i := 0; i < len(fileContents); i++ {
+	for i := start; i < len(fileContents); i++ {
 		waitgroup.Add(1)
-		output[i] = make(chan Reference, 1)
+		output[i-start] = make(chan Reference, 1)
 
 		startIndex := 0
 		searchBuffer := fileContents[:i]
@@ -126,11 +170,15 @@ func CompressAsync(fileContents []byte, useProgressBar bool, maxSearchBufferLeng
 			startIndex = len(searchBuffer) - maxSearchBufferLength
 		}
 
-		go compressorWorkerAsync(&waitgroup, output[i], searchBuffer[startIndex:], []byte{fileContents[i]}, fileContents[i:], bar)
+		go compressorWorkerAsync(ctx, &waitgroup, output[i-start], searchBuffer[startIndex:], []byte{fileContents[i]}, fileContents[i:], bar)
 	}
 
 	waitgroup.Wait()
 
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
 	var finalOutput []byte
 	var ignoreNextChars int
 	for _, i := range output {
@@ -150,12 +198,18 @@ func CompressAsync(fileContents []byte, useProgressBar bool, maxSearchBufferLeng
 		}
 	}
 
-	return finalOutput
+	return finalOutput, nil
 }
 
-func compressorWorkerAsync(waitgroup *sync.WaitGroup, output chan<- Reference, searchBuffer []byte, scanBytes []byte, nextBytes []byte, bar *pb.ProgressBar) {
+func compressorWorkerAsync(ctx context.Context, waitgroup *sync.WaitGroup, output chan<- Reference, searchBuffer []byte, scanBytes []byte, nextBytes []byte, bar *pb.ProgressBar) {
 	defer waitgroup.Done()
 
+	if ctx.Err() != nil {
+		// Compression was cancelled so skip the search, the output is discarded
+		output <- Reference{}
+		return
+	}
+
 	out := compressorWorker(searchBuffer, scanBytes, nextBytes[1:], bar)
 
 	output <- out
@@ -319,23 +373,58 @@ func getEncoding(relativePointer int, relativeOffset int) []byte {
 	return []byte(Opening + strconv.Itoa(relativePointer) + Separator + strconv.Itoa(relativeOffset) + Closing)
 }
 
+var (
+	// ErrCorrupt is returned when decompressing a stream that has a malformed or out of range reference
+	ErrCorrupt = errors.New("lzss: corrupt input")
+	// ErrTooLarge is returned when a stream decompresses to more than the limit
+	ErrTooLarge = errors.New("lzss: decompressed size exceeds limit")
+)
+
 // Decompress decompressed the file contents and returns the decompressed contents as a slice of bytes
+//
+// Deprecated: a corrupt stream is decompressed up to the first invalid reference without an error, use DecompressLimit instead.
 func Decompress(fileContents []byte, useProgressBar bool) []byte {
-	searchBuffer := make([]byte, 0)
+	output, _ := DecompressLimit(fileContents, useProgressBar, 0)
+	return output
+}
+
+// DecompressLimit is like Decompress but returns ErrCorrupt for malformed references and ErrTooLarge once the output would exceed limit bytes.
+// A limit of 0 means no limit.
+func DecompressLimit(fileContents []byte, useProgressBar bool, limit int) ([]byte, error) {
+	return DecompressDict(fileContents, nil, limit)
+}
+
+// DecompressDict is like DecompressLimit for a stream compressed with a dictionary, which references can point back into
+func DecompressDict(fileContents []byte, dict []byte, limit int) ([]byte, error) {
+	return decompressDict(context.Background(), fileContents, dict, limit)
+}
+
+func decompressDict(ctx context.Context, fileContents []byte, dict []byte, limit int) ([]byte, error) {
+	searchBuffer := EncodeOpeningSymbols(dict)
 	output := make([]byte, 0)
 
+	// Escaping at most doubles the size of the output so this bounds memory before the opening symbols are decoded
+	rawLimit := 2 * limit
+
 	pointer := 0
 	pointerBytes := make([]byte, 0)
 	offset := 0
 	offsetBytes := make([]byte, 0)
 	lookingFor := Opening
-	for _, fileByte := range fileContents {
+	var err error
+	for i, fileByte := range fileContents {
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return DecodeOpeningSymbols(output), ctx.Err()
+		}
 		if lookingFor == Opening && string(fileByte) == Opening {
 			lookingFor = Separator
 		} else if lookingFor == Separator {
 			if string(fileByte) == Separator {
 				lookingFor = Closing
-				pointer, _ = strconv.Atoi(string(pointerBytes))
+				pointer, err = strconv.Atoi(string(pointerBytes))
+				if err != nil {
+					return DecodeOpeningSymbols(output), ErrCorrupt
+				}
 				pointerBytes = make([]byte, 0)
 			} else {
 				pointerBytes = append(pointerBytes, fileByte)
@@ -343,9 +432,19 @@ func Decompress(fileContents []byte, useProgressBar bool) []byte {
 		} else if lookingFor == Closing {
 			if string(fileByte) == Closing {
 				lookingFor = Opening
-				offset, _ = strconv.Atoi(string(offsetBytes))
+				offset, err = strconv.Atoi(string(offsetBytes))
+				if err != nil {
+					return DecodeOpeningSymbols(output), ErrCorrupt
+				}
 				offsetBytes = make([]byte, 0)
 
+				// References can only point back into what has been decoded and can't overlap the bytes they produce
+				if pointer < 1 || pointer > len(searchBuffer) || offset < 0 || offset > pointer {
+					return DecodeOpeningSymbols(output), ErrCorrupt
+				}
+				if limit > 0 && len(output)+offset > rawLimit {
+					return DecodeOpeningSymbols(output), ErrTooLarge
+				}
 				absolutePointer := len(searchBuffer) - pointer
 				slice := searchBuffer[absolutePointer : absolutePointer+offset]
 
@@ -355,12 +454,52 @@ func Decompress(fileContents []byte, useProgressBar bool) []byte {
 				offsetBytes = append(offsetBytes, fileByte)
 			}
 		} else {
+			if limit > 0 && len(output) >= rawLimit {
+				return DecodeOpeningSymbols(output), ErrTooLarge
+			}
 			output = append(output, fileByte)
 			searchBuffer = append(searchBuffer, fileByte)
 		}
 	}
 	output = DecodeOpeningSymbols(output)
-	return output
+	if lookingFor != Opening {
+		// The stream ended part way through a reference
+		return output, ErrCorrupt
+	}
+	if limit > 0 && len(output) > limit {
+		return output[:limit], ErrTooLarge
+	}
+	return output, nil
+}
+
+// Stats walks a compressed stream without decompressing it and returns the number of references, the number of literal bytes and the largest reference pointer used.
+func Stats(fileContents []byte) (references int, literals int, maxPointer int) {
+	lookingFor := Opening
+	pointerBytes := make([]byte, 0)
+	for _, fileByte := range fileContents {
+		if lookingFor == Opening && string(fileByte) == Opening {
+			lookingFor = Separator
+		} else if lookingFor == Separator {
+			if string(fileByte) == Separator {
+				lookingFor = Closing
+				pointer, _ := strconv.Atoi(string(pointerBytes))
+				if pointer > maxPointer {
+					maxPointer = pointer
+				}
+				pointerBytes = make([]byte, 0)
+			} else {
+				pointerBytes = append(pointerBytes, fileByte)
+			}
+		} else if lookingFor == Closing {
+			if string(fileByte) == Closing {
+				lookingFor = Opening
+				references++
+			}
+		} else {
+			literals++
+		}
+	}
+	return references, literals, maxPointer
 }
 
 const EncodedOpening = 0xff
@@ -1,13 +1,18 @@
 package lz
 
 import (
+	"bytes"
+	"context"
+	codec "github.com/go-compression/raisin/compressor/codec"
+	"github.com/go-compression/raisin/internal/codectest"
+	"io/ioutil"
 	"reflect"
 	"testing"
 )
 
 func TestCompressRecursive(t *testing.T) {
 	compressed := CompressRecursive([]byte(samIAm), false, 8192)
-	decompressed := Decompress(compressed, false)
+	decompressed, _ := DecompressLimit(compressed, false, 0)
 	if !reflect.DeepEqual(decompressed, []byte(samIAm)) {
 		// t.Errorf(
 		// 	"Compress was not lossless, Expected:\n%s\n\nGot:\n%s",
@@ -24,8 +29,8 @@ func TestCompressRecursive(t *testing.T) {
 
 func TestCompress(t *testing.T) {
 	compressed := Compress([]byte(samIAm), false, 8192)
-	decompressed := Decompress(compressed, false)
-	if !reflect.DeepEqual(decompressed, []byte(samIAm)) {
+	decompressed, err := DecompressLimit(compressed, false, 0)
+	if err != nil || !reflect.DeepEqual(decompressed, []byte(samIAm)) {
 		t.Errorf(
 			"Compress was not lossless, Expected:\n%s\n\nGot:\n%s",
 			samIAm,
@@ -36,8 +41,8 @@ func TestCompress(t *testing.T) {
 
 func TestCompressAsync(t *testing.T) {
 	compressed := CompressAsync([]byte(samIAm), false, 8192)
-	decompressed := Decompress(compressed, false)
-	if !reflect.DeepEqual(decompressed, []byte(samIAm)) {
+	decompressed, err := DecompressLimit(compressed, false, 0)
+	if err != nil || !reflect.DeepEqual(decompressed, []byte(samIAm)) {
 		t.Errorf(
 			"Compress was not lossless, Expected:\n%s\n\nGot:\n%s",
 			samIAm,
@@ -46,6 +51,93 @@ func TestCompressAsync(t *testing.T) {
 	}
 }
 
+func TestWriterContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var b bytes.Buffer
+	w := NewWriterContext(ctx, &b, codec.Options{})
+	if _, err := w.Write([]byte(samIAm)); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from a cancelled writer, got %v", err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("Cancelled writer wrote %d bytes", b.Len())
+	}
+}
+
+func TestDecompressLimitCorrupt(t *testing.T) {
+	for _, c := range []string{"<1,1>", "ab<3,1>", "ab<1,2>", "ab<0,0>", "ab<-1,1>", "ab<x,1>", "ab<1,y>", "ab<1", "ab<1,1"} {
+		if _, err := DecompressLimit([]byte(c), false, 0); err != ErrCorrupt {
+			t.Errorf("Expected ErrCorrupt decompressing %q, got %v", c, err)
+		}
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	content := []byte(samIAm)
+	codectest.Limit(t, content, Compress(content, false, 4096), ErrTooLarge, func(compressed []byte, limit int) ([]byte, error) {
+		return DecompressLimit(compressed, false, limit)
+	})
+}
+
+func TestCompressDict(t *testing.T) {
+	// A message too short to have any repeats of its own can still reference the dictionary, escaped symbols included
+	dict := []byte(`{"id": 0, "name": "<unknown>", "status": "active", "tags": ["a", "b"]}`)
+	message := []byte(`{"id": 7, "name": "<unknown>", "status": "active", "tags": ["b"]}`)
+	var b bytes.Buffer
+	w := NewWriterContext(context.Background(), &b, codec.Options{Dict: dict})
+	w.Write(message)
+	w.Close()
+	if plain := CompressAsync(message, false, DefaultWindowSize); b.Len() >= len(plain)/2 {
+		t.Errorf("Compressed to %d bytes with a dictionary, %d without", b.Len(), len(plain))
+	}
+	decompressed, err := ioutil.ReadAll(NewReaderContext(context.Background(), bytes.NewReader(b.Bytes()), codec.Options{Dict: dict}))
+	if err != nil || !bytes.Equal(decompressed, message) {
+		t.Errorf("Compressing with a dictionary was not lossless: %v", err)
+	}
+	if _, err := DecompressLimit(b.Bytes(), false, 0); err != ErrCorrupt {
+		t.Errorf("Expected ErrCorrupt decompressing references into a missing dictionary, got %v", err)
+	}
+}
+
+// escapingCases stress the escaping of the < and \\ symbols lzss uses to mark references.
+var escapingCases = []string{
+	"",
+	"<",
+	"\\",
+	"<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<",
+	"\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\",
+	"<\\<<\\\\<a<\\b\\<\\\\\\>><<<",
+	"<1,2>\\<3,4>abc<1,2>\\<3,4>abc",
+}
+
+func TestCompressEscaping(t *testing.T) {
+	for _, c := range escapingCases {
+		for name, compress := range map[string]func([]byte, bool, int) []byte{"Compress": Compress, "CompressAsync": CompressAsync} {
+			decompressed, err := DecompressLimit(compress([]byte(c), false, 4096), false, 0)
+			if err != nil || !bytes.Equal(decompressed, []byte(c)) {
+				t.Errorf("%s was not lossless on %q, got %q", name, c, decompressed)
+			}
+		}
+	}
+}
+
+func FuzzCompress(f *testing.F) {
+	for _, c := range escapingCases {
+		f.Add([]byte(c))
+	}
+	f.Add([]byte(samIAm))
+	f.Fuzz(func(t *testing.T, content []byte) {
+		if len(content) > 1024 {
+			// Long repeated patterns take seconds to compress
+			return
+		}
+		decompressed, err := DecompressLimit(CompressAsync(content, false, 4096), false, 0)
+		if err != nil || !bytes.Equal(decompressed, content) {
+			t.Errorf("Compress was not lossless on %q, got %q", content, decompressed)
+		}
+	})
+}
+
 const samIAm = `"GREEN EGGS AND HAM" (by Doctor Seuss) 
 
 I AM SAM. I AM SAM. SAM I AM.
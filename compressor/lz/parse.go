@@ -0,0 +1,151 @@
+package lz
+
+import "context"
+
+// Token represents either a literal byte or a back reference to Length bytes starting Distance bytes before it, as returned by Parse.
+// A Length of 0 means the token is the literal.
+type Token struct {
+	Literal  byte
+	Length   int
+	Distance int
+}
+
+// ParseSettings represents an object that can be used to modify how Parse searches for matches
+// WindowSize is how far back matches may start, MinMatch and MaxMatch bound their length (MinMatch is at least 3),
+// MaxChain is how many earlier positions with the same prefix are compared and Lazy delays a match by a byte if the next one is longer.
+type ParseSettings struct {
+	WindowSize int
+	MinMatch   int
+	MaxMatch   int
+	MaxChain   int
+	Lazy       bool
+}
+
+// NewParseSettings returns the default settings for Parse as a ParseSettings object, the limits of DEFLATE
+func NewParseSettings() ParseSettings {
+	s := ParseSettings{}
+	s.WindowSize = 32 * 1024
+	s.MinMatch = 3
+	s.MaxMatch = 258
+	s.MaxChain = 128
+	s.Lazy = true
+	return s
+}
+
+const parseHashBits = 15
+
+// matchFinder finds earlier occurrences of the bytes at a position through a hash chain of every 3 byte prefix.
+// Positions have to be inserted in order before they can be found as the start of a match.
+type matchFinder struct {
+	content  []byte
+	settings ParseSettings
+	minMatch int
+	head     []int
+	prev     []int
+}
+
+func newMatchFinder(content []byte, settings ParseSettings) *matchFinder {
+	m := &matchFinder{content: content, settings: settings}
+	m.minMatch = settings.MinMatch
+	if m.minMatch < 3 {
+		m.minMatch = 3
+	}
+	m.head = make([]int, 1<<parseHashBits)
+	for i := range m.head {
+		m.head[i] = -1
+	}
+	m.prev = make([]int, len(content))
+	return m
+}
+
+func (m *matchFinder) hash(i int) uint32 {
+	return (uint32(m.content[i])<<16 | uint32(m.content[i+1])<<8 | uint32(m.content[i+2])) * 2654435761 >> (32 - parseHashBits)
+}
+
+func (m *matchFinder) insert(i int) {
+	if i+3 > len(m.content) {
+		return
+	}
+	h := m.hash(i)
+	m.prev[i] = m.head[h]
+	m.head[h] = i
+}
+
+// find returns the length and distance of the longest match for the bytes at i of no more than maxLength bytes, or 0, 0 if there's none of at least MinMatch.
+// Matches may overlap i.
+func (m *matchFinder) find(i int, maxLength int) (int, int) {
+	if maxLength < m.minMatch || i+m.minMatch > len(m.content) {
+		return 0, 0
+	}
+	bestLength, bestDistance := 0, 0
+	chain := 0
+	for j := m.head[m.hash(i)]; j >= 0 && i-j <= m.settings.WindowSize && chain < m.settings.MaxChain; j = m.prev[j] {
+		chain++
+		length := 0
+		for length < maxLength && m.content[j+length] == m.content[i+length] {
+			length++
+		}
+		if length > bestLength {
+			bestLength, bestDistance = length, i-j
+			if length == maxLength {
+				break
+			}
+		}
+	}
+	if bestLength < m.minMatch {
+		return 0, 0
+	}
+	return bestLength, bestDistance
+}
+
+// Parse finds the same kind of back references as Compress but returns them as tokens rather than the textual <pointer,length> encoding,
+// so that they can be entropy coded by another format such as DEFLATE. Unlike Compress, a reference may overlap the bytes it produces
+// which lets runs of a repeated pattern be encoded with a single reference.
+func Parse(content []byte, settings ParseSettings) []Token {
+	tokens, _ := ParseContext(context.Background(), content, settings)
+	return tokens
+}
+
+// ParseContext is like Parse but stops and returns the context's error once ctx is done.
+func ParseContext(ctx context.Context, content []byte, settings ParseSettings) ([]Token, error) {
+	tokens := make([]Token, 0, len(content)/2)
+	m := newMatchFinder(content, settings)
+	find := func(i int) (int, int) {
+		maxLength := len(content) - i
+		if settings.MaxMatch > 0 && maxLength > settings.MaxMatch {
+			maxLength = settings.MaxMatch
+		}
+		return m.find(i, maxLength)
+	}
+
+	for i, checked := 0, 0; i < len(content); {
+		if i-checked >= checkInterval {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			checked = i
+		}
+		length, distance := find(i)
+		if length == 0 {
+			m.insert(i)
+			tokens = append(tokens, Token{Literal: content[i]})
+			i++
+			continue
+		}
+		m.insert(i)
+		if settings.Lazy && i+1 < len(content) {
+			// If the match starting at the next byte is longer it's worth spending a literal on this one
+			if nextLength, _ := find(i + 1); nextLength > length {
+				tokens = append(tokens, Token{Literal: content[i]})
+				i++
+				continue
+			}
+		}
+		for k := i + 1; k < i+length; k++ {
+			m.insert(k)
+		}
+		tokens = append(tokens, Token{Length: length, Distance: distance})
+		i += length
+	}
+	return tokens, nil
+}
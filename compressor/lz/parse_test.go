@@ -0,0 +1,53 @@
+package lz
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// expand rebuilds the content a sequence of tokens represents, copying references byte by byte so they may overlap.
+func expand(tokens []Token) []byte {
+	var output []byte
+	for _, token := range tokens {
+		if token.Length == 0 {
+			output = append(output, token.Literal)
+			continue
+		}
+		start := len(output) - token.Distance
+		for i := 0; i < token.Length; i++ {
+			output = append(output, output[start+i])
+		}
+	}
+	return output
+}
+
+func TestParse(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := make([]byte, 10000)
+	r.Read(random)
+	for _, content := range [][]byte{{}, {'a'}, []byte("aaaaaaaaaaaaaaaaaaaa"), []byte(samIAm), random} {
+		for _, lazy := range []bool{false, true} {
+			settings := NewParseSettings()
+			settings.Lazy = lazy
+			tokens := Parse(content, settings)
+			if !bytes.Equal(expand(tokens), content) {
+				t.Errorf("Parse with lazy=%t did not reproduce %d bytes", lazy, len(content))
+			}
+			for _, token := range tokens {
+				if token.Length != 0 && (token.Length < settings.MinMatch || token.Length > settings.MaxMatch ||
+					token.Distance < 1 || token.Distance > settings.WindowSize) {
+					t.Errorf("Parse returned a reference outside the settings: %+v", token)
+				}
+			}
+		}
+	}
+}
+
+func TestParseRun(t *testing.T) {
+	// A run should be a literal followed by references that overlap themselves
+	tokens := Parse(bytes.Repeat([]byte{'a'}, 1000), NewParseSettings())
+	if len(tokens) > 6 || tokens[0].Length != 0 || tokens[1].Distance != 1 {
+		t.Errorf("Expected a run to be a literal and a few overlapping references, got %+v", tokens)
+	}
+}
@@ -0,0 +1,56 @@
+package lz
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+)
+
+// checkInterval is how many bytes are coded between checks of whether the context is done
+const checkInterval = 4096
+
+// bufferedWriter buffers everything written to it and writes it out as a single compressed stream when closed, for the LZ77, LZ78 and literals codecs
+type bufferedWriter struct {
+	w        io.Writer
+	ctx      context.Context
+	compress func(context.Context, []byte) ([]byte, error)
+	buffer   bytes.Buffer
+}
+
+func (writer *bufferedWriter) Write(data []byte) (n int, err error) {
+	return writer.buffer.Write(data)
+}
+
+func (writer *bufferedWriter) Close() error {
+	compressed, err := writer.compress(writer.ctx, writer.buffer.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = writer.w.Write(compressed)
+	return err
+}
+
+// bufferedReader decompresses everything from an io.Reader on the first call to Read, for the LZ77, LZ78 and literals codecs
+type bufferedReader struct {
+	r            io.Reader
+	ctx          context.Context
+	decompress   func(context.Context, []byte, int) ([]byte, error)
+	limit        int
+	decompressed *bytes.Reader
+}
+
+func (r *bufferedReader) Read(content []byte) (n int, err error) {
+	if r.decompressed == nil {
+		compressed, err := ioutil.ReadAll(r.r)
+		if err != nil {
+			return 0, err
+		}
+		decompressed, err := r.decompress(r.ctx, compressed, r.limit)
+		if err != nil {
+			return 0, err
+		}
+		r.decompressed = bytes.NewReader(decompressed)
+	}
+	return r.decompressed.Read(content)
+}
@@ -0,0 +1,194 @@
+package lz4
+
+import (
+	"encoding/binary"
+)
+
+const (
+	minMatch = 4
+	// The last 5 bytes of a block are always literals and the last match has to start at least 12 bytes before the end
+	lastLiterals = 5
+	mfLimit      = 12
+	maxOffset    = 1<<16 - 1
+	hashLog      = 16
+	// skipTrigger makes the encoder step further the longer it goes without finding a match, so incompressible data is skipped over quickly
+	skipTrigger = 6
+)
+
+// hashTable maps the hash of 5 bytes to one more than the last position they were seen at, so that 0 means empty.
+type hashTable [1 << hashLog]int32
+
+// hash returns the hash of the low 5 bytes of sequence. Matches only need 4 bytes but hashing 5 like the reference
+// encoder does on 64-bit machines means fewer short matches crowd out longer ones.
+func hash(sequence uint64) uint32 {
+	return uint32((sequence << 24) * 889523592379 >> (64 - hashLog))
+}
+
+// CompressBlock takes a slice of bytes and returns it as a single LZ4 block.
+// A block doesn't store its own size, so it's up to the caller to keep track of how large the decompressed block is.
+func CompressBlock(src []byte) []byte {
+	return appendBlock(nil, src, new(hashTable))
+}
+
+// appendBlock appends the LZ4 block of src to dst. Matches are found greedily through a hash table that remembers only the
+// last position of every 5 byte sequence, which is what makes LZ4 fast at the cost of its ratio.
+func appendBlock(dst []byte, src []byte, table *hashTable) []byte {
+	anchor := 0
+	if len(src) > mfLimit {
+		*table = hashTable{}
+		matchLimit := len(src) - lastLiterals
+		lastStart := len(src) - mfLimit
+		for i := 0; i <= lastStart; {
+			sequence := binary.LittleEndian.Uint64(src[i:])
+			h := hash(sequence)
+			candidate := int(table[h]) - 1
+			table[h] = int32(i + 1)
+			if candidate < 0 || i-candidate > maxOffset || binary.LittleEndian.Uint32(src[candidate:]) != uint32(sequence) {
+				i += 1 + (i-anchor)>>skipTrigger
+				continue
+			}
+
+			// Extend the match backwards into the pending literals and then forwards as far as the block allows
+			for i > anchor && candidate > 0 && src[i-1] == src[candidate-1] {
+				i--
+				candidate--
+			}
+			length := minMatch
+			for i+length < matchLimit && src[candidate+length] == src[i+length] {
+				length++
+			}
+			dst = appendSequence(dst, src[anchor:i], i-candidate, length)
+			i += length
+			anchor = i
+			if i-2 <= lastStart {
+				table[hash(binary.LittleEndian.Uint64(src[i-2:]))] = int32(i - 2 + 1)
+			}
+		}
+	}
+	return appendLiterals(dst, src[anchor:])
+}
+
+// appendSequence appends a sequence of literals followed by a match.
+// Both lengths are stored in the 4 bit halves of the token byte with any excess following as a run of bytes that ends with one under 255.
+func appendSequence(dst []byte, literals []byte, offset int, length int) []byte {
+	dst = appendToken(dst, len(literals), length-minMatch)
+	dst = append(dst, literals...)
+	dst = append(dst, byte(offset), byte(offset>>8))
+	if length-minMatch >= 15 {
+		dst = appendLength(dst, length-minMatch-15)
+	}
+	return dst
+}
+
+// appendLiterals appends the last sequence of a block, which has literals and no match.
+func appendLiterals(dst []byte, literals []byte) []byte {
+	dst = appendToken(dst, len(literals), 0)
+	return append(dst, literals...)
+}
+
+func appendToken(dst []byte, literals int, length int) []byte {
+	token := byte(0)
+	if literals >= 15 {
+		token = 15 << 4
+	} else {
+		token = byte(literals) << 4
+	}
+	if length >= 15 {
+		token |= 15
+	} else {
+		token |= byte(length)
+	}
+	dst = append(dst, token)
+	if literals >= 15 {
+		dst = appendLength(dst, literals-15)
+	}
+	return dst
+}
+
+func appendLength(dst []byte, n int) []byte {
+	for n >= 255 {
+		dst = append(dst, 255)
+		n -= 255
+	}
+	return append(dst, byte(n))
+}
+
+// DecompressBlock takes a single LZ4 block and returns its contents, failing with ErrTooLarge if they are larger than maxSize bytes.
+func DecompressBlock(src []byte, maxSize int) ([]byte, error) {
+	return decodeBlock(nil, src, 0, maxSize)
+}
+
+// decodeBlock appends the contents of an LZ4 block to dst. Matches may reach back as far as dst[windowStart:],
+// which lets linked blocks in a frame refer to the blocks before them. The block fails with ErrTooLarge once dst grows past maxSize bytes.
+func decodeBlock(dst []byte, src []byte, windowStart int, maxSize int) ([]byte, error) {
+	readLength := func(i int, n int) (int, int, error) {
+		for {
+			if i >= len(src) {
+				return i, n, ErrCorrupt
+			}
+			b := src[i]
+			i++
+			n += int(b)
+			if b != 255 {
+				return i, n, nil
+			}
+		}
+	}
+
+	i := 0
+	for {
+		if i >= len(src) {
+			return dst, ErrCorrupt
+		}
+		token := src[i]
+		i++
+
+		literals := int(token >> 4)
+		var err error
+		if literals == 15 {
+			if i, literals, err = readLength(i, literals); err != nil {
+				return dst, err
+			}
+		}
+		if literals > len(src)-i {
+			return dst, ErrCorrupt
+		}
+		if len(dst)+literals > maxSize {
+			return dst, ErrTooLarge
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+		if i == len(src) {
+			// The last sequence is the only one without a match
+			return dst, nil
+		}
+
+		if i+2 > len(src) {
+			return dst, ErrCorrupt
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		if offset == 0 || offset > len(dst)-windowStart {
+			return dst, ErrCorrupt
+		}
+		length := int(token & 15)
+		if length == 15 {
+			if i, length, err = readLength(i, length); err != nil {
+				return dst, err
+			}
+		}
+		length += minMatch
+		if len(dst)+length > maxSize {
+			return dst, ErrTooLarge
+		}
+		start := len(dst) - offset
+		if offset >= length {
+			dst = append(dst, dst[start:start+length]...)
+		} else {
+			// The match overlaps the bytes it produces so it has to be copied a byte at a time
+			for k := 0; k < length; k++ {
+				dst = append(dst, dst[start+k])
+			}
+		}
+	}
+}
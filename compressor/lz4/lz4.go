@@ -0,0 +1,442 @@
+// Package lz4 implements the LZ4 block and frame formats, trading compression ratio for speed.
+// Frames it writes can be read by the lz4 command line tool and it reads frames written by it, including linked blocks and checksums.
+package lz4
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	codec "github.com/go-compression/raisin/compressor/codec"
+	xxhash "github.com/go-compression/raisin/compressor/xxhash"
+)
+
+// Settings represents an object that can be used to modify how frames are written
+// BlockSize is the largest block (64 KiB, 256 KiB, 1 MiB or 4 MiB, other sizes are rounded up), BlockChecksum and ContentChecksum
+// add an xxHash32 of every block and of the whole content, and ContentSize stores the size of the content in the header when it's known up front.
+type Settings struct {
+	BlockSize       int
+	BlockChecksum   bool
+	ContentChecksum bool
+	ContentSize     bool
+}
+
+// NewSettings returns the default settings as a Settings object, the same as the lz4 command line tool
+func NewSettings() Settings {
+	s := Settings{}
+	s.BlockSize = 4 << 20
+	s.BlockChecksum = false
+	s.ContentChecksum = true
+	s.ContentSize = false
+	return s
+}
+
+const (
+	frameMagic         = 0x184d2204
+	skippableMagic     = 0x184d2a50
+	skippableMagicMask = 0xfffffff0
+	version            = 1
+
+	flagBlockIndependence = 1 << 5
+	flagBlockChecksum     = 1 << 4
+	flagContentSize       = 1 << 3
+	flagContentChecksum   = 1 << 2
+	flagDictionaryID      = 1 << 0
+
+	uncompressedBlock = 1 << 31
+	// windowSize is how far back a match in a linked block can reach into the blocks before it
+	windowSize = 64 << 10
+)
+
+var (
+	// ErrCorrupt is returned when decompressing a frame or block that is malformed
+	ErrCorrupt = errors.New("lz4: corrupt input")
+	// ErrChecksum is returned when a header, block or content checksum doesn't match
+	ErrChecksum = errors.New("lz4: checksum mismatch")
+	// ErrTooLarge is returned when a stream decompresses to more than the limit
+	ErrTooLarge = errors.New("lz4: decompressed size exceeds limit")
+	// ErrDictionary is returned for frames that need a preset dictionary, which isn't supported
+	ErrDictionary = errors.New("lz4: preset dictionaries are not supported")
+)
+
+// blockSizes maps the block size IDs of a frame header to the block sizes they stand for
+var blockSizes = map[byte]int{4: 64 << 10, 5: 256 << 10, 6: 1 << 20, 7: 4 << 20}
+
+func blockSizeID(size int) byte {
+	for id := byte(4); id < 7; id++ {
+		if size <= blockSizes[id] {
+			return id
+		}
+	}
+	return 7
+}
+
+// Compress takes a slice of bytes and returns it as an LZ4 frame
+func Compress(content []byte, settings Settings) []byte {
+	var b bytes.Buffer
+	w := newWriter(&b, settings)
+	if settings.ContentSize {
+		w.contentSize = int64(len(content))
+	}
+	w.Write(content)
+	w.Close()
+	return b.Bytes()
+}
+
+// Decompress takes one or more LZ4 frames and returns their decompressed contents
+func Decompress(content []byte) ([]byte, error) {
+	return DecompressLimit(content, 0)
+}
+
+// DecompressLimit is like Decompress but returns ErrTooLarge once the output would exceed limit bytes, a limit of 0 means no limit.
+func DecompressLimit(content []byte, limit int) ([]byte, error) {
+	return ioutil.ReadAll(NewReaderContext(context.Background(), bytes.NewReader(content), codec.Options{Limit: limit}))
+}
+
+// Writer compresses everything written to it into an LZ4 frame, writing out a block every time a block's worth has been written
+type Writer struct {
+	w           io.Writer
+	settings    Settings
+	blockSize   int
+	contentSize int64
+	buffer      []byte
+	compressed  []byte
+	table       *hashTable
+	digest      *xxhash.Digest32
+	wroteHeader bool
+	err         error
+}
+
+// NewWriter creates an io.WriteCloser object with an io.Writer that writes LZ4 frames with the default settings
+func NewWriter(w io.Writer) io.WriteCloser {
+	return NewWriterSettings(w, NewSettings())
+}
+
+// NewWriterSettings creates an io.WriteCloser object with an io.Writer that writes LZ4 frames with the given settings.
+// The Writer doesn't know the size of the content in advance so it never stores it, whatever settings.ContentSize is.
+func NewWriterSettings(w io.Writer, settings Settings) io.WriteCloser {
+	return newWriter(w, settings)
+}
+
+func newWriter(w io.Writer, settings Settings) *Writer {
+	z := new(Writer)
+	z.w = w
+	z.settings = settings
+	z.blockSize = blockSizes[blockSizeID(settings.BlockSize)]
+	z.contentSize = -1
+	z.table = new(hashTable)
+	z.digest = xxhash.New32()
+	return z
+}
+
+func (writer *Writer) Write(data []byte) (n int, err error) {
+	for len(data) > 0 && writer.err == nil {
+		if writer.buffer == nil {
+			writer.buffer = make([]byte, 0, writer.blockSize)
+		}
+		copied := len(data)
+		if free := writer.blockSize - len(writer.buffer); copied > free {
+			copied = free
+		}
+		writer.buffer = append(writer.buffer, data[:copied]...)
+		data = data[copied:]
+		n += copied
+		if len(writer.buffer) == writer.blockSize {
+			writer.flush()
+		}
+	}
+	return n, writer.err
+}
+
+// Close writes out the last block, the end mark and the content checksum
+func (writer *Writer) Close() error {
+	if len(writer.buffer) > 0 || !writer.wroteHeader {
+		writer.flush()
+	}
+	out := []byte{0, 0, 0, 0}
+	if writer.settings.ContentChecksum {
+		out = appendUint32(out, writer.digest.Sum32())
+	}
+	writer.write(out)
+	return writer.err
+}
+
+// flush writes out the buffered content as a block, after the frame header if it hasn't been written yet.
+// Blocks that don't compress are stored as they are.
+func (writer *Writer) flush() {
+	if !writer.wroteHeader {
+		writer.writeHeader()
+	}
+	if len(writer.buffer) == 0 {
+		return
+	}
+	writer.digest.Write(writer.buffer)
+	writer.compressed = appendBlock(writer.compressed[:0], writer.buffer, writer.table)
+	data := writer.compressed
+	size := uint32(len(data))
+	if len(data) >= len(writer.buffer) {
+		data = writer.buffer
+		size = uint32(len(data)) | uncompressedBlock
+	}
+	out := appendUint32(nil, size)
+	out = append(out, data...)
+	if writer.settings.BlockChecksum {
+		out = appendUint32(out, xxhash.Sum32(data))
+	}
+	writer.write(out)
+	writer.buffer = writer.buffer[:0]
+}
+
+func (writer *Writer) writeHeader() {
+	writer.wroteHeader = true
+	flags := byte(version<<6 | flagBlockIndependence)
+	if writer.settings.BlockChecksum {
+		flags |= flagBlockChecksum
+	}
+	if writer.contentSize >= 0 {
+		flags |= flagContentSize
+	}
+	if writer.settings.ContentChecksum {
+		flags |= flagContentChecksum
+	}
+	descriptor := []byte{flags, blockSizeID(writer.blockSize) << 4}
+	if writer.contentSize >= 0 {
+		descriptor = appendUint64(descriptor, uint64(writer.contentSize))
+	}
+	out := appendUint32(nil, frameMagic)
+	out = append(out, descriptor...)
+	out = append(out, byte(xxhash.Sum32(descriptor)>>8))
+	writer.write(out)
+}
+
+func appendUint32(dst []byte, v uint32) []byte {
+	return append(dst, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(dst []byte, v uint64) []byte {
+	return appendUint32(appendUint32(dst, uint32(v)), uint32(v>>32))
+}
+
+func (writer *Writer) write(data []byte) {
+	if writer.err == nil {
+		_, writer.err = writer.w.Write(data)
+	}
+}
+
+// Reader decompresses LZ4 frames from an io.Reader a block at a time, skipping over any skippable frames between them
+type Reader struct {
+	r     io.Reader
+	ctx   context.Context
+	limit int
+	total int
+
+	inFrame         bool
+	readFrame       bool
+	independent     bool
+	blockChecksum   bool
+	contentChecksum bool
+	blockSize       int
+	contentSize     int64
+	frameSize       int64
+	digest          *xxhash.Digest32
+
+	// window holds the last block decoded, after as much of the blocks before it as a linked block can refer to
+	window  []byte
+	block   []byte
+	pending []byte
+	err     error
+}
+
+// NewReader creates an io.Reader object that decompresses LZ4 frames from an io.Reader
+func NewReader(r io.Reader) io.Reader {
+	return NewReaderContext(context.Background(), r, codec.Options{})
+}
+
+// NewReaderContext creates an io.Reader like NewReader that gives up between blocks once ctx is done and fails with ErrTooLarge
+// instead of decompressing more than opts.Limit bytes, a limit of 0 means no limit
+func NewReaderContext(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+	z := new(Reader)
+	z.r = r
+	z.ctx = ctx
+	z.limit = opts.Limit
+	z.digest = xxhash.New32()
+	return z
+}
+
+func (r *Reader) Read(content []byte) (n int, err error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.next()
+	}
+	n = copy(content, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// readFull reads exactly len(buf) bytes, a stream that ends early is corrupt
+func (r *Reader) readFull(buf []byte) error {
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrCorrupt
+		}
+		return err
+	}
+	return nil
+}
+
+// next decodes the next block into pending, reading the header of the next frame first if the last one has ended.
+// It returns io.EOF once the stream ends between frames.
+func (r *Reader) next() error {
+	if err := r.ctx.Err(); err != nil {
+		return err
+	}
+	var word [4]byte
+	if !r.inFrame {
+		if _, err := io.ReadFull(r.r, word[:]); err == io.EOF && r.readFrame {
+			return io.EOF
+		} else if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrCorrupt
+		} else if err != nil {
+			return err
+		}
+		magic := binary.LittleEndian.Uint32(word[:])
+		if magic&skippableMagicMask == skippableMagic {
+			if err := r.readFull(word[:]); err != nil {
+				return err
+			}
+			skipped, err := io.CopyN(ioutil.Discard, r.r, int64(binary.LittleEndian.Uint32(word[:])))
+			if err != nil || skipped != int64(binary.LittleEndian.Uint32(word[:])) {
+				return ErrCorrupt
+			}
+			return nil
+		} else if magic != frameMagic {
+			return ErrCorrupt
+		}
+		return r.readHeader()
+	}
+
+	if err := r.readFull(word[:]); err != nil {
+		return err
+	}
+	size := binary.LittleEndian.Uint32(word[:])
+	if size == 0 {
+		return r.endFrame()
+	}
+	uncompressed := size&uncompressedBlock != 0
+	size &^= uncompressedBlock
+	if int(size) > r.blockSize {
+		return ErrCorrupt
+	}
+	if cap(r.block) < int(size) {
+		r.block = make([]byte, size)
+	}
+	r.block = r.block[:size]
+	if err := r.readFull(r.block); err != nil {
+		return err
+	}
+	if r.blockChecksum {
+		if err := r.readFull(word[:]); err != nil {
+			return err
+		}
+		if xxhash.Sum32(r.block) != binary.LittleEndian.Uint32(word[:]) {
+			return ErrChecksum
+		}
+	}
+
+	// Linked blocks keep the end of the blocks before them around to refer back to
+	if r.independent {
+		r.window = r.window[:0]
+	} else if len(r.window) > windowSize {
+		r.window = append(r.window[:0], r.window[len(r.window)-windowSize:]...)
+	}
+	start := len(r.window)
+	maxSize, tooLarge := start+r.blockSize, ErrCorrupt
+	if r.limit > 0 && start+r.limit-r.total < maxSize {
+		maxSize, tooLarge = start+r.limit-r.total, ErrTooLarge
+	}
+	var err error
+	if uncompressed {
+		if start+len(r.block) > maxSize {
+			return tooLarge
+		}
+		r.window = append(r.window, r.block...)
+	} else if r.window, err = decodeBlock(r.window, r.block, 0, maxSize); err == ErrTooLarge {
+		return tooLarge
+	} else if err != nil {
+		return err
+	}
+
+	r.pending = r.window[start:]
+	r.digest.Write(r.pending)
+	r.total += len(r.pending)
+	r.frameSize += int64(len(r.pending))
+	return nil
+}
+
+func (r *Reader) readHeader() error {
+	descriptor := make([]byte, 2, 14)
+	if err := r.readFull(descriptor); err != nil {
+		return err
+	}
+	flags, bd := descriptor[0], descriptor[1]
+	if flags>>6 != version || flags&(1<<1) != 0 || bd&0x8f != 0 {
+		return ErrCorrupt
+	}
+	blockSize, ok := blockSizes[bd>>4]
+	if !ok {
+		return ErrCorrupt
+	}
+	if flags&flagContentSize != 0 {
+		descriptor = descriptor[:10]
+		if err := r.readFull(descriptor[2:]); err != nil {
+			return err
+		}
+	}
+	if flags&flagDictionaryID != 0 {
+		return ErrDictionary
+	}
+	var checksum [1]byte
+	if err := r.readFull(checksum[:]); err != nil {
+		return err
+	}
+	if byte(xxhash.Sum32(descriptor)>>8) != checksum[0] {
+		return ErrChecksum
+	}
+
+	r.inFrame = true
+	r.readFrame = true
+	r.independent = flags&flagBlockIndependence != 0
+	r.blockChecksum = flags&flagBlockChecksum != 0
+	r.contentChecksum = flags&flagContentChecksum != 0
+	r.blockSize = blockSize
+	r.contentSize = -1
+	if flags&flagContentSize != 0 {
+		r.contentSize = int64(binary.LittleEndian.Uint64(descriptor[2:]))
+	}
+	r.frameSize = 0
+	r.window = r.window[:0]
+	r.digest.Reset()
+	return nil
+}
+
+func (r *Reader) endFrame() error {
+	r.inFrame = false
+	if r.contentChecksum {
+		var word [4]byte
+		if err := r.readFull(word[:]); err != nil {
+			return err
+		}
+		if r.digest.Sum32() != binary.LittleEndian.Uint32(word[:]) {
+			return ErrChecksum
+		}
+	}
+	if r.contentSize >= 0 && r.contentSize != r.frameSize {
+		return ErrCorrupt
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package lz4
+
+import (
+	"bytes"
+	"github.com/go-compression/raisin/internal/codectest"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+var vectors = []struct {
+	file  string
+	input string
+}{
+	{"empty.lz4", "empty"},
+	{"text.lz4", "text"},
+	{"text-hc-checksums.lz4", "text"},
+	{"repeated-linked-64k.lz4", "repeated"},
+	{"random.lz4", "random"},
+}
+
+func TestDecompressVectors(t *testing.T) {
+	inputs := vectorInputs()
+	for _, vector := range vectors {
+		compressed, err := ioutil.ReadFile(filepath.Join("testdata", vector.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		decompressed, err := Decompress(compressed)
+		if err != nil {
+			t.Errorf("Failed to decompress %s: %v", vector.file, err)
+		} else if !bytes.Equal(decompressed, inputs[vector.input]) {
+			t.Errorf("%s did not decompress to %s", vector.file, vector.input)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	all := []Settings{NewSettings(), {BlockSize: 64 << 10, BlockChecksum: true, ContentChecksum: true, ContentSize: true}, {}}
+	for name, content := range vectorInputs() {
+		for _, settings := range all {
+			decompressed, err := Decompress(Compress(content, settings))
+			if err != nil || !bytes.Equal(decompressed, content) {
+				t.Errorf("%s with %+v was not lossless: %v", name, settings, err)
+			}
+		}
+	}
+}
+
+func TestCompressRatio(t *testing.T) {
+	// The repeated text should compress about as well as the lz4 tool managed, and random data should be stored
+	inputs := vectorInputs()
+	if compressed := Compress(inputs["repeated"], NewSettings()); len(compressed) > 2000 {
+		t.Errorf("Compressed %d bytes of repeated text to %d bytes", len(inputs["repeated"]), len(compressed))
+	}
+	if compressed := Compress(inputs["random"], NewSettings()); len(compressed) > len(inputs["random"])+19 {
+		t.Errorf("Compressed %d random bytes to %d bytes, more than storing them", len(inputs["random"]), len(compressed))
+	}
+}
+
+func TestBlock(t *testing.T) {
+	for name, content := range vectorInputs() {
+		decompressed, err := DecompressBlock(CompressBlock(content), len(content))
+		if err != nil || !bytes.Equal(decompressed, content) {
+			t.Errorf("Block of %s was not lossless: %v", name, err)
+		}
+	}
+	text := []byte(codectest.Text)
+	if _, err := DecompressBlock(CompressBlock(text), len(text)-1); err != ErrTooLarge {
+		t.Errorf("Expected ErrTooLarge one byte under the size of the block, got %v", err)
+	}
+}
+
+func TestConcatenatedFrames(t *testing.T) {
+	// Frames can follow each other, with skippable frames holding other data in between
+	text := []byte(codectest.Text)
+	skippable := []byte{0x5a, 0x2a, 0x4d, 0x18, 3, 0, 0, 0, 'a', 'b', 'c'}
+	stream := append(append(Compress(text, NewSettings()), skippable...), Compress(text, NewSettings())...)
+	decompressed, err := Decompress(stream)
+	if err != nil || !bytes.Equal(decompressed, append(append([]byte{}, text...), text...)) {
+		t.Errorf("Concatenated frames were not decompressed: %v", err)
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	compressed := Compress([]byte(codectest.Text), Settings{BlockSize: 64 << 10, BlockChecksum: true, ContentChecksum: true})
+	flip := func(i int) []byte {
+		corrupt := append([]byte{}, compressed...)
+		corrupt[i] ^= 1
+		return corrupt
+	}
+	codectest.Corrupt(t, map[string][]byte{
+		"empty":            {},
+		"bad magic":        flip(0),
+		"truncated":        compressed[:len(compressed)-6],
+		"trailing garbage": append(append([]byte{}, compressed...), 1, 2),
+	}, ErrCorrupt, Decompress)
+	codectest.Corrupt(t, map[string][]byte{
+		"header checksum":  flip(6),
+		"block checksum":   flip(20),
+		"content checksum": flip(len(compressed) - 1),
+	}, ErrChecksum, Decompress)
+	codectest.Corrupt(t, map[string][]byte{
+		"dictionary": {0x04, 0x22, 0x4d, 0x18, 0x61, 0x40},
+	}, ErrDictionary, Decompress)
+	blocks := map[string][]byte{
+		"offset before start": {0x10, 'a', 2, 0},
+		"zero offset":         {0x10, 'a', 0, 0},
+		"truncated literals":  {0x50, 'a'},
+		"truncated length":    {0xf0},
+	}
+	codectest.Corrupt(t, blocks, ErrCorrupt, func(block []byte) ([]byte, error) {
+		return DecompressBlock(block, 100)
+	})
+}
+
+func TestDecompressLimit(t *testing.T) {
+	content := vectorInputs()["repeated"]
+	compressed := Compress(content, Settings{BlockSize: 64 << 10})
+	codectest.Limit(t, content, compressed, ErrTooLarge, DecompressLimit)
+	codectest.ReaderLimit(t, content, compressed, ErrTooLarge, NewReaderContext)
+}
+
+func TestWriter(t *testing.T) {
+	// Writes that straddle blocks have to come out the same as compressing everything at once
+	content := vectorInputs()["repeated"]
+	settings := Settings{BlockSize: 64 << 10, ContentChecksum: true}
+	newWriter := func(w io.Writer) io.WriteCloser {
+		return NewWriterSettings(w, settings)
+	}
+	if !bytes.Equal(codectest.Writer(t, content, 1000, newWriter, NewReader), Compress(content, settings)) {
+		t.Errorf("Writer split over many writes did not match Compress")
+	}
+}
+
+func TestLZ4Tool(t *testing.T) {
+	lz4, err := exec.LookPath("lz4")
+	if err != nil {
+		t.Skip("lz4 is not installed")
+	}
+	for name, content := range vectorInputs() {
+		for _, settings := range []Settings{NewSettings(), {BlockSize: 64 << 10, BlockChecksum: true, ContentChecksum: true, ContentSize: true}} {
+			cmd := exec.Command(lz4, "-dc")
+			cmd.Stdin = bytes.NewReader(Compress(content, settings))
+			decompressed, err := cmd.Output()
+			if err != nil {
+				t.Errorf("lz4 -d could not read %s with %+v: %v", name, settings, err)
+			} else if !bytes.Equal(decompressed, content) {
+				t.Errorf("lz4 -d did not decode %s with %+v losslessly", name, settings)
+			}
+		}
+	}
+}
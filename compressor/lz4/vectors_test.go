@@ -0,0 +1,19 @@
+package lz4
+
+import (
+	"bytes"
+	"github.com/go-compression/raisin/internal/codectest"
+	"math/rand"
+)
+
+// vectorInputs returns the contents of the frames in testdata, which were written by the lz4 command line tool
+func vectorInputs() map[string][]byte {
+	random := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(random)
+	return map[string][]byte{
+		"empty":    {},
+		"text":     []byte(codectest.Text),
+		"repeated": bytes.Repeat([]byte(codectest.Text), 200),
+		"random":   random,
+	}
+}
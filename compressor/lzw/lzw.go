@@ -0,0 +1,410 @@
+// Package lzw implements LZW with variable width codes and a clear code that resets the dictionary, laid out like the Unix compress utility.
+// With Unix framing its output is a .Z file that uncompress and gzip -d can read.
+package lzw
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	codec "github.com/go-compression/raisin/compressor/codec"
+	"io"
+	"io/ioutil"
+)
+
+// Settings represents an object that can be used to modify how Compress encodes a stream
+// MaxWidth is the widest code in bits (9 to 16), once the dictionary is full Reset lets the encoder clear it
+// when the compression ratio starts to drop, and Unix adds the .Z header.
+type Settings struct {
+	MaxWidth int
+	Reset    bool
+	Unix     bool
+}
+
+// NewSettings returns the default settings for Compress as a Settings object, the same as compress -b16
+func NewSettings() Settings {
+	s := Settings{}
+	s.MaxWidth = 16
+	s.Reset = true
+	s.Unix = true
+	return s
+}
+
+const (
+	minWidth = 9
+	maxWidth = 16
+	// clearCode resets the dictionary, it is only reserved when Reset is on (compress's block mode)
+	clearCode = 256
+	// checkGap is how many input bytes pass between checks of the compression ratio once the dictionary is full
+	checkGap = 10000
+	// blockModeFlag is set in the third byte of the .Z header when the stream may contain clear codes
+	blockModeFlag = 0x80
+	widthMask     = 0x1f
+	// checkInterval is how many bytes or codes are coded between checks of whether the context is done
+	checkInterval = 4096
+)
+
+// Magic is the two byte sequence every .Z file starts with.
+var Magic = []byte{0x1f, 0x9d}
+
+var (
+	// ErrCorrupt is returned when decompressing a stream with a bad header or a code that isn't in the dictionary
+	ErrCorrupt = errors.New("lzw: corrupt input")
+	// ErrTooLarge is returned when a stream decompresses to more than the limit
+	ErrTooLarge = errors.New("lzw: decompressed size exceeds limit")
+)
+
+func (s Settings) width() int {
+	if s.MaxWidth < minWidth {
+		return minWidth
+	} else if s.MaxWidth > maxWidth {
+		return maxWidth
+	}
+	return s.MaxWidth
+}
+
+// codeWriter packs codes least significant bit first in groups of 8 codes, as compress does.
+// A group is padded out to its full size whenever the code width changes, because the decoder reads a whole group at a time.
+type codeWriter struct {
+	out    []byte
+	group  [maxWidth]byte
+	offset int
+	width  int
+}
+
+func (w *codeWriter) write(code int) {
+	for i := 0; i < w.width; i++ {
+		if code>>uint(i)&1 != 0 {
+			bit := w.offset + i
+			w.group[bit/8] |= 1 << uint(bit%8)
+		}
+	}
+	w.offset += w.width
+	if w.offset == w.width*8 {
+		w.out = append(w.out, w.group[:w.width]...)
+		w.group = [maxWidth]byte{}
+		w.offset = 0
+	}
+}
+
+// setWidth pads out the current group and switches to codes of the given width.
+func (w *codeWriter) setWidth(width int) {
+	if w.offset > 0 {
+		w.out = append(w.out, w.group[:w.width]...)
+		w.group = [maxWidth]byte{}
+		w.offset = 0
+	}
+	w.width = width
+}
+
+func (w *codeWriter) flush() []byte {
+	w.out = append(w.out, w.group[:(w.offset+7)/8]...)
+	return w.out
+}
+
+// maxCode returns the largest code that fits in width bits, at the widest width every code up to the size of the dictionary fits.
+// compress only checks for the widest width after growing, so with a maximum of 9 bits a full dictionary still moves on to 10 bit codes.
+func maxCode(width int, widest int) int {
+	if width == widest && width > minWidth {
+		return 1 << uint(widest)
+	}
+	return 1<<uint(width) - 1
+}
+
+// Compress takes a slice of bytes and returns its LZW encoding.
+// Codes start 9 bits wide and grow by a bit every time the dictionary outgrows them, up to settings.MaxWidth.
+func Compress(content []byte, settings Settings) []byte {
+	output, _ := compress(context.Background(), content, settings)
+	return output
+}
+
+func compress(ctx context.Context, content []byte, settings Settings) ([]byte, error) {
+	widest := settings.width()
+	w := &codeWriter{width: minWidth}
+	if settings.Unix {
+		flags := byte(widest)
+		if settings.Reset {
+			flags |= blockModeFlag
+		}
+		w.out = append(w.out, Magic[0], Magic[1], flags)
+	}
+	if len(content) == 0 {
+		return w.out, nil
+	}
+
+	first := 256
+	if settings.Reset {
+		first = clearCode + 1
+	}
+	dictionarySize := 1 << uint(widest)
+	nextCode := first
+	dictionary := make(map[int]int)
+	checkpoint := checkGap
+	ratio := 0.0
+
+	output := func(code int) {
+		w.write(code)
+		// The decoder adds every entry a code later than the encoder, so the width only grows after the next code is written
+		if nextCode > maxCode(w.width, widest) {
+			w.setWidth(w.width + 1)
+		}
+	}
+
+	prefix := int(content[0])
+	for i := 1; i < len(content); i++ {
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		c := content[i]
+		key := prefix<<8 | int(c)
+		if code, ok := dictionary[key]; ok {
+			prefix = code
+			continue
+		}
+		output(prefix)
+		prefix = int(c)
+		if nextCode < dictionarySize {
+			dictionary[key] = nextCode
+			nextCode++
+		} else if settings.Reset && i+1 >= checkpoint {
+			// The dictionary is full, keep it while it's still improving the ratio and start over once it isn't
+			checkpoint = i + 1 + checkGap
+			current := float64(i+1) / float64(len(w.out)+1)
+			if current > ratio {
+				ratio = current
+			} else {
+				ratio = 0
+				dictionary = make(map[int]int)
+				nextCode = first
+				w.write(clearCode)
+				w.setWidth(minWidth)
+			}
+		}
+	}
+	output(prefix)
+	return w.flush(), nil
+}
+
+// Decompress takes an LZW stream and returns the decoded contents, reading the width and mode from the header if settings.Unix is set.
+func Decompress(content []byte, settings Settings) ([]byte, error) {
+	return DecompressLimit(content, settings, 0)
+}
+
+// DecompressLimit is like Decompress but returns ErrTooLarge once the output would exceed limit bytes, a limit of 0 means no limit.
+func DecompressLimit(content []byte, settings Settings, limit int) ([]byte, error) {
+	return decompress(context.Background(), content, settings, limit)
+}
+
+func decompress(ctx context.Context, content []byte, settings Settings, limit int) ([]byte, error) {
+	widest := settings.width()
+	blockMode := settings.Reset
+	if settings.Unix {
+		if len(content) < 3 || !bytes.HasPrefix(content, Magic) {
+			return nil, ErrCorrupt
+		}
+		widest = int(content[2] & widthMask)
+		blockMode = content[2]&blockModeFlag != 0
+		if widest < minWidth || widest > maxWidth {
+			return nil, ErrCorrupt
+		}
+		content = content[3:]
+	}
+
+	first := 256
+	if blockMode {
+		first = clearCode + 1
+	}
+	dictionarySize := 1 << uint(widest)
+	prefixes := make([]int, dictionarySize)
+	suffixes := make([]byte, dictionarySize)
+	nextCode := first
+
+	// The reader mirrors codeWriter, reading a whole group of codes whenever the width changes
+	width := minWidth
+	pos := 0
+	var group []byte
+	offset, size := 0, 0
+	cleared := false
+	read := func() int {
+		if cleared || offset >= size || nextCode > maxCode(width, widest) {
+			if nextCode > maxCode(width, widest) {
+				width++
+			}
+			if cleared {
+				width = minWidth
+				cleared = false
+			}
+			n := width
+			if n > len(content)-pos {
+				n = len(content) - pos
+			}
+			group = content[pos : pos+n]
+			pos += n
+			offset = 0
+			// A code can only start this many bits into the group
+			size = n*8 - (width - 1)
+			if size <= 0 {
+				return -1
+			}
+		}
+		code := 0
+		for i := 0; i < width; i++ {
+			bit := offset + i
+			code |= int(group[bit/8]>>uint(bit%8)&1) << uint(i)
+		}
+		offset += width
+		return code
+	}
+
+	code := read()
+	if code < 0 {
+		return []byte{}, nil
+	}
+	if code > 255 {
+		return nil, ErrCorrupt
+	}
+	output := []byte{byte(code)}
+	previous := code
+	lastByte := byte(code)
+	stack := make([]byte, 0, dictionarySize)
+	for codes := 1; ; codes++ {
+		if codes%checkInterval == 0 && ctx.Err() != nil {
+			return output, ctx.Err()
+		}
+		code = read()
+		if code < 0 {
+			break
+		}
+		if blockMode && code == clearCode {
+			// Like compress, the first code after a clear adds an entry in the clear code's place that is never used
+			nextCode = first - 1
+			cleared = true
+			if code = read(); code < 0 {
+				break
+			}
+		}
+
+		current := code
+		stack = stack[:0]
+		if code > nextCode || (code == nextCode && nextCode >= dictionarySize) {
+			return output, ErrCorrupt
+		} else if code == nextCode {
+			// The code being defined, which is the previous string followed by its own first byte
+			stack = append(stack, lastByte)
+			code = previous
+		}
+		for code >= 256 {
+			if len(stack) >= dictionarySize {
+				return output, ErrCorrupt
+			}
+			stack = append(stack, suffixes[code])
+			code = prefixes[code]
+		}
+		lastByte = byte(code)
+		stack = append(stack, lastByte)
+
+		if limit > 0 && len(output)+len(stack) > limit {
+			return output, ErrTooLarge
+		}
+		for i := len(stack) - 1; i >= 0; i-- {
+			output = append(output, stack[i])
+		}
+
+		if nextCode < dictionarySize {
+			prefixes[nextCode] = previous
+			suffixes[nextCode] = lastByte
+			nextCode++
+		}
+		previous = current
+	}
+	return output, nil
+}
+
+// Writer compresses everything written to it as one LZW stream on Close
+type Writer struct {
+	w        io.Writer
+	ctx      context.Context
+	settings Settings
+	buffer   bytes.Buffer
+}
+
+// NewWriter creates an io.WriteCloser object with an io.Writer that writes .Z files
+func NewWriter(w io.Writer) io.WriteCloser {
+	return NewWriterSettings(w, NewSettings())
+}
+
+// NewWriterSettings creates an io.WriteCloser object with an io.Writer that compresses with the given settings
+func NewWriterSettings(w io.Writer, settings Settings) io.WriteCloser {
+	z := new(Writer)
+	z.w = w
+	z.ctx = context.Background()
+	z.settings = settings
+	return z
+}
+
+// NewWriterContext creates an io.WriteCloser like NewWriter that gives up once ctx is done
+func NewWriterContext(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
+	z := NewWriterSettings(w, NewSettings()).(*Writer)
+	z.ctx = ctx
+	return z
+}
+
+func (writer *Writer) Write(data []byte) (n int, err error) {
+	return writer.buffer.Write(data)
+}
+
+// Close compresses everything written so far and writes out the stream
+func (writer *Writer) Close() error {
+	compressed, err := compress(writer.ctx, writer.buffer.Bytes(), writer.settings)
+	if err != nil {
+		return err
+	}
+	_, err = writer.w.Write(compressed)
+	return err
+}
+
+// Reader decompresses everything from an io.Reader on the first call to Read
+type Reader struct {
+	r            io.Reader
+	ctx          context.Context
+	settings     Settings
+	limit        int
+	decompressed *bytes.Reader
+}
+
+// NewReader creates an io.Reader object that reads .Z files from an io.Reader
+func NewReader(r io.Reader) io.Reader {
+	return NewReaderSettings(r, NewSettings(), 0)
+}
+
+// NewReaderSettings creates an io.Reader object that decompresses streams written with the given settings from an io.Reader
+func NewReaderSettings(r io.Reader, settings Settings, limit int) io.Reader {
+	z := new(Reader)
+	z.r = r
+	z.ctx = context.Background()
+	z.settings = settings
+	z.limit = limit
+	return z
+}
+
+// NewReaderContext creates an io.Reader like NewReader that gives up once ctx is done and fails with ErrTooLarge
+// instead of decompressing more than opts.Limit bytes, a limit of 0 means no limit
+func NewReaderContext(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+	z := NewReaderSettings(r, NewSettings(), opts.Limit).(*Reader)
+	z.ctx = ctx
+	return z
+}
+
+func (r *Reader) Read(content []byte) (n int, err error) {
+	if r.decompressed == nil {
+		compressed, err := ioutil.ReadAll(r.r)
+		if err != nil {
+			return 0, err
+		}
+		decompressed, err := decompress(r.ctx, compressed, r.settings, r.limit)
+		if err != nil {
+			return 0, err
+		}
+		r.decompressed = bytes.NewReader(decompressed)
+	}
+	return r.decompressed.Read(content)
+}
@@ -0,0 +1,114 @@
+package lzw
+
+import (
+	"bytes"
+	"compress/lzw"
+	"github.com/go-compression/raisin/internal/codectest"
+	"os/exec"
+	"testing"
+)
+
+func testInputs() map[string][]byte {
+	inputs := codectest.Inputs(100000)
+	// Text followed by random bytes fills the dictionary and then makes it useless, which should trigger a clear code
+	inputs["mixed"] = append(append([]byte{}, inputs["text"]...), inputs["random"]...)
+	return inputs
+}
+
+func testSettings() []Settings {
+	var all []Settings
+	for _, width := range []int{9, 12, 16} {
+		for _, reset := range []bool{true, false} {
+			for _, unix := range []bool{true, false} {
+				all = append(all, Settings{MaxWidth: width, Reset: reset, Unix: unix})
+			}
+		}
+	}
+	return all
+}
+
+func TestRoundTrip(t *testing.T) {
+	for name, content := range testInputs() {
+		for _, settings := range testSettings() {
+			decompressed, err := Decompress(Compress(content, settings), settings)
+			if err != nil {
+				t.Errorf("%s with %+v failed to decompress: %v", name, settings, err)
+			} else if !bytes.Equal(decompressed, content) {
+				t.Errorf("%s with %+v was not lossless", name, settings)
+			}
+		}
+	}
+}
+
+func TestClearCode(t *testing.T) {
+	content := testInputs()["mixed"]
+	settings := NewSettings()
+	settings.MaxWidth = 12
+	withReset := Compress(content, settings)
+	settings.Reset = false
+	withoutReset := Compress(content, settings)
+	if len(withReset) >= len(withoutReset) {
+		t.Errorf("Clearing the dictionary compressed to %d bytes, not clearing it to %d", len(withReset), len(withoutReset))
+	}
+}
+
+func TestCompressRatio(t *testing.T) {
+	// The stream is a little larger than the standard library's since codes are padded whenever the width changes
+	text := testInputs()["text"]
+	var standard bytes.Buffer
+	w := lzw.NewWriter(&standard, lzw.LSB, 8)
+	w.Write(text)
+	w.Close()
+	if compressed := Compress(text, NewSettings()); len(compressed) > standard.Len()+standard.Len()/10 {
+		t.Errorf("Compressed text to %d bytes, compress/lzw managed %d", len(compressed), standard.Len())
+	}
+}
+
+func TestUncompress(t *testing.T) {
+	gzip, err := exec.LookPath("gzip")
+	if err != nil {
+		t.Skip("gzip is not installed")
+	}
+	for name, content := range testInputs() {
+		for _, settings := range testSettings() {
+			if !settings.Unix {
+				continue
+			}
+			cmd := exec.Command(gzip, "-dc")
+			cmd.Stdin = bytes.NewReader(Compress(content, settings))
+			decompressed, err := cmd.Output()
+			if err != nil {
+				t.Errorf("gzip -d could not read %s with %+v: %v", name, settings, err)
+			} else if !bytes.Equal(decompressed, content) {
+				t.Errorf("gzip -d did not decode %s with %+v losslessly", name, settings)
+			}
+		}
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	compressed := Compress(testInputs()["text"], NewSettings())
+	cases := map[string][]byte{
+		"no header":   compressed[3:],
+		"bad width":   append([]byte{0x1f, 0x9d, 0x80 | 17}, compressed[3:]...),
+		"truncated":   compressed[:2],
+		"first code":  {0x1f, 0x9d, 0x90, 0xff, 0xff},
+		"future code": {0x1f, 0x9d, 0x90, 'a', 0xfe, 0x03},
+	}
+	codectest.Corrupt(t, cases, ErrCorrupt, func(content []byte) ([]byte, error) {
+		return Decompress(content, NewSettings())
+	})
+}
+
+func TestDecompressLimit(t *testing.T) {
+	content := testInputs()["run"]
+	compressed := Compress(content, NewSettings())
+	codectest.Limit(t, content, compressed, ErrTooLarge, func(compressed []byte, limit int) ([]byte, error) {
+		return DecompressLimit(compressed, NewSettings(), limit)
+	})
+	codectest.ReaderLimit(t, content, compressed, ErrTooLarge, NewReaderContext)
+}
+
+func TestWriter(t *testing.T) {
+	codectest.Writer(t, testInputs()["text"], 30000, NewWriter, NewReader)
+}
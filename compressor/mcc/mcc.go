@@ -1,7 +1,10 @@
 package mcc
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	codec "github.com/go-compression/raisin/compressor/codec"
 	huff "github.com/icza/huffman"
 	// huffman "github.com/go-compression/raisin/compressor/huffman"
 	"io"
@@ -209,7 +212,10 @@ func createRoot() *State {
 	return &state
 }
 
-func encodeBytes(fileContents []byte) ([]int, []byte, int) {
+// checkInterval is how many bytes are coded between checks of whether the context is done
+const checkInterval = 4096
+
+func encodeBytes(ctx context.Context, fileContents []byte) ([]int, []byte, int, error) {
 	bitsize := 0
 	allbits := make([]string, 0)
 	bitstream := make([]int, 0)
@@ -217,7 +223,10 @@ func encodeBytes(fileContents []byte) ([]int, []byte, int) {
 
 	state := createRoot()
 	// root := state
-	for _, fileByte := range fileContents {
+	for i, fileByte := range fileContents {
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return nil, nil, 0, ctx.Err()
+		}
 		containsSymbol := false
 		var stateWithSymbol *State
 		for _, transitionState := range *state.transitions {
@@ -315,10 +324,17 @@ func encodeBytes(fileContents []byte) ([]int, []byte, int) {
 	// fmt.Println("Bits: " + strings.Join(allbits, ""))
 
 	// printTransitions(*root, 0)
-	return bitstream, literals, bitsize
+	return bitstream, literals, bitsize, nil
 }
 
-func decodeBytes(bitstream []int, literals []byte) []byte {
+var (
+	// ErrCorrupt is returned when decompressing a stream that is missing its separator or moves to states that don't exist
+	ErrCorrupt = errors.New("mcc: corrupt input")
+	// ErrTooLarge is returned when a stream decompresses to more than the limit
+	ErrTooLarge = errors.New("mcc: decompressed size exceeds limit")
+)
+
+func decodeBytes(ctx context.Context, bitstream []int, literals []byte, limit int) ([]byte, error) {
 	state := createRoot()
 	// root := state
 
@@ -326,15 +342,25 @@ func decodeBytes(bitstream []int, literals []byte) []byte {
 
 	movingUp := false
 
-	for _, bit := range bitstream {
+	for i, bit := range bitstream {
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return output, ctx.Err()
+		}
+		// Every transition outputs at most one byte
+		if limit > 0 && len(output) >= limit {
+			return output, ErrTooLarge
+		}
 		childState := state.getStateFromRepresentation(bit)
 		if childState == nil {
-			panic("Couldn't find child state with bit representation")
+			return output, ErrCorrupt
 		}
 
 		if childState.isTok {
 			if childState.token == Read {
 				if movingUp {
+					if state.isRoot {
+						return output, ErrCorrupt
+					}
 					// Output token to outstream
 					output = append(output, state.symbol)
 					// Reset moving up status
@@ -346,6 +372,9 @@ func decodeBytes(bitstream []int, literals []byte) []byte {
 				} else {
 					// Read token
 					// Pop char from beginning of literal stream
+					if len(literals) == 0 {
+						return output, ErrCorrupt
+					}
 					symbol := literals[0]
 					literals = literals[1:]
 					// Output symbol too outstream
@@ -363,7 +392,8 @@ func decodeBytes(bitstream []int, literals []byte) []byte {
 				}
 				for i := 0; i < moveUpTimes; i++ {
 					if state.parent == nil {
-						panic("Trying to go up past root node")
+						// Trying to go up past root node
+						return output, ErrCorrupt
 					}
 					// Enter the parent state
 					state = state.parent
@@ -381,7 +411,7 @@ func decodeBytes(bitstream []int, literals []byte) []byte {
 			state.parent.sortByFrequency()
 		}
 	}
-	return output
+	return output, nil
 }
 
 const separator = byte('\\')
@@ -391,24 +421,39 @@ func encodeStreamAndLiterals(bitstream []int, literals []byte) []byte {
 	return append(append([]byte(bits), separator), literals...)
 }
 
-func decodeStreamAndLiterals(bytes []byte) ([]int, []byte) {
+func decodeStreamAndLiterals(bytes []byte) ([]int, []byte, error) {
 	stringInput := string(bytes)
 	separatorIndex := strings.IndexByte(stringInput, separator)
-	bitstrings := strings.Split(stringInput[:separatorIndex], ",")
+	if separatorIndex == -1 {
+		return nil, nil, ErrCorrupt
+	}
 	literals := bytes[separatorIndex+1:]
+	if separatorIndex == 0 {
+		// An empty input has no stream at all
+		return []int{}, literals, nil
+	}
+	bitstrings := strings.Split(stringInput[:separatorIndex], ",")
 	bits := make([]int, len(bitstrings))
 	for i, bitstring := range bitstrings {
 		num, err := strconv.Atoi(bitstring)
 		if err != nil {
-			panic(err)
+			return nil, nil, ErrCorrupt
 		}
 		bits[i] = num
 	}
-	return bits, literals
+	return bits, literals, nil
 }
 
 func Compress(fileContents []byte) []byte {
-	bitstream, literals, bitsize := encodeBytes(fileContents)
+	compressed, _ := compress(context.Background(), fileContents)
+	return compressed
+}
+
+func compress(ctx context.Context, fileContents []byte) ([]byte, error) {
+	bitstream, literals, bitsize, err := encodeBytes(ctx, fileContents)
+	if err != nil {
+		return nil, err
+	}
 	fmt.Println("Character bytes:", len(literals))
 	fmt.Println("State bits:", bitsize, "bytes:", bitsize/8)
 	fmt.Println("True estimate of bytes:", (bitsize/8)+len(literals))
@@ -422,15 +467,31 @@ func Compress(fileContents []byte) []byte {
 	}
 
 	fmt.Println("Sum:", result)
-	return encodeStreamAndLiterals(bitstream, literals)
+	return encodeStreamAndLiterals(bitstream, literals), nil
 }
 
+// Decompress takes a slice of bytes and returns the decompressed contents
+//
+// Deprecated: a corrupt stream is decompressed as far as possible and its error dropped, use DecompressLimit instead.
 func Decompress(fileContents []byte) []byte {
-	bitstream, literals := decodeStreamAndLiterals(fileContents)
-	output := decodeBytes(bitstream, literals)
+	output, _ := DecompressLimit(fileContents, 0)
 	return output
 }
 
+// DecompressLimit is like Decompress but returns ErrCorrupt for malformed streams and ErrTooLarge once the output would exceed limit bytes.
+// A limit of 0 means no limit.
+func DecompressLimit(fileContents []byte, limit int) ([]byte, error) {
+	return decompress(context.Background(), fileContents, limit)
+}
+
+func decompress(ctx context.Context, fileContents []byte, limit int) ([]byte, error) {
+	bitstream, literals, err := decodeStreamAndLiterals(fileContents)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBytes(ctx, bitstream, literals, limit)
+}
+
 func printTransitions(parent State, indentation int) {
 	for _, state := range *parent.transitions {
 		fmt.Print(strings.Repeat("-", indentation), state.displayValue(), "-", state.freq, "\n")
@@ -442,17 +503,27 @@ func printTransitions(parent State, indentation int) {
 }
 
 type Writer struct {
-	w io.Writer
+	w   io.Writer
+	ctx context.Context
 }
 
 func NewWriter(w io.Writer) io.WriteCloser {
+	return NewWriterContext(context.Background(), w, codec.Options{})
+}
+
+// NewWriterContext creates an io.WriteCloser like NewWriter that gives up once ctx is done
+func NewWriterContext(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
 	z := new(Writer)
 	z.w = w
+	z.ctx = ctx
 	return z
 }
 
 func (writer *Writer) Write(data []byte) (n int, err error) {
-	compressed := Compress(data)
+	compressed, err := compress(writer.ctx, data)
+	if err != nil {
+		return 0, err
+	}
 	writer.w.Write(compressed)
 	return len(compressed), nil
 }
@@ -466,11 +537,21 @@ type Reader struct {
 	compressed   []byte
 	decompressed []byte
 	pos          int
+	limit        int
+	ctx          context.Context
 }
 
 func NewReader(r io.Reader) io.Reader {
+	return NewReaderContext(context.Background(), r, codec.Options{})
+}
+
+// NewReaderContext creates an io.Reader like NewReader that gives up once ctx is done and fails with ErrTooLarge
+// instead of decompressing more than opts.Limit bytes, a limit of 0 means no limit
+func NewReaderContext(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
 	z := new(Reader)
 	z.r = r
+	z.limit = opts.Limit
+	z.ctx = ctx
 	return z
 }
 
@@ -480,7 +561,10 @@ func (r *Reader) Read(content []byte) (n int, err error) {
 		if err != nil {
 			return 0, err
 		}
-		r.decompressed = Decompress(r.compressed)
+		r.decompressed, err = decompress(r.ctx, r.compressed, r.limit)
+		if err != nil {
+			return 0, err
+		}
 	}
 	bytesToWriteOut := len(r.decompressed[r.pos:])
 	if len(content) < bytesToWriteOut {
@@ -0,0 +1,115 @@
+package xxhash
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	prime32_1 uint32 = 2654435761
+	prime32_2 uint32 = 2246822519
+	prime32_3 uint32 = 3266489917
+	prime32_4 uint32 = 668265263
+	prime32_5 uint32 = 374761393
+)
+
+// Digest32 computes the 32-bit xxHash digest of a stream written to it in pieces, as used by the LZ4 frame format.
+type Digest32 struct {
+	seed           uint32
+	v1, v2, v3, v4 uint32
+	total          uint64
+	mem            [16]byte
+	n              int
+}
+
+// Sum32 returns the xxHash32 digest of data using a seed of zero.
+func Sum32(data []byte) uint32 {
+	d := New32()
+	d.Write(data)
+	return d.Sum32()
+}
+
+// New32 returns a Digest32 using a seed of zero.
+func New32() *Digest32 {
+	return New32Seed(0)
+}
+
+// New32Seed returns a Digest32 using the given seed.
+func New32Seed(seed uint32) *Digest32 {
+	d := &Digest32{seed: seed}
+	d.Reset()
+	return d
+}
+
+// Reset discards everything written so far.
+func (d *Digest32) Reset() {
+	d.v1 = d.seed + prime32_1 + prime32_2
+	d.v2 = d.seed + prime32_2
+	d.v3 = d.seed
+	d.v4 = d.seed - prime32_1
+	d.total = 0
+	d.n = 0
+}
+
+// Write adds data to the digest, it never returns an error.
+func (d *Digest32) Write(data []byte) (int, error) {
+	length := len(data)
+	d.total += uint64(length)
+	if d.n+len(data) < 16 {
+		d.n += copy(d.mem[d.n:], data)
+		return length, nil
+	}
+	if d.n > 0 {
+		data = data[copy(d.mem[d.n:], data):]
+		d.stripe(d.mem[:])
+		d.n = 0
+	}
+	for len(data) >= 16 {
+		d.stripe(data)
+		data = data[16:]
+	}
+	d.n = copy(d.mem[:], data)
+	return length, nil
+}
+
+func (d *Digest32) stripe(data []byte) {
+	d.v1 = round32(d.v1, binary.LittleEndian.Uint32(data[0:4]))
+	d.v2 = round32(d.v2, binary.LittleEndian.Uint32(data[4:8]))
+	d.v3 = round32(d.v3, binary.LittleEndian.Uint32(data[8:12]))
+	d.v4 = round32(d.v4, binary.LittleEndian.Uint32(data[12:16]))
+}
+
+// Sum32 returns the digest of everything written so far without changing it.
+func (d *Digest32) Sum32() uint32 {
+	var h uint32
+	if d.total >= 16 {
+		h = bits.RotateLeft32(d.v1, 1) + bits.RotateLeft32(d.v2, 7) + bits.RotateLeft32(d.v3, 12) + bits.RotateLeft32(d.v4, 18)
+	} else {
+		h = d.seed + prime32_5
+	}
+	h += uint32(d.total)
+
+	data := d.mem[:d.n]
+	for len(data) >= 4 {
+		h += binary.LittleEndian.Uint32(data[:4]) * prime32_3
+		h = bits.RotateLeft32(h, 17) * prime32_4
+		data = data[4:]
+	}
+	for _, b := range data {
+		h += uint32(b) * prime32_5
+		h = bits.RotateLeft32(h, 11) * prime32_1
+	}
+
+	h ^= h >> 15
+	h *= prime32_2
+	h ^= h >> 13
+	h *= prime32_3
+	h ^= h >> 16
+	return h
+}
+
+func round32(acc, input uint32) uint32 {
+	acc += input * prime32_2
+	acc = bits.RotateLeft32(acc, 13)
+	return acc * prime32_1
+}
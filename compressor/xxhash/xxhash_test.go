@@ -0,0 +1,61 @@
+package xxhash
+
+import (
+	"testing"
+)
+
+func TestSum64(t *testing.T) {
+	tests := []struct {
+		input string
+		want  uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+	}
+	for _, test := range tests {
+		got := Sum64([]byte(test.input))
+		if got != test.want {
+			t.Errorf("Sum64(%q) = %#x; want %#x", test.input, got, test.want)
+		}
+	}
+}
+
+func TestSum32(t *testing.T) {
+	tests := []struct {
+		input string
+		want  uint32
+	}{
+		{"", 0x02cc5d05},
+		{"a", 0x550d7456},
+		{"abc", 0x32d153ff},
+		{"Nobody inspects the spammish repetition", 0xe2293b2f},
+		{"Nobody inspects the spammish repetition, nobody inspects the spammish repetition", 0x7607eb32},
+	}
+	for _, test := range tests {
+		got := Sum32([]byte(test.input))
+		if got != test.want {
+			t.Errorf("Sum32(%q) = %#x; want %#x", test.input, got, test.want)
+		}
+	}
+}
+
+func TestDigest32(t *testing.T) {
+	// Writing in pieces of every size has to give the same digest as writing everything at once
+	data := []byte("Nobody inspects the spammish repetition, nobody inspects the spammish repetition")
+	want := Sum32(data)
+	for size := 1; size <= len(data); size++ {
+		d := New32()
+		for i := 0; i < len(data); i += size {
+			end := i + size
+			if end > len(data) {
+				end = len(data)
+			}
+			d.Write(data[i:end])
+		}
+		if got := d.Sum32(); got != want {
+			t.Errorf("Digest32 written %d bytes at a time = %#x; want %#x", size, got, want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// loadBits returns n bits of data starting at bit start, counting from the least significant bit of the first byte.
+// Bits past the end of data are zero, and n can be at most 56.
+func loadBits(data []byte, start int, n uint) uint64 {
+	if n == 0 {
+		return 0
+	}
+	i := start >> 3
+	var word uint64
+	if i+8 <= len(data) {
+		word = binary.LittleEndian.Uint64(data[i:])
+	} else {
+		for k := len(data) - 1; k >= i; k-- {
+			word = word<<8 | uint64(data[k])
+		}
+	}
+	return (word >> uint(start&7)) & (1<<n - 1)
+}
+
+// forwardReader reads bits from the least significant bit of each byte onwards, which is how FSE table descriptions are stored
+type forwardReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *forwardReader) peekBits(n uint) uint64 {
+	return loadBits(r.data, r.pos, n)
+}
+
+func (r *forwardReader) readBits(n uint) uint64 {
+	value := r.peekBits(n)
+	r.pos += int(n)
+	return value
+}
+
+// backwardReader reads a bitstream from its end towards its start, which is how FSE and Huffman coded streams are written.
+// The highest set bit of the last byte marks where the stream begins and bits are read from the most significant down.
+// pos is the number of bits left to read, reading past the start of the stream returns zeros and leaves pos negative.
+type backwardReader struct {
+	data []byte
+	pos  int
+}
+
+func newBackwardReader(data []byte) (*backwardReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, ErrCorrupt
+	}
+	return &backwardReader{data: data, pos: 8*(len(data)-1) + bits.Len8(data[len(data)-1]) - 1}, nil
+}
+
+// peekBits returns the next n bits without consuming them, with zeros filling in for bits before the start of the stream
+func (r *backwardReader) peekBits(n uint) uint64 {
+	start := r.pos - int(n)
+	if start >= 0 {
+		return loadBits(r.data, start, n)
+	} else if -start >= int(n) {
+		return 0
+	}
+	return loadBits(r.data, 0, n-uint(-start)) << uint(-start)
+}
+
+func (r *backwardReader) readBits(n uint) uint64 {
+	value := r.peekBits(n)
+	r.pos -= int(n)
+	return value
+}
@@ -0,0 +1,146 @@
+package zstd
+
+import (
+	"math/bits"
+)
+
+// fseEntry is one state of an FSE decoding table: the symbol it decodes to, and the base that the next bits
+// read from the stream are added to for the next state
+type fseEntry struct {
+	symbol uint8
+	bits   uint8
+	base   uint16
+}
+
+// fseTable is a finite state entropy decoding table with 1<<log states
+type fseTable struct {
+	log     uint
+	entries []fseEntry
+}
+
+// readDistribution reads the FSE table description at the start of data, returning the normalized count of each symbol,
+// the accuracy log and how many bytes the description took up. A count of -1 is a symbol less likely than 1 in 1<<log.
+func readDistribution(data []byte, maxSymbol int, maxLog uint) ([]int16, uint, int, error) {
+	if len(data) == 0 {
+		return nil, 0, 0, ErrCorrupt
+	}
+	r := &forwardReader{data: data}
+	log := uint(r.readBits(4)) + 5
+	if log > maxLog {
+		return nil, 0, 0, ErrCorrupt
+	}
+
+	// Counts take as many bits as it takes to store the probability that's left, with the smaller values taking one bit less
+	remaining := 1<<log + 1
+	threshold := 1 << log
+	width := log + 1
+	counts := make([]int16, 0, maxSymbol+1)
+	for remaining > 1 {
+		if len(counts) > maxSymbol {
+			return nil, 0, 0, ErrCorrupt
+		}
+		max := 2*threshold - 1 - remaining
+		value := int(r.peekBits(width - 1))
+		if value < max {
+			r.pos += int(width - 1)
+		} else {
+			value = int(r.readBits(width))
+			if value >= threshold {
+				value -= max
+			}
+		}
+		count := value - 1
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		counts = append(counts, int16(count))
+
+		// A count of zero is followed by 2 bit repeat flags for more zeros, with 3 meaning another flag follows
+		if count == 0 {
+			for {
+				repeat := int(r.readBits(2))
+				for k := 0; k < repeat; k++ {
+					counts = append(counts, 0)
+				}
+				if repeat != 3 || len(counts) > maxSymbol+1 {
+					break
+				}
+			}
+		}
+		if remaining < 1 {
+			return nil, 0, 0, ErrCorrupt
+		}
+		for remaining < threshold {
+			width--
+			threshold >>= 1
+		}
+	}
+	if remaining != 1 || len(counts) > maxSymbol+1 || r.pos > 8*len(data) {
+		return nil, 0, 0, ErrCorrupt
+	}
+	return counts, log, (r.pos + 7) / 8, nil
+}
+
+// newFSETable builds the decoding table for a normalized distribution.
+// Symbols less likely than 1 in 1<<log take the last states, and the rest are spread over the table with a fixed step
+// so that each symbol's states are far apart. A symbol's states then read enough bits to reach any of the states after it.
+func newFSETable(counts []int16, log uint) (*fseTable, error) {
+	size := 1 << log
+	t := &fseTable{log: log, entries: make([]fseEntry, size)}
+	next := make([]int, len(counts))
+	high := size - 1
+	for symbol, count := range counts {
+		if count == -1 {
+			if high < 0 {
+				return nil, ErrCorrupt
+			}
+			t.entries[high].symbol = uint8(symbol)
+			high--
+			next[symbol] = 1
+		} else {
+			next[symbol] = int(count)
+		}
+	}
+
+	step := size>>1 + size>>3 + 3
+	mask := size - 1
+	pos := 0
+	for symbol, count := range counts {
+		for i := 0; i < int(count); i++ {
+			t.entries[pos].symbol = uint8(symbol)
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+	if pos != 0 {
+		return nil, ErrCorrupt
+	}
+
+	for i := range t.entries {
+		entry := &t.entries[i]
+		state := next[entry.symbol]
+		next[entry.symbol]++
+		width := log + 1 - uint(bits.Len(uint(state)))
+		entry.bits = uint8(width)
+		entry.base = uint16(state<<width - size)
+	}
+	return t, nil
+}
+
+// newRLETable returns a table whose only state decodes to symbol without reading any bits
+func newRLETable(symbol byte) *fseTable {
+	return &fseTable{log: 0, entries: []fseEntry{{symbol: symbol}}}
+}
+
+// mustFSETable builds one of the predefined tables
+func mustFSETable(counts []int16, log uint) *fseTable {
+	t, err := newFSETable(counts, log)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
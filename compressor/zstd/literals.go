@@ -0,0 +1,278 @@
+package zstd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	rawLiterals        = 0
+	rleLiterals        = 1
+	compressedLiterals = 2
+	treelessLiterals   = 3
+
+	maxHuffmanBits = 11
+	// maxWeightsLog is the largest accuracy log of the FSE table that Huffman weights are compressed with
+	maxWeightsLog = 6
+)
+
+type huffmanEntry struct {
+	symbol byte
+	bits   uint8
+}
+
+// huffmanTable decodes a symbol by looking up the next maxBits bits, every code owning all of the entries it is a prefix of
+type huffmanTable struct {
+	maxBits uint
+	entries []huffmanEntry
+}
+
+// readHuffmanTable reads the Huffman tree description at the start of data, returning the table and the bytes it took up.
+// The weights are stored as 4 bit values or compressed with FSE, and the weight of the last symbol is left out because it's
+// whatever brings the total up to a power of 2.
+func readHuffmanTable(data []byte) (*huffmanTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, ErrCorrupt
+	}
+	header := int(data[0])
+	var weights []byte
+	size := 0
+	if header < 128 {
+		size = header
+		if 1+size > len(data) {
+			return nil, 0, ErrCorrupt
+		}
+		var err error
+		if weights, err = readWeights(data[1 : 1+size]); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		count := header - 127
+		size = (count + 1) / 2
+		if 1+size > len(data) {
+			return nil, 0, ErrCorrupt
+		}
+		weights = make([]byte, count)
+		for i := range weights {
+			if i%2 == 0 {
+				weights[i] = data[1+i/2] >> 4
+			} else {
+				weights[i] = data[1+i/2] & 15
+			}
+		}
+	}
+	table, err := newHuffmanTable(weights)
+	return table, 1 + size, err
+}
+
+// readWeights decodes FSE compressed Huffman weights. Two states take turns decoding from the same stream, which ends
+// once updating a state would read past the start of the stream, after which the other state's symbol is the last one.
+func readWeights(data []byte) ([]byte, error) {
+	counts, log, n, err := readDistribution(data, maxHuffmanBits, maxWeightsLog)
+	if err != nil {
+		return nil, err
+	}
+	table, err := newFSETable(counts, log)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newBackwardReader(data[n:])
+	if err != nil {
+		return nil, err
+	}
+	states := [2]uint64{r.readBits(log), r.readBits(log)}
+	weights := make([]byte, 0, 256)
+	for i := 0; ; i ^= 1 {
+		entry := table.entries[states[i]]
+		weights = append(weights, entry.symbol)
+		states[i] = uint64(entry.base) + r.readBits(uint(entry.bits))
+		if r.pos < 0 {
+			weights = append(weights, table.entries[states[i^1]].symbol)
+			break
+		}
+		if len(weights) > 255 {
+			return nil, ErrCorrupt
+		}
+	}
+	if len(weights) > 255 {
+		return nil, ErrCorrupt
+	}
+	return weights, nil
+}
+
+// newHuffmanTable builds a decoding table from the weights of every symbol but the last. A symbol of weight w has
+// a code maxBits+1-w bits long, and codes are handed out from the lowest weight up, then from the lowest symbol up.
+func newHuffmanTable(weights []byte) (*huffmanTable, error) {
+	if len(weights) == 0 || len(weights) > 255 {
+		return nil, ErrCorrupt
+	}
+	total := 0
+	for _, weight := range weights {
+		if weight > maxHuffmanBits {
+			return nil, ErrCorrupt
+		} else if weight > 0 {
+			total += 1 << (weight - 1)
+		}
+	}
+	if total == 0 {
+		return nil, ErrCorrupt
+	}
+	maxBits := uint(bits.Len(uint(total)))
+	leftover := 1<<maxBits - total
+	if maxBits > maxHuffmanBits || leftover&(leftover-1) != 0 {
+		return nil, ErrCorrupt
+	}
+	weights = append(weights[:len(weights):len(weights)], byte(bits.Len(uint(leftover))))
+
+	t := &huffmanTable{maxBits: maxBits, entries: make([]huffmanEntry, 1<<maxBits)}
+	pos := 0
+	for weight := byte(1); weight <= byte(maxBits); weight++ {
+		for symbol, w := range weights {
+			if w != weight {
+				continue
+			}
+			entry := huffmanEntry{symbol: byte(symbol), bits: uint8(maxBits + 1 - uint(weight))}
+			for k := 0; k < 1<<(weight-1); k++ {
+				t.entries[pos] = entry
+				pos++
+			}
+		}
+	}
+	return t, nil
+}
+
+// decodeStream appends the n symbols of one Huffman coded stream to dst, which has to use up the stream exactly
+func (t *huffmanTable) decodeStream(dst []byte, src []byte, n int) ([]byte, error) {
+	r, err := newBackwardReader(src)
+	if err != nil {
+		return dst, err
+	}
+	for i := 0; i < n; i++ {
+		entry := t.entries[r.peekBits(t.maxBits)]
+		dst = append(dst, entry.symbol)
+		r.pos -= int(entry.bits)
+	}
+	if r.pos != 0 {
+		return dst, ErrCorrupt
+	}
+	return dst, nil
+}
+
+// decode returns the size literals of either 1 or 4 Huffman coded streams. With 4 streams a jump table gives
+// the sizes of the first 3 and every stream but the last decodes a quarter of the literals, rounded up.
+func (t *huffmanTable) decode(src []byte, size int, streams int) ([]byte, error) {
+	literals := make([]byte, 0, size)
+	if streams == 1 {
+		return t.decodeStream(literals, src, size)
+	}
+	if len(src) < 6 {
+		return nil, ErrCorrupt
+	}
+	sizes := [4]int{int(binary.LittleEndian.Uint16(src)), int(binary.LittleEndian.Uint16(src[2:])), int(binary.LittleEndian.Uint16(src[4:]))}
+	sizes[3] = len(src) - 6 - sizes[0] - sizes[1] - sizes[2]
+	segment := (size + 3) / 4
+	if sizes[3] < 0 || size-3*segment < 0 {
+		return nil, ErrCorrupt
+	}
+	src = src[6:]
+	for i, streamSize := range sizes {
+		n := segment
+		if i == 3 {
+			n = size - 3*segment
+		}
+		var err error
+		if literals, err = t.decodeStream(literals, src[:streamSize], n); err != nil {
+			return nil, err
+		}
+		src = src[streamSize:]
+	}
+	return literals, nil
+}
+
+// readLiterals decodes the literals section at the start of a compressed block, returning the literals and the size of the section.
+// Compressed literals replace the block's Huffman table, which treeless literals in later blocks of the frame go on to use.
+func (d *decoder) readLiterals(block []byte) ([]byte, int, error) {
+	if len(block) == 0 {
+		return nil, 0, ErrCorrupt
+	}
+	kind := block[0] & 3
+	format := block[0] >> 2 & 3
+
+	if kind == rawLiterals || kind == rleLiterals {
+		var size, header int
+		switch format {
+		case 0, 2:
+			size, header = int(block[0]>>3), 1
+		case 1:
+			if len(block) < 2 {
+				return nil, 0, ErrCorrupt
+			}
+			size, header = int(block[0]>>4)|int(block[1])<<4, 2
+		case 3:
+			if len(block) < 3 {
+				return nil, 0, ErrCorrupt
+			}
+			size, header = int(block[0]>>4)|int(block[1])<<4|int(block[2])<<12, 3
+		}
+		if size > maxBlockSize {
+			return nil, 0, ErrCorrupt
+		}
+		if kind == rleLiterals {
+			if len(block) < header+1 {
+				return nil, 0, ErrCorrupt
+			}
+			return bytes.Repeat(block[header:header+1], size), header + 1, nil
+		}
+		if len(block) < header+size {
+			return nil, 0, ErrCorrupt
+		}
+		return block[header : header+size], header + size, nil
+	}
+
+	// The sizes of compressed literals are packed together after the 4 bits of the type and format
+	streams, header := 4, 0
+	var size, compressedSize int
+	switch format {
+	case 0, 1:
+		if format == 0 {
+			streams = 1
+		}
+		header = 3
+		if len(block) < header {
+			return nil, 0, ErrCorrupt
+		}
+		sizes := int(block[0]) | int(block[1])<<8 | int(block[2])<<16
+		size, compressedSize = sizes>>4&0x3ff, sizes>>14&0x3ff
+	case 2:
+		header = 4
+		if len(block) < header {
+			return nil, 0, ErrCorrupt
+		}
+		sizes := int(binary.LittleEndian.Uint32(block))
+		size, compressedSize = sizes>>4&0x3fff, sizes>>18&0x3fff
+	case 3:
+		header = 5
+		if len(block) < header {
+			return nil, 0, ErrCorrupt
+		}
+		sizes := uint64(binary.LittleEndian.Uint32(block)) | uint64(block[4])<<32
+		size, compressedSize = int(sizes>>4&0x3ffff), int(sizes>>22&0x3ffff)
+	}
+	if size > maxBlockSize || len(block) < header+compressedSize {
+		return nil, 0, ErrCorrupt
+	}
+	src := block[header : header+compressedSize]
+	if kind == compressedLiterals {
+		table, n, err := readHuffmanTable(src)
+		if err != nil {
+			return nil, 0, err
+		}
+		d.huffman = table
+		src = src[n:]
+	} else if d.huffman == nil {
+		return nil, 0, ErrCorrupt
+	}
+	literals, err := d.huffman.decode(src, size, streams)
+	return literals, header + compressedSize, err
+}
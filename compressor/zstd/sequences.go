@@ -0,0 +1,233 @@
+package zstd
+
+const (
+	predefinedMode = 0
+	rleMode        = 1
+	fseMode        = 2
+	repeatMode     = 3
+
+	maxLiteralLengthSymbol = 35
+	maxMatchLengthSymbol   = 52
+	maxOffsetSymbol        = 31
+	maxLiteralLengthLog    = 9
+	maxMatchLengthLog      = 9
+	maxOffsetLog           = 8
+)
+
+// The predefined distributions used by blocks that don't describe their own tables
+var (
+	literalLengthTable = mustFSETable([]int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}, 6)
+	matchLengthTable = mustFSETable([]int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}, 6)
+	offsetTable = mustFSETable([]int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}, 5)
+)
+
+// Literal and match length codes stand for a base value plus a number of extra bits read from the stream
+var (
+	literalLengthBase = [maxLiteralLengthSymbol + 1]int{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536,
+	}
+	literalLengthBits = [maxLiteralLengthSymbol + 1]uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+	matchLengthBase = [maxMatchLengthSymbol + 1]int{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539,
+	}
+	matchLengthBits = [maxMatchLengthSymbol + 1]uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+)
+
+// readTable returns the table a block uses for one kind of code, along with how many bytes describing it took up.
+// Repeat mode carries on with the table of the previous block in the frame.
+func readTable(mode byte, data []byte, previous *fseTable, predefined *fseTable, maxSymbol int, maxLog uint) (*fseTable, int, error) {
+	switch mode {
+	case predefinedMode:
+		return predefined, 0, nil
+	case rleMode:
+		if len(data) == 0 || int(data[0]) > maxSymbol {
+			return nil, 0, ErrCorrupt
+		}
+		return newRLETable(data[0]), 1, nil
+	case fseMode:
+		counts, log, n, err := readDistribution(data, maxSymbol, maxLog)
+		if err != nil {
+			return nil, 0, err
+		}
+		table, err := newFSETable(counts, log)
+		return table, n, err
+	default:
+		if previous == nil {
+			return nil, 0, ErrCorrupt
+		}
+		return previous, 0, nil
+	}
+}
+
+// executeSequences decodes the sequences section of a compressed block and appends the block's contents to the output.
+// Every sequence copies some literals and then a match, and whatever literals are left over come last.
+func (d *decoder) executeSequences(block []byte, literals []byte) error {
+	if len(block) == 0 {
+		return ErrCorrupt
+	}
+	count, i := int(block[0]), 1
+	switch {
+	case count == 255:
+		if len(block) < 3 {
+			return ErrCorrupt
+		}
+		count, i = int(block[1])+int(block[2])<<8+0x7f00, 3
+	case count >= 128:
+		if len(block) < 2 {
+			return ErrCorrupt
+		}
+		count, i = (count-128)<<8+int(block[1]), 2
+	}
+	blockStart := len(d.output)
+	if count == 0 {
+		if i != len(block) {
+			return ErrCorrupt
+		}
+		return d.appendLiterals(literals, blockStart)
+	}
+
+	if i >= len(block) || block[i]&3 != 0 {
+		return ErrCorrupt
+	}
+	modes := block[i]
+	i++
+	var n int
+	var err error
+	if d.literalLengths, n, err = readTable(modes>>6, block[i:], d.literalLengths, literalLengthTable, maxLiteralLengthSymbol, maxLiteralLengthLog); err != nil {
+		return err
+	}
+	i += n
+	if d.offsets, n, err = readTable(modes>>4&3, block[i:], d.offsets, offsetTable, maxOffsetSymbol, maxOffsetLog); err != nil {
+		return err
+	}
+	i += n
+	if d.matchLengths, n, err = readTable(modes>>2&3, block[i:], d.matchLengths, matchLengthTable, maxMatchLengthSymbol, maxMatchLengthLog); err != nil {
+		return err
+	}
+	i += n
+
+	r, err := newBackwardReader(block[i:])
+	if err != nil {
+		return err
+	}
+	literalLengthState := r.readBits(d.literalLengths.log)
+	offsetState := r.readBits(d.offsets.log)
+	matchLengthState := r.readBits(d.matchLengths.log)
+	for s := 0; s < count; s++ {
+		literalLengthEntry := d.literalLengths.entries[literalLengthState]
+		offsetEntry := d.offsets.entries[offsetState]
+		matchLengthEntry := d.matchLengths.entries[matchLengthState]
+
+		// The extra bits come offset first, and the states are updated literal length first
+		offsetCode := uint(offsetEntry.symbol)
+		offsetValue := 1<<offsetCode + int(r.readBits(offsetCode))
+		matchLength := matchLengthBase[matchLengthEntry.symbol] + int(r.readBits(matchLengthBits[matchLengthEntry.symbol]))
+		literalLength := literalLengthBase[literalLengthEntry.symbol] + int(r.readBits(literalLengthBits[literalLengthEntry.symbol]))
+		if s < count-1 {
+			literalLengthState = uint64(literalLengthEntry.base) + r.readBits(uint(literalLengthEntry.bits))
+			matchLengthState = uint64(matchLengthEntry.base) + r.readBits(uint(matchLengthEntry.bits))
+			offsetState = uint64(offsetEntry.base) + r.readBits(uint(offsetEntry.bits))
+		}
+		if r.pos < 0 {
+			return ErrCorrupt
+		}
+
+		offset, err := d.offset(offsetValue, literalLength)
+		if err != nil {
+			return err
+		}
+		if literalLength > len(literals) {
+			return ErrCorrupt
+		}
+		if err := d.grow(blockStart, literalLength+matchLength); err != nil {
+			return err
+		}
+		d.output = append(d.output, literals[:literalLength]...)
+		literals = literals[literalLength:]
+		if offset > len(d.output)-d.frameStart {
+			return ErrCorrupt
+		}
+		start := len(d.output) - offset
+		if offset >= matchLength {
+			d.output = append(d.output, d.output[start:start+matchLength]...)
+		} else {
+			// The match overlaps the bytes it produces so it has to be copied a byte at a time
+			for k := 0; k < matchLength; k++ {
+				d.output = append(d.output, d.output[start+k])
+			}
+		}
+	}
+	if r.pos != 0 {
+		return ErrCorrupt
+	}
+	return d.appendLiterals(literals, blockStart)
+}
+
+// offset turns the offset value of a sequence into a distance, keeping track of the last 3 distances used.
+// Values of 3 and under repeat one of them, shifted along by one when the sequence has no literals, since
+// repeating the last distance straight after a match would have made for a longer match instead.
+func (d *decoder) offset(value int, literalLength int) (int, error) {
+	if value > 3 {
+		offset := value - 3
+		d.repeats = [3]int{offset, d.repeats[0], d.repeats[1]}
+		return offset, nil
+	}
+	index := value - 1
+	if literalLength == 0 {
+		index++
+	}
+	switch index {
+	case 0:
+		return d.repeats[0], nil
+	case 1:
+		offset := d.repeats[1]
+		d.repeats[1], d.repeats[0] = d.repeats[0], offset
+		return offset, nil
+	case 2:
+		offset := d.repeats[2]
+		d.repeats[2], d.repeats[1], d.repeats[0] = d.repeats[1], d.repeats[0], offset
+		return offset, nil
+	default:
+		offset := d.repeats[0] - 1
+		if offset == 0 {
+			return 0, ErrCorrupt
+		}
+		d.repeats = [3]int{offset, d.repeats[0], d.repeats[1]}
+		return offset, nil
+	}
+}
+
+// appendLiterals appends the literals left over after a block's last sequence
+func (d *decoder) appendLiterals(literals []byte, blockStart int) error {
+	if err := d.grow(blockStart, len(literals)); err != nil {
+		return err
+	}
+	d.output = append(d.output, literals...)
+	return nil
+}
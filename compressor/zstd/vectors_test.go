@@ -0,0 +1,28 @@
+package zstd
+
+import (
+	"bytes"
+	"github.com/go-compression/raisin/internal/codectest"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+)
+
+// vectorInputs returns the contents of the frames in testdata, which were written by the zstd command line tool
+func vectorInputs() map[string][]byte {
+	random := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(random)
+	// Words picked at random make for literals and matches that aren't as regular as repeated text, over several blocks
+	words, err := ioutil.ReadFile(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		panic(err)
+	}
+	return map[string][]byte{
+		"empty":    {},
+		"text":     []byte(codectest.Text),
+		"repeated": bytes.Repeat([]byte(codectest.Text), 200),
+		"random":   random,
+		"zeros":    make([]byte, 300000),
+		"words":    words,
+	}
+}
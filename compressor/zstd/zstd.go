@@ -0,0 +1,270 @@
+// Package zstd implements a decoder for the Zstandard compression format, as described in RFC 8878.
+// It reads frames written by the zstd command line tool at any level, but doesn't compress and doesn't support preset dictionaries.
+package zstd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	codec "github.com/go-compression/raisin/compressor/codec"
+	"io"
+	"io/ioutil"
+
+	xxhash "github.com/go-compression/raisin/compressor/xxhash"
+)
+
+const (
+	frameMagic         = 0xfd2fb528
+	skippableMagic     = 0x184d2a50
+	skippableMagicMask = 0xfffffff0
+
+	rawBlock        = 0
+	rleBlock        = 1
+	compressedBlock = 2
+
+	// maxBlockSize is the most a block can hold, whatever the window size of its frame
+	maxBlockSize = 128 << 10
+)
+
+var (
+	// ErrCorrupt is returned when decompressing a frame that is malformed
+	ErrCorrupt = errors.New("zstd: corrupt input")
+	// ErrChecksum is returned when the content checksum of a frame doesn't match
+	ErrChecksum = errors.New("zstd: checksum mismatch")
+	// ErrTooLarge is returned when a stream decompresses to more than the limit
+	ErrTooLarge = errors.New("zstd: decompressed size exceeds limit")
+	// ErrDictionary is returned for frames that need a preset dictionary, which isn't supported
+	ErrDictionary = errors.New("zstd: preset dictionaries are not supported")
+)
+
+// decoder holds the output of every frame decoded so far along with the state that carries over from one block of a frame to the next
+type decoder struct {
+	ctx        context.Context
+	output     []byte
+	limit      int
+	frameStart int
+
+	repeats                               [3]int
+	huffman                               *huffmanTable
+	literalLengths, offsets, matchLengths *fseTable
+}
+
+// Decompress takes one or more Zstandard frames and returns their decompressed contents
+func Decompress(content []byte) ([]byte, error) {
+	return DecompressLimit(content, 0)
+}
+
+// DecompressLimit is like Decompress but returns ErrTooLarge once the output would exceed limit bytes, a limit of 0 means no limit.
+// Skippable frames between frames are passed over.
+func DecompressLimit(content []byte, limit int) ([]byte, error) {
+	return decompress(context.Background(), content, limit)
+}
+
+func decompress(ctx context.Context, content []byte, limit int) ([]byte, error) {
+	d := &decoder{ctx: ctx, limit: limit, output: make([]byte, 0, 4*len(content))}
+	if len(content) == 0 {
+		return nil, ErrCorrupt
+	}
+	for len(content) > 0 {
+		if len(content) < 4 {
+			return d.output, ErrCorrupt
+		}
+		magic := binary.LittleEndian.Uint32(content)
+		if magic&skippableMagicMask == skippableMagic {
+			if len(content) < 8 || uint64(binary.LittleEndian.Uint32(content[4:])) > uint64(len(content)-8) {
+				return d.output, ErrCorrupt
+			}
+			content = content[8+binary.LittleEndian.Uint32(content[4:]):]
+			continue
+		} else if magic != frameMagic {
+			return d.output, ErrCorrupt
+		}
+		n, err := d.decodeFrame(content[4:])
+		if err != nil {
+			return d.output, err
+		}
+		content = content[4+n:]
+	}
+	return d.output, nil
+}
+
+// decodeFrame decodes the frame after its magic number, returning how many bytes it took up
+func (d *decoder) decodeFrame(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, ErrCorrupt
+	}
+	descriptor := data[0]
+	i := 1
+	singleSegment := descriptor&0x20 != 0
+	if descriptor&0x08 != 0 {
+		return 0, ErrCorrupt
+	}
+
+	// Frames that are a single segment have no window descriptor and their window is the size of their content
+	windowSize := uint64(0)
+	if !singleSegment {
+		if i >= len(data) {
+			return 0, ErrCorrupt
+		}
+		exponent, mantissa := uint(data[i]>>3), uint64(data[i]&7)
+		windowSize = 1 << (10 + exponent)
+		windowSize += windowSize / 8 * mantissa
+		i++
+	}
+	dictionarySize := [4]int{0, 1, 2, 4}[descriptor&3]
+	if i+dictionarySize > len(data) {
+		return 0, ErrCorrupt
+	}
+	for k := 0; k < dictionarySize; k++ {
+		if data[i+k] != 0 {
+			return 0, ErrDictionary
+		}
+	}
+	i += dictionarySize
+	contentSizeSize := [4]int{0, 2, 4, 8}[descriptor>>6]
+	if contentSizeSize == 0 && singleSegment {
+		contentSizeSize = 1
+	}
+	if i+contentSizeSize > len(data) {
+		return 0, ErrCorrupt
+	}
+	contentSize := uint64(0)
+	for k := contentSizeSize - 1; k >= 0; k-- {
+		contentSize = contentSize<<8 | uint64(data[i+k])
+	}
+	if contentSizeSize == 2 {
+		contentSize += 256
+	}
+	i += contentSizeSize
+	if singleSegment {
+		windowSize = contentSize
+	}
+	blockLimit := maxBlockSize
+	if windowSize < maxBlockSize {
+		blockLimit = int(windowSize)
+	}
+
+	d.frameStart = len(d.output)
+	d.repeats = [3]int{1, 4, 8}
+	d.huffman = nil
+	d.literalLengths, d.offsets, d.matchLengths = nil, nil, nil
+	for last := false; !last; {
+		if err := d.ctx.Err(); err != nil {
+			return 0, err
+		}
+		if i+3 > len(data) {
+			return 0, ErrCorrupt
+		}
+		header := int(data[i]) | int(data[i+1])<<8 | int(data[i+2])<<16
+		i += 3
+		last = header&1 != 0
+		size := header >> 3
+		if size > blockLimit {
+			return 0, ErrCorrupt
+		}
+		switch header >> 1 & 3 {
+		case rawBlock:
+			if i+size > len(data) {
+				return 0, ErrCorrupt
+			}
+			if err := d.grow(len(d.output), size); err != nil {
+				return 0, err
+			}
+			d.output = append(d.output, data[i:i+size]...)
+			i += size
+		case rleBlock:
+			if i >= len(data) {
+				return 0, ErrCorrupt
+			}
+			if err := d.grow(len(d.output), size); err != nil {
+				return 0, err
+			}
+			for k := 0; k < size; k++ {
+				d.output = append(d.output, data[i])
+			}
+			i++
+		case compressedBlock:
+			if i+size > len(data) {
+				return 0, ErrCorrupt
+			}
+			if err := d.decodeBlock(data[i : i+size]); err != nil {
+				return 0, err
+			}
+			i += size
+		default:
+			return 0, ErrCorrupt
+		}
+	}
+
+	if contentSizeSize > 0 && uint64(len(d.output)-d.frameStart) != contentSize {
+		return 0, ErrCorrupt
+	}
+	if descriptor&0x04 != 0 {
+		if i+4 > len(data) {
+			return 0, ErrCorrupt
+		}
+		if uint32(xxhash.Sum64(d.output[d.frameStart:])) != binary.LittleEndian.Uint32(data[i:]) {
+			return 0, ErrChecksum
+		}
+		i += 4
+	}
+	return i, nil
+}
+
+// decodeBlock decodes a compressed block, which is a section of literals followed by a section of sequences
+func (d *decoder) decodeBlock(block []byte) error {
+	literals, n, err := d.readLiterals(block)
+	if err != nil {
+		return err
+	}
+	return d.executeSequences(block[n:], literals)
+}
+
+// grow checks that n more bytes fit in both the block that started at blockStart and the limit
+func (d *decoder) grow(blockStart int, n int) error {
+	if len(d.output)+n-blockStart > maxBlockSize {
+		return ErrCorrupt
+	} else if d.limit > 0 && len(d.output)+n > d.limit {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// Reader decompresses Zstandard frames, decoding the whole stream on the first call to Read
+type Reader struct {
+	r            io.Reader
+	ctx          context.Context
+	limit        int
+	decompressed *bytes.Reader
+}
+
+// NewReader creates an io.Reader object that decompresses Zstandard frames from an io.Reader
+func NewReader(r io.Reader) io.Reader {
+	return NewReaderContext(context.Background(), r, codec.Options{})
+}
+
+// NewReaderContext creates an io.Reader like NewReader that gives up between blocks once ctx is done and fails with ErrTooLarge
+// instead of decompressing more than opts.Limit bytes, a limit of 0 means no limit
+func NewReaderContext(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+	z := new(Reader)
+	z.r = r
+	z.ctx = ctx
+	z.limit = opts.Limit
+	return z
+}
+
+func (r *Reader) Read(content []byte) (n int, err error) {
+	if r.decompressed == nil {
+		compressed, err := ioutil.ReadAll(r.r)
+		if err != nil {
+			return 0, err
+		}
+		decompressed, err := decompress(r.ctx, compressed, r.limit)
+		if err != nil {
+			return 0, err
+		}
+		r.decompressed = bytes.NewReader(decompressed)
+	}
+	return r.decompressed.Read(content)
+}
@@ -0,0 +1,149 @@
+package zstd
+
+import (
+	"bytes"
+	"github.com/go-compression/raisin/internal/codectest"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+var vectors = []struct {
+	file  string
+	input string
+}{
+	{"empty.zst", "empty"},
+	{"text.zst", "text"},
+	{"repeated-1.zst", "repeated"},
+	{"repeated-19.zst", "repeated"},
+	{"words-1.zst", "words"},
+	{"words-19.zst", "words"},
+	{"words-stream.zst", "words"},
+	{"random.zst", "random"},
+	{"zeros.zst", "zeros"},
+}
+
+func TestDecompressVectors(t *testing.T) {
+	inputs := vectorInputs()
+	for _, vector := range vectors {
+		compressed, err := ioutil.ReadFile(filepath.Join("testdata", vector.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		decompressed, err := Decompress(compressed)
+		if err != nil {
+			t.Errorf("Failed to decompress %s: %v", vector.file, err)
+		} else if !bytes.Equal(decompressed, inputs[vector.input]) {
+			t.Errorf("%s did not decompress to %s", vector.file, vector.input)
+		}
+	}
+}
+
+func TestConcatenatedFrames(t *testing.T) {
+	// text-frames.zst holds the text twice in two frames, one with a checksum and one without
+	compressed, err := ioutil.ReadFile(filepath.Join("testdata", "text-frames.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := []byte(codectest.Text)
+	twice := append(append([]byte{}, text...), text...)
+	decompressed, err := Decompress(compressed)
+	if err != nil || !bytes.Equal(decompressed, twice) {
+		t.Errorf("Concatenated frames were not decompressed: %v", err)
+	}
+
+	// Skippable frames can go anywhere between frames
+	skippable := []byte{0x5a, 0x2a, 0x4d, 0x18, 3, 0, 0, 0, 'a', 'b', 'c'}
+	stream := append(append(append([]byte{}, skippable...), compressed...), skippable...)
+	decompressed, err = Decompress(stream)
+	if err != nil || !bytes.Equal(decompressed, twice) {
+		t.Errorf("Skippable frames were not passed over: %v", err)
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	compressed, err := ioutil.ReadFile(filepath.Join("testdata", "repeated-19.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flip := func(i int) []byte {
+		corrupt := append([]byte{}, compressed...)
+		corrupt[i] ^= 1
+		return corrupt
+	}
+	codectest.Corrupt(t, map[string][]byte{
+		"empty":            {},
+		"bad magic":        flip(0),
+		"reserved bit":     append([]byte{0x28, 0xb5, 0x2f, 0xfd, 0x08}, compressed[5:]...),
+		"truncated":        compressed[:len(compressed)-6],
+		"trailing garbage": append(append([]byte{}, compressed...), 1, 2),
+		"reserved block":   {0x28, 0xb5, 0x2f, 0xfd, 0x00, 0x00, 0x07, 0x00, 0x00},
+	}, ErrCorrupt, Decompress)
+	codectest.Corrupt(t, map[string][]byte{"content checksum": flip(len(compressed) - 1)}, ErrChecksum, Decompress)
+	codectest.Corrupt(t, map[string][]byte{"dictionary": {0x28, 0xb5, 0x2f, 0xfd, 0x01, 0x00, 0x07, 0x01, 0x00, 0x00}}, ErrDictionary, Decompress)
+
+	// Flipping a bit of the compressed data has to be caught, mostly by the checksum, unless it's one of the few bits the format ignores
+	codectest.Flips(t, vectorInputs()["repeated"], compressed, 4, 1, Decompress)
+}
+
+func TestDecompressLimit(t *testing.T) {
+	compressed, err := ioutil.ReadFile(filepath.Join("testdata", "words-19.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := vectorInputs()["words"]
+	codectest.Limit(t, content, compressed, ErrTooLarge, DecompressLimit)
+	codectest.ReaderLimit(t, content, compressed, ErrTooLarge, NewReaderContext)
+}
+
+func TestReader(t *testing.T) {
+	compressed, err := ioutil.ReadFile(filepath.Join("testdata", "words-1.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decompressed, err := ioutil.ReadAll(NewReader(bytes.NewReader(compressed)))
+	if err != nil || !bytes.Equal(decompressed, vectorInputs()["words"]) {
+		t.Errorf("Reader was not lossless: %v", err)
+	}
+}
+
+func TestZstdTool(t *testing.T) {
+	zstd, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Skip("zstd is not installed")
+	}
+	for name, content := range vectorInputs() {
+		for _, level := range []string{"-1", "-3", "-9", "-19", "--fast=5"} {
+			cmd := exec.Command(zstd, "-c", "-q", level)
+			cmd.Stdin = bytes.NewReader(content)
+			compressed, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("zstd %s could not compress %s: %v", level, name, err)
+			}
+			decompressed, err := Decompress(compressed)
+			if err != nil {
+				t.Errorf("Could not decompress %s written by zstd %s: %v", name, level, err)
+			} else if !bytes.Equal(decompressed, content) {
+				t.Errorf("%s written by zstd %s did not decompress losslessly", name, level)
+			}
+		}
+	}
+}
+
+// FuzzDecompress checks that no input makes the decoder panic or decompress past its limit
+func FuzzDecompress(f *testing.F) {
+	for _, vector := range vectors {
+		compressed, err := ioutil.ReadFile(filepath.Join("testdata", vector.file))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(compressed)
+	}
+	const limit = 1 << 20
+	f.Fuzz(func(t *testing.T, content []byte) {
+		if decompressed, err := DecompressLimit(content, limit); len(decompressed) > limit {
+			t.Fatalf("Decompressed %d bytes past the limit of %d: %v", len(decompressed), limit, err)
+		}
+	})
+}
@@ -0,0 +1,219 @@
+// Package corpus generates deterministic synthetic files for benchmarking and testing without network access.
+// The same class, size and seed always produce the same bytes.
+package corpus
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Classes is a slice of strings representing the kinds of files that can be generated.
+var Classes = [...]string{"text", "json", "random", "repetitive", "sparse", "numeric"}
+
+// generators maps every class to the function generating it and the extension of the file written by Write.
+var generators = map[string]struct {
+	generate  func(r *rand.Rand, size int) []byte
+	extension string
+}{
+	"text":       {generateText, "txt"},
+	"json":       {generateJSONLogs, "jsonl"},
+	"random":     {generateRandom, "bin"},
+	"repetitive": {generateRepetitive, "txt"},
+	"sparse":     {generateSparse, "bin"},
+	"numeric":    {generateNumeric, "csv"},
+}
+
+// Settings represents an object that can be used to modify the files written by Write.
+// Size is the size of every file in bytes and Seed the seed they are generated from.
+type Settings struct {
+	Size    int
+	Seed    int64
+	Classes []string
+}
+
+// NewSettings returns the default settings for Write as a Settings object, 256 KiB of every class with seed 1.
+func NewSettings() Settings {
+	s := Settings{}
+	s.Size = 256 * 1024
+	s.Seed = 1
+	s.Classes = Classes[:]
+	return s
+}
+
+// Generate returns size bytes of the given class generated from seed.
+func Generate(class string, size int, seed int64) ([]byte, error) {
+	generator, ok := generators[class]
+	if !ok {
+		return nil, fmt.Errorf("corpus: unknown class: %s", class)
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("corpus: invalid size: %d", size)
+	}
+	r := rand.New(rand.NewSource(seed))
+	return generator.generate(r, size)[:size], nil
+}
+
+// Write generates a file for every class in settings into dir, creating it if needed, and returns their paths.
+// Files are named after their class, for example text.txt or numeric.csv.
+func Write(dir string, settings Settings) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(settings.Classes))
+	for _, class := range settings.Classes {
+		content, err := Generate(class, settings.Size, settings.Seed)
+		if err != nil {
+			return paths, err
+		}
+		path := filepath.Join(dir, class+"."+generators[class].extension)
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+var words = strings.Fields(`the of and to a in is it you that he was for on are with as his they be at one
+have this from or had by hot word but what some we can out other were all there when up use your how said an
+each she which do their time if will way about many then them write would like so these her long make thing see
+him two has look more day could go come did number sound no most people my over know water than call first who
+may down side been now find any new work part take get place made live where after back little only round man
+year came show every good me give our under name very through just form sentence great think say help low line
+differ turn cause much mean before move right boy old too same tell does set three want air well also play small
+end put home read hand port large spell add even land here must big high such follow act why ask men change went
+light kind off need house picture try us again animal point mother world near build self earth father head stand
+own page should country found answer school grow study still learn plant cover food sun four between state keep
+eye never last let thought city tree cross farm hard start might story saw far sea draw left late run while press
+close night real life few north open seem together next white children begin got walk example ease paper group
+always music those both mark often letter until mile river car feet care second book carry took science eat room
+friend began idea fish mountain stop once base hear horse cut sure watch color face wood main enough plain girl
+usual young ready above ever red list though feel talk bird soon body dog family direct pose leave song measure
+door product black short numeral class wind question happen complete ship area half rock order fire south problem`)
+
+// generateText writes sentences of words picked with a Zipf distribution so that common words dominate like in English.
+func generateText(r *rand.Rand, size int) []byte {
+	var b bytes.Buffer
+	zipf := rand.NewZipf(r, 1.1, 2, uint64(len(words)-1))
+	punctuation := []string{".", ".", ".", "?", "!", ";", ","}
+	for b.Len() < size {
+		sentences := 2 + r.Intn(5)
+		for s := 0; s < sentences; s++ {
+			length := 4 + r.Intn(14)
+			for w := 0; w < length; w++ {
+				word := words[zipf.Uint64()]
+				if w == 0 {
+					word = strings.ToUpper(word[:1]) + word[1:]
+				} else {
+					b.WriteByte(' ')
+				}
+				b.WriteString(word)
+			}
+			b.WriteString(punctuation[r.Intn(len(punctuation))])
+			b.WriteByte(' ')
+		}
+		b.WriteString("\n\n")
+	}
+	return b.Bytes()
+}
+
+// generateJSONLogs writes one structured log line per request, with increasing timestamps and repeating field names.
+func generateJSONLogs(r *rand.Rand, size int) []byte {
+	var b bytes.Buffer
+	levels := []string{"debug", "info", "info", "info", "warn", "error"}
+	services := []string{"api", "auth", "billing", "search", "worker"}
+	methods := []string{"GET", "GET", "GET", "POST", "PUT", "DELETE"}
+	paths := []string{"/users", "/users/%d", "/orders", "/orders/%d", "/search", "/health", "/login"}
+	statuses := []int{200, 200, 200, 200, 201, 204, 301, 400, 404, 500}
+	messages := []string{"request completed", "request completed", "cache miss", "slow query", "retrying upstream", "token refreshed"}
+	timestamp := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for b.Len() < size {
+		timestamp = timestamp.Add(time.Duration(r.Intn(2000)) * time.Millisecond)
+		path := paths[r.Intn(len(paths))]
+		if strings.Contains(path, "%d") {
+			path = fmt.Sprintf(path, r.Intn(100000))
+		}
+		fmt.Fprintf(&b, `{"time":"%s","level":"%s","service":"%s","method":"%s","path":"%s","status":%d,"latency_ms":%.3f,"request_id":"%016x","msg":"%s"}`+"\n",
+			timestamp.Format("2006-01-02T15:04:05.000Z"),
+			levels[r.Intn(len(levels))],
+			services[r.Intn(len(services))],
+			methods[r.Intn(len(methods))],
+			path,
+			statuses[r.Intn(len(statuses))],
+			r.ExpFloat64()*25,
+			r.Uint64(),
+			messages[r.Intn(len(messages))])
+	}
+	return b.Bytes()
+}
+
+// generateRandom writes uniformly random bytes, which no algorithm should be able to compress.
+func generateRandom(r *rand.Rand, size int) []byte {
+	content := make([]byte, size)
+	r.Read(content)
+	return content
+}
+
+// generateRepetitive writes a handful of phrases and byte runs over and over with the occasional typo.
+func generateRepetitive(r *rand.Rand, size int) []byte {
+	var b bytes.Buffer
+	phrases := make([][]byte, 4+r.Intn(4))
+	for i := range phrases {
+		phrase := make([]byte, 8+r.Intn(56))
+		for j := range phrase {
+			phrase[j] = 'a' + byte(r.Intn(26))
+		}
+		phrases[i] = append(phrase, '\n')
+	}
+	for b.Len() < size {
+		if r.Intn(8) == 0 {
+			b.Write(bytes.Repeat([]byte{byte('A' + r.Intn(26))}, 16+r.Intn(240)))
+			continue
+		}
+		phrase := phrases[r.Intn(len(phrases))]
+		if r.Intn(32) == 0 {
+			phrase = append([]byte{}, phrase...)
+			phrase[r.Intn(len(phrase)-1)] = 'a' + byte(r.Intn(26))
+		}
+		b.Write(phrase)
+	}
+	return b.Bytes()
+}
+
+// generateSparse writes mostly zero bytes broken up by short runs of random bytes, like a sparse binary file or disk image.
+func generateSparse(r *rand.Rand, size int) []byte {
+	content := make([]byte, size+256)
+	for i := 0; i < size; {
+		i += int(r.ExpFloat64() * 512)
+		length := 1 + r.Intn(24)
+		if i+length > size {
+			break
+		}
+		r.Read(content[i : i+length])
+		i += length
+	}
+	return content
+}
+
+// generateNumeric writes a csv table of sensor readings that follow a random walk, so neighbouring rows are similar.
+func generateNumeric(r *rand.Rand, size int) []byte {
+	var b bytes.Buffer
+	b.WriteString("id,timestamp,sensor,temperature,humidity,pressure,count\n")
+	temperature, humidity, pressure := 20.0, 50.0, 1013.25
+	timestamp := int64(1577836800)
+	for id := 1; b.Len() < size; id++ {
+		timestamp += int64(1 + r.Intn(60))
+		temperature += r.NormFloat64() * 0.2
+		humidity = math.Max(0, math.Min(100, humidity+r.NormFloat64()*0.5))
+		pressure += r.NormFloat64() * 0.1
+		fmt.Fprintf(&b, "%d,%d,s%02d,%.2f,%.1f,%.2f,%d\n", id, timestamp, r.Intn(16), temperature, humidity, pressure, r.Intn(1000))
+	}
+	return b.Bytes()
+}
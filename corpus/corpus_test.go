@@ -0,0 +1,73 @@
+package corpus
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateDeterministic(t *testing.T) {
+	for _, class := range Classes {
+		first, err := Generate(class, 10000, 42)
+		if err != nil {
+			t.Fatalf("Generate %s errored: %s", class, err)
+		}
+		second, _ := Generate(class, 10000, 42)
+		other, _ := Generate(class, 10000, 43)
+		if len(first) != 10000 {
+			t.Errorf("Expected 10000 bytes of %s, got %d", class, len(first))
+		}
+		if !bytes.Equal(first, second) {
+			t.Errorf("Generate %s is not deterministic", class)
+		}
+		if bytes.Equal(first, other) {
+			t.Errorf("Generate %s ignores the seed", class)
+		}
+	}
+}
+
+func TestGenerateJSONLines(t *testing.T) {
+	content, _ := Generate("json", 4096, 1)
+	lines := bytes.Split(content, []byte("\n"))
+	// The last line is cut off at the requested size
+	for _, line := range lines[:len(lines)-1] {
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(line, &decoded); err != nil {
+			t.Fatalf("Invalid json line %q: %s", line, err)
+		}
+	}
+}
+
+func TestGenerateUnknownClass(t *testing.T) {
+	if _, err := Generate("nope", 10, 1); err == nil {
+		t.Errorf("Expected an error for an unknown class")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raisin-corpus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	settings := NewSettings()
+	settings.Size = 100
+	settings.Classes = []string{"text", "numeric"}
+	paths, err := Write(dir, settings)
+	if err != nil {
+		t.Fatalf("Write errored: %s", err)
+	}
+	expected := []string{filepath.Join(dir, "text.txt"), filepath.Join(dir, "numeric.csv")}
+	for i, path := range expected {
+		if paths[i] != path {
+			t.Errorf("Expected %s, got %s", path, paths[i])
+		}
+		if info, err := os.Stat(path); err != nil || info.Size() != 100 {
+			t.Errorf("Expected %s to be 100 bytes", path)
+		}
+	}
+}
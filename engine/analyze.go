@@ -0,0 +1,307 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+)
+
+// AnalyzeSettings represents an object that can be used to modify the settings when analyzing files with Analyze
+// MaxOrder is the highest order of conditional entropy computed (at most 7), WindowSize the size of the blocks
+// the windowed entropy is computed over, and MatchWindow how far back matches are searched for.
+type AnalyzeSettings struct {
+	MaxOrder    int
+	WindowSize  int
+	MatchWindow int
+	MinMatch    int
+}
+
+// NewAnalyzeSettings returns the default settings for Analyze as an AnalyzeSettings object
+func NewAnalyzeSettings() AnalyzeSettings {
+	s := AnalyzeSettings{}
+	s.MaxOrder = 3
+	s.WindowSize = 4096
+	s.MatchWindow = 32 * 1024
+	s.MinMatch = 3
+	return s
+}
+
+// MaxAnalyzeOrder is the highest order of conditional entropy Analyze supports, since contexts are packed into an integer.
+const MaxAnalyzeOrder = 7
+
+// OrderEntropy represents the entropy of each byte given the Order bytes before it.
+// EstimatedBytes is the size an ideal order-Order model would compress the file to, ignoring the cost of the model itself.
+type OrderEntropy struct {
+	Order          int     `json:"order"`
+	BitsPerByte    float64 `json:"bits_per_byte"`
+	Contexts       int     `json:"contexts"`
+	EstimatedBytes int64   `json:"estimated_bytes"`
+}
+
+// WindowEntropy represents the order-0 entropy of a single block of a file.
+type WindowEntropy struct {
+	Offset      int64   `json:"offset"`
+	BitsPerByte float64 `json:"bits_per_byte"`
+}
+
+// MatchBucket represents how many matches had a length between MinLength and MaxLength inclusive.
+type MatchBucket struct {
+	MinLength int `json:"min_length"`
+	MaxLength int `json:"max_length"`
+	Count     int `json:"count"`
+}
+
+// MatchStats represents the matches found by a greedy LZ77 parse of a file, an estimate of how much dictionary coders can gain.
+type MatchStats struct {
+	Matches         int           `json:"matches"`
+	MatchedBytes    int64         `json:"matched_bytes"`
+	MatchedFraction float64       `json:"matched_fraction"`
+	MeanLength      float64       `json:"mean_length"`
+	MaxLength       int           `json:"max_length"`
+	MeanDistance    float64       `json:"mean_distance"`
+	Lengths         []MatchBucket `json:"lengths"`
+}
+
+// Analysis represents the statistics of a file that hint at which algorithms will compress it well.
+type Analysis struct {
+	Path      string          `json:"path"`
+	Size      int64           `json:"size"`
+	Symbols   int             `json:"symbols"`
+	Entropy   []OrderEntropy  `json:"entropy"`
+	Windows   []WindowEntropy `json:"windows"`
+	Histogram [256]int64      `json:"histogram"`
+	Matches   MatchStats      `json:"matches"`
+}
+
+// AnalyzeFile takes a path to a file and settings and returns the Analysis of its contents.
+func AnalyzeFile(path string, settings AnalyzeSettings) (Analysis, error) {
+	fileContents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Analysis{}, err
+	}
+	analysis, err := Analyze(fileContents, settings)
+	analysis.Path = path
+	return analysis, err
+}
+
+// Analyze takes the contents of a file and settings and returns its entropy of every order up to settings.MaxOrder,
+// its entropy per window, its byte histogram and the matches a dictionary coder would find.
+// Higher orders underestimate the entropy of small files since most contexts are only seen a few times.
+func Analyze(content []byte, settings AnalyzeSettings) (Analysis, error) {
+	if settings.MaxOrder < 0 || settings.MaxOrder > MaxAnalyzeOrder {
+		return Analysis{}, fmt.Errorf("raisin: order must be between 0 and %d, got %d", MaxAnalyzeOrder, settings.MaxOrder)
+	}
+	analysis := Analysis{Size: int64(len(content))}
+
+	for _, c := range content {
+		analysis.Histogram[c]++
+	}
+	for _, count := range analysis.Histogram {
+		if count > 0 {
+			analysis.Symbols++
+		}
+	}
+
+	for order := 0; order <= settings.MaxOrder; order++ {
+		bits, contexts := conditionalEntropy(content, order)
+		analysis.Entropy = append(analysis.Entropy, OrderEntropy{
+			Order:          order,
+			BitsPerByte:    bits,
+			Contexts:       contexts,
+			EstimatedBytes: int64(math.Ceil(bits * float64(len(content)) / 8)),
+		})
+	}
+
+	if settings.WindowSize > 0 {
+		for offset := 0; offset < len(content); offset += settings.WindowSize {
+			end := offset + settings.WindowSize
+			if end > len(content) {
+				end = len(content)
+			}
+			bits, _ := conditionalEntropy(content[offset:end], 0)
+			analysis.Windows = append(analysis.Windows, WindowEntropy{Offset: int64(offset), BitsPerByte: bits})
+		}
+	}
+
+	analysis.Matches = matchStats(content, settings.MatchWindow, settings.MinMatch)
+	return analysis, nil
+}
+
+// conditionalEntropy returns the entropy in bits of every byte given the order bytes before it, along with the number of distinct contexts.
+func conditionalEntropy(content []byte, order int) (float64, int) {
+	if len(content) <= order {
+		return 0, 0
+	}
+	// Contexts are the previous bytes packed into an integer, joint counts add the byte itself in the low 8 bits
+	contextCounts := make(map[uint64]int)
+	jointCounts := make(map[uint64]int)
+	var context uint64
+	mask := uint64(1)<<(8*uint(order)) - 1
+	for i, c := range content {
+		if i >= order {
+			contextCounts[context]++
+			jointCounts[context<<8|uint64(c)]++
+		}
+		if order > 0 {
+			context = (context<<8 | uint64(c)) & mask
+		}
+	}
+
+	total := float64(len(content) - order)
+	entropy := 0.0
+	for joint, count := range jointCounts {
+		probability := float64(count) / float64(contextCounts[joint>>8])
+		entropy -= float64(count) / total * math.Log2(probability)
+	}
+	return entropy, len(contextCounts)
+}
+
+var matchBuckets = [][2]int{{3, 4}, {5, 8}, {9, 16}, {17, 32}, {33, 64}, {65, 128}, {129, math.MaxInt32}}
+
+// matchStats greedily parses content like an LZ77 coder, using a hash chain of the last few positions of every minMatch byte prefix.
+func matchStats(content []byte, window int, minMatch int) MatchStats {
+	const maxChain = 32
+	stats := MatchStats{}
+	for _, bucket := range matchBuckets {
+		if bucket[1] >= minMatch {
+			stats.Lengths = append(stats.Lengths, MatchBucket{MinLength: bucket[0], MaxLength: bucket[1]})
+		}
+	}
+	if minMatch < 1 || window < 1 || len(content) < minMatch {
+		return stats
+	}
+
+	positions := make(map[string][]int)
+	insert := func(i int) {
+		if i+minMatch > len(content) {
+			return
+		}
+		key := string(content[i : i+minMatch])
+		chain := append(positions[key], i)
+		if len(chain) > maxChain {
+			chain = chain[1:]
+		}
+		positions[key] = chain
+	}
+
+	var totalDistance int64
+	for i := 0; i < len(content); {
+		bestLength, bestDistance := 0, 0
+		if i+minMatch <= len(content) {
+			chain := positions[string(content[i:i+minMatch])]
+			for j := len(chain) - 1; j >= 0 && i-chain[j] <= window; j-- {
+				length := 0
+				for i+length < len(content) && content[chain[j]+length] == content[i+length] {
+					length++
+				}
+				if length > bestLength {
+					bestLength, bestDistance = length, i-chain[j]
+				}
+			}
+		}
+		if bestLength < minMatch {
+			insert(i)
+			i++
+			continue
+		}
+
+		stats.Matches++
+		stats.MatchedBytes += int64(bestLength)
+		totalDistance += int64(bestDistance)
+		if bestLength > stats.MaxLength {
+			stats.MaxLength = bestLength
+		}
+		for b := range stats.Lengths {
+			if bestLength >= stats.Lengths[b].MinLength && bestLength <= stats.Lengths[b].MaxLength {
+				stats.Lengths[b].Count++
+				break
+			}
+		}
+		for end := i + bestLength; i < end; i++ {
+			insert(i)
+		}
+	}
+
+	stats.MatchedFraction = float64(stats.MatchedBytes) / float64(len(content))
+	if stats.Matches > 0 {
+		stats.MeanLength = float64(stats.MatchedBytes) / float64(stats.Matches)
+		stats.MeanDistance = float64(totalDistance) / float64(stats.Matches)
+	}
+	return stats
+}
+
+// WriteAnalysis writes out an Analysis object in the given format, either "text" or "json".
+func WriteAnalysis(w io.Writer, analysis Analysis, format string) error {
+	switch format {
+	case "json":
+		encoded, err := json.MarshalIndent(analysis, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(encoded))
+		return err
+	case "text":
+		fmt.Fprintf(w, "File: %s\n", analysis.Path)
+		fmt.Fprintf(w, "Size: %s (%d bytes)\n", ByteCountSI(analysis.Size), analysis.Size)
+		fmt.Fprintf(w, "Distinct bytes: %d\n", analysis.Symbols)
+
+		fmt.Fprintf(w, "Entropy:\n")
+		for _, order := range analysis.Entropy {
+			fmt.Fprintf(w, "\torder %d: %.3f bits/byte, %d contexts, ideal size %s\n",
+				order.Order, order.BitsPerByte, order.Contexts, ByteCountSI(order.EstimatedBytes))
+		}
+
+		if len(analysis.Windows) > 0 {
+			min, max, mean := math.Inf(1), math.Inf(-1), 0.0
+			var sparkline strings.Builder
+			levels := []rune(" ▁▂▃▄▅▆▇█")
+			for _, window := range analysis.Windows {
+				min = math.Min(min, window.BitsPerByte)
+				max = math.Max(max, window.BitsPerByte)
+				mean += window.BitsPerByte / float64(len(analysis.Windows))
+				sparkline.WriteRune(levels[int(math.Round(window.BitsPerByte/8*float64(len(levels)-1)))])
+			}
+			fmt.Fprintf(w, "Windowed entropy: %d windows, min %.3f, mean %.3f, max %.3f bits/byte\n", len(analysis.Windows), min, mean, max)
+			fmt.Fprintf(w, "\t%s\n", sparkline.String())
+		}
+
+		fmt.Fprintf(w, "Most common bytes:\n")
+		symbols := make([]int, 0, analysis.Symbols)
+		for c, count := range analysis.Histogram {
+			if count > 0 {
+				symbols = append(symbols, c)
+			}
+		}
+		sort.SliceStable(symbols, func(i, j int) bool {
+			return analysis.Histogram[symbols[i]] > analysis.Histogram[symbols[j]]
+		})
+		for i := 0; i < len(symbols) && i < 10; i++ {
+			c := symbols[i]
+			fmt.Fprintf(w, "\t%-6s %d (%.2f%%)\n", byteLabel(byte(c)), analysis.Histogram[c], float64(analysis.Histogram[c])/float64(analysis.Size)*100)
+		}
+
+		matches := analysis.Matches
+		fmt.Fprintf(w, "Matches: %d covering %.2f%% of the file, mean length %.1f, max length %d, mean distance %.0f\n",
+			matches.Matches, matches.MatchedFraction*100, matches.MeanLength, matches.MaxLength, matches.MeanDistance)
+		for _, bucket := range matches.Lengths {
+			if bucket.MaxLength == math.MaxInt32 {
+				fmt.Fprintf(w, "\t%d+: %d\n", bucket.MinLength, bucket.Count)
+			} else {
+				fmt.Fprintf(w, "\t%d-%d: %d\n", bucket.MinLength, bucket.MaxLength, bucket.Count)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("raisin: unknown analysis format: %s", format)
+}
+
+func byteLabel(c byte) string {
+	if c >= 0x21 && c < 0x7f {
+		return fmt.Sprintf("'%c'", c)
+	}
+	return fmt.Sprintf("0x%02x", c)
+}
@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestAnalyzeEntropy(t *testing.T) {
+	content := bytes.Repeat([]byte("ab"), 1000)
+	analysis, err := Analyze(content, NewAnalyzeSettings())
+	if err != nil {
+		t.Fatalf("Analyze errored: %s", err)
+	}
+	if math.Abs(analysis.Entropy[0].BitsPerByte-1) > 1e-9 {
+		t.Errorf("Expected order 0 entropy of 1 bit, got %f", analysis.Entropy[0].BitsPerByte)
+	}
+	for _, order := range analysis.Entropy[1:] {
+		if order.BitsPerByte > 1e-9 {
+			t.Errorf("Expected order %d entropy of 0 bits, got %f", order.Order, order.BitsPerByte)
+		}
+	}
+	if analysis.Symbols != 2 || analysis.Histogram['a'] != 1000 {
+		t.Errorf("Got histogram with %d symbols and %d a's", analysis.Symbols, analysis.Histogram['a'])
+	}
+	if len(analysis.Windows) != 1 {
+		t.Errorf("Expected 1 window, got %d", len(analysis.Windows))
+	}
+}
+
+func TestAnalyzeMatches(t *testing.T) {
+	content := []byte("I AM SAM. I AM SAM. SAM I AM.")
+	stats := matchStats(content, 1024, 3)
+	// "I AM SAM. " repeats in full, then "SAM" and " I AM" are matched
+	if stats.Matches != 3 || stats.MaxLength != 10 || stats.MatchedBytes != 18 {
+		t.Errorf("Got %+v", stats)
+	}
+}
+
+func TestAnalyzeInvalidOrder(t *testing.T) {
+	settings := NewAnalyzeSettings()
+	settings.MaxOrder = MaxAnalyzeOrder + 1
+	if _, err := Analyze([]byte("abc"), settings); err == nil {
+		t.Errorf("Expected an error for an order above %d", MaxAnalyzeOrder)
+	}
+}
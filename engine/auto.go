@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AutoSettings represents an object that can be used to modify how ChooseAlgorithms searches for the best algorithm chain.
+// MaxDepth is the most layers a chain may have, SampleSize how many bytes of the input each chain is tried on,
+// and TimeBudget how long the whole search may take. Candidates are tried in order, so cheap algorithms should come first.
+type AutoSettings struct {
+	MaxDepth   int
+	SampleSize int
+	TimeBudget time.Duration
+	Candidates []string
+}
+
+// NewAutoSettings returns the default settings for ChooseAlgorithms as an AutoSettings object
+func NewAutoSettings() AutoSettings {
+	s := AutoSettings{}
+	s.MaxDepth = 2
+	s.SampleSize = 64 * 1024
+	s.TimeBudget = 10 * time.Second
+	s.Candidates = []string{"flate", "deflate", "zlib", "gzip", "lzw", "rlzw", "lz4", "bzip2", "huffman", "arithmetic", "ans", "lzss", "lzss-ans"}
+	return s
+}
+
+// IsAuto returns whether algorithms asks for the algorithm chain to be chosen automatically with ChooseAlgorithms.
+func IsAuto(algorithms []string) bool {
+	return len(algorithms) == 1 && algorithms[0] == "auto"
+}
+
+// Candidate represents a single algorithm chain tried by ChooseAlgorithms and its result on the sample.
+type Candidate struct {
+	Algorithms []string      `json:"algorithms"`
+	Size       int           `json:"size"`
+	Duration   time.Duration `json:"duration_ns"`
+	Lossless   bool          `json:"lossless"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// ErrNoCandidate is returned by ChooseAlgorithms when no chain round-tripped the sample within the time budget.
+var ErrNoCandidate = errors.New("raisin: no algorithm chain losslessly compressed the sample within the time budget")
+
+// ChooseAlgorithms tries every chain of up to settings.MaxDepth candidate algorithms on a sample of content
+// and returns the one with the smallest lossless output along with every candidate that was tried.
+// Chains are only extended if they are lossless and the search stops once ctx is done or the time budget runs out,
+// in which case the best chain so far is returned.
+func ChooseAlgorithms(ctx context.Context, content []byte, settings AutoSettings) ([]string, []Candidate, error) {
+	if settings.TimeBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, settings.TimeBudget)
+		defer cancel()
+	}
+	sample := autoSample(content, settings.SampleSize)
+
+	var candidates []Candidate
+	best := -1
+	// Breadth first so that every single algorithm is tried before any chain of two
+	type chain struct {
+		algorithms []string
+		compressed []byte
+	}
+	layer := []chain{{compressed: sample}}
+	for depth := 0; depth < settings.MaxDepth && len(layer) > 0; depth++ {
+		var next []chain
+		for _, prefix := range layer {
+			for _, algorithm := range settings.Candidates {
+				if ctx.Err() != nil {
+					return bestCandidate(candidates, best)
+				}
+				if len(prefix.algorithms) > 0 && prefix.algorithms[len(prefix.algorithms)-1] == algorithm {
+					continue
+				}
+				algorithms := append(append([]string{}, prefix.algorithms...), algorithm)
+				candidate, compressed := tryCandidate(ctx, sample, prefix.compressed, algorithms)
+				if ctx.Err() != nil {
+					// The candidate was cut short by the budget so its result can't be trusted
+					return bestCandidate(candidates, best)
+				}
+				candidates = append(candidates, candidate)
+				if !candidate.Lossless {
+					continue
+				}
+				if best < 0 || candidate.Size < candidates[best].Size {
+					best = len(candidates) - 1
+				}
+				next = append(next, chain{algorithms, compressed})
+			}
+		}
+		layer = next
+	}
+	return bestCandidate(candidates, best)
+}
+
+func bestCandidate(candidates []Candidate, best int) ([]string, []Candidate, error) {
+	if best < 0 {
+		return nil, candidates, ErrNoCandidate
+	}
+	return candidates[best].Algorithms, candidates, nil
+}
+
+// tryCandidate adds the last algorithm of algorithms on top of prefixCompressed, the sample compressed with the rest of the chain,
+// and checks that the whole chain decompresses back to the sample.
+func tryCandidate(ctx context.Context, sample []byte, prefixCompressed []byte, algorithms []string) (candidate Candidate, compressed []byte) {
+	candidate.Algorithms = algorithms
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			candidate.Error = fmt.Sprint(r)
+			candidate.Lossless = false
+			compressed = nil
+		}
+		candidate.Duration = time.Since(start)
+	}()
+
+	compressed, err := compressContext(ctx, prefixCompressed, algorithms[len(algorithms)-1:])
+	if err != nil {
+		candidate.Error = err.Error()
+		return candidate, nil
+	}
+	candidate.Size = len(compressed)
+	decompressed, err := decompressContext(ctx, compressed, algorithms)
+	if err != nil {
+		candidate.Error = err.Error()
+		return candidate, nil
+	}
+	candidate.Lossless = bytes.Equal(decompressed, sample)
+	return candidate, compressed
+}
+
+// verifyChoice round-trips the lossless candidates on the whole of content with dict, smallest first, and returns the first chain
+// that decompresses back to it. A chain that was lossless on the sample can still mangle bytes that are only in the rest of content.
+func verifyChoice(ctx context.Context, content []byte, candidates []Candidate, dict []byte) ([]string, error) {
+	var lossless []Candidate
+	for _, candidate := range candidates {
+		if candidate.Lossless {
+			lossless = append(lossless, candidate)
+		}
+	}
+	sort.SliceStable(lossless, func(i, j int) bool { return lossless[i].Size < lossless[j].Size })
+	for _, candidate := range lossless {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if roundTrips(ctx, content, candidate.Algorithms, dict) {
+			return candidate.Algorithms, nil
+		}
+	}
+	return nil, ErrNoCandidate
+}
+
+// roundTrips returns whether content compressed with algorithms and dict decompresses back to content.
+func roundTrips(ctx context.Context, content []byte, algorithms []string, dict []byte) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	compressed, err := compressDict(ctx, content, algorithms, dict)
+	if err != nil {
+		return false
+	}
+	decompressed, err := decompressDict(ctx, compressed, algorithms, 0, dict)
+	return err == nil && bytes.Equal(decompressed, content)
+}
+
+// autoSample returns up to size bytes of content, taken as evenly spaced chunks so that the whole input is represented.
+func autoSample(content []byte, size int) []byte {
+	if size <= 0 || len(content) <= size {
+		return content
+	}
+	const chunks = 4
+	chunkSize := size / chunks
+	if chunkSize == 0 {
+		// Too small to split into chunks
+		return content[:size]
+	}
+	stride := (len(content) - chunkSize) / (chunks - 1)
+	sample := make([]byte, 0, chunkSize*chunks)
+	for i := 0; i < chunks; i++ {
+		sample = append(sample, content[i*stride:i*stride+chunkSize]...)
+	}
+	return sample
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestChooseAlgorithms(t *testing.T) {
+	content := bytes.Repeat([]byte("I AM SAM. I AM SAM. SAM I AM. "), 200)
+	settings := NewAutoSettings()
+	settings.Candidates = []string{"huffman", "flate"}
+
+	chosen, candidates, err := ChooseAlgorithms(context.Background(), content, settings)
+	if err != nil {
+		t.Fatalf("ChooseAlgorithms errored: %s", err)
+	}
+	if len(candidates) != 4 {
+		t.Errorf("Expected 4 candidates with 2 algorithms and a depth of 2, got %d", len(candidates))
+	}
+	for _, candidate := range candidates {
+		if candidate.Lossless && candidate.Size < len(compress(content, chosen)) {
+			t.Errorf("Chose %v but %v is smaller", chosen, candidate.Algorithms)
+		}
+	}
+	if unpacked, _, err := Unpack(Pack(content, chosen, ChecksumCRC32), nil); err != nil || !reflect.DeepEqual(unpacked, content) {
+		t.Errorf("Chosen chain %v is not lossless", chosen)
+	}
+}
+
+func TestChooseAlgorithmsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := ChooseAlgorithms(ctx, []byte("abc"), NewAutoSettings()); err != ErrNoCandidate {
+		t.Errorf("Expected ErrNoCandidate, got %v", err)
+	}
+}
+
+func TestVerifyChoice(t *testing.T) {
+	content := bytes.Repeat([]byte("I AM SAM. I AM SAM. SAM I AM. "), 20)
+	// dmc can't decompress yet, so it has to be passed over even though it looked smallest
+	candidates := []Candidate{
+		{Algorithms: []string{"flate"}, Size: 50, Lossless: true},
+		{Algorithms: []string{"dmc"}, Size: 10, Lossless: true},
+		{Algorithms: []string{"lzss"}, Size: 5, Lossless: false},
+	}
+	chosen, err := verifyChoice(context.Background(), content, candidates, nil)
+	if err != nil || !reflect.DeepEqual(chosen, []string{"flate"}) {
+		t.Errorf("Expected to fall back to flate, got %v (%v)", chosen, err)
+	}
+	if _, err := verifyChoice(context.Background(), content, candidates[1:], nil); err != ErrNoCandidate {
+		t.Errorf("Expected ErrNoCandidate without a chain that round-trips, got %v", err)
+	}
+}
+
+func TestAutoSample(t *testing.T) {
+	content := make([]byte, 1000)
+	for i := range content {
+		content[i] = byte(i / 100)
+	}
+	sample := autoSample(content, 100)
+	if len(sample) != 100 || sample[0] != 0 || sample[99] != 9 {
+		t.Errorf("Expected the sample to span the whole input, got %v", sample)
+	}
+	if len(autoSample(content, 0)) != len(content) {
+		t.Errorf("Expected a sample size of 0 to use the whole input")
+	}
+	for size := 1; size < 4; size++ {
+		if len(autoSample(content, size)) != size {
+			t.Errorf("Expected a sample of %d bytes, got %d", size, len(autoSample(content, size)))
+		}
+	}
+}
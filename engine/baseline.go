@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Comparison represents a benchmark result compared against the same file and algorithm in a baseline.
+// RatioDelta is the change in compression ratio in percentage points, a positive delta means larger output.
+// The speed deltas are the relative change in throughput in percent, a negative delta means slower.
+type Comparison struct {
+	File                 string   `json:"file"`
+	Engine               string   `json:"engine"`
+	BaselineRatio        float32  `json:"baseline_ratio"`
+	Ratio                float32  `json:"ratio"`
+	RatioDelta           float32  `json:"ratio_delta"`
+	CompressSpeedDelta   float64  `json:"compress_speed_delta"`
+	DecompressSpeedDelta float64  `json:"decompress_speed_delta"`
+	BaselineLossless     bool     `json:"baseline_lossless"`
+	Lossless             bool     `json:"lossless"`
+	Status               string   `json:"status"`
+	Regressions          []string `json:"regressions,omitempty"`
+}
+
+// SaveBaseline writes benchmark results to path as json so they can be compared against with LoadBaseline and CompareResults.
+func SaveBaseline(path string, results []Result) error {
+	encoded, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, encoded, 0644)
+}
+
+// LoadBaseline reads benchmark results written by SaveBaseline or by the json benchmark format.
+func LoadBaseline(path string) ([]Result, error) {
+	encoded, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	if err := json.Unmarshal(encoded, &results); err != nil {
+		return nil, fmt.Errorf("raisin: invalid baseline %s: %s", path, err)
+	}
+	return results, nil
+}
+
+// CompareResults matches results to the baseline by file and algorithm and flags regressions.
+// A result regresses when it stops being lossless, fails where the baseline didn't,
+// or when its compression ratio or either speed is more than threshold percent worse than the baseline.
+// Results missing from either side are reported with the status "new" or "missing" and never count as regressions.
+func CompareResults(baseline []Result, results []Result, threshold float64) []Comparison {
+	key := func(result Result) string {
+		return result.File + "\x00" + result.CompressionEngine
+	}
+	baselineResults := make(map[string]Result, len(baseline))
+	for _, result := range baseline {
+		baselineResults[key(result)] = result
+	}
+
+	comparisons := make([]Comparison, 0, len(results))
+	seen := make(map[string]bool, len(results))
+	for _, result := range results {
+		seen[key(result)] = true
+		comparison := Comparison{File: result.File, Engine: result.CompressionEngine, Ratio: result.Ratio, Lossless: result.Lossless}
+		old, ok := baselineResults[key(result)]
+		if !ok {
+			comparison.Status = "new"
+			comparisons = append(comparisons, comparison)
+			continue
+		}
+		comparison.BaselineRatio = old.Ratio
+		comparison.BaselineLossless = old.Lossless
+
+		if old.Lossless && !result.Lossless {
+			comparison.Regressions = append(comparison.Regressions, "lossless")
+		}
+		if result.Failed {
+			if !old.Failed {
+				comparison.Regressions = append(comparison.Regressions, "failed")
+			}
+		} else if !old.Failed {
+			comparison.RatioDelta = result.Ratio - old.Ratio
+			comparison.CompressSpeedDelta = percentChange(old.CompressSpeed, result.CompressSpeed)
+			comparison.DecompressSpeedDelta = percentChange(old.DecompressSpeed, result.DecompressSpeed)
+			if old.Ratio > 0 && float64(comparison.RatioDelta/old.Ratio*100) > threshold {
+				comparison.Regressions = append(comparison.Regressions, "ratio")
+			}
+			if comparison.CompressSpeedDelta < -threshold {
+				comparison.Regressions = append(comparison.Regressions, "compress speed")
+			}
+			if comparison.DecompressSpeedDelta < -threshold {
+				comparison.Regressions = append(comparison.Regressions, "decompress speed")
+			}
+		}
+
+		if len(comparison.Regressions) > 0 {
+			comparison.Status = "regressed"
+		} else {
+			comparison.Status = "ok"
+		}
+		comparisons = append(comparisons, comparison)
+	}
+
+	for _, result := range baseline {
+		if !seen[key(result)] {
+			comparisons = append(comparisons, Comparison{File: result.File, Engine: result.CompressionEngine, BaselineRatio: result.Ratio, BaselineLossless: result.Lossless, Status: "missing"})
+		}
+	}
+	return comparisons
+}
+
+// Regressed returns whether any of the comparisons is a regression.
+func Regressed(comparisons []Comparison) bool {
+	for _, comparison := range comparisons {
+		if len(comparison.Regressions) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteComparison writes comparisons to w as a table.
+func WriteComparison(w io.Writer, comparisons []Comparison) {
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.SetStyle(table.StyleLight)
+	t.AppendHeader(table.Row{"file", "engine", "ratio (baseline)", "ratio", "ratio delta", "compress speed", "decompress speed", "lossless", "status"})
+	for _, c := range comparisons {
+		status := c.Status
+		if len(c.Regressions) > 0 {
+			status = fmt.Sprintf("%s (%s)", c.Status, strings.Join(c.Regressions, ", "))
+		}
+		switch c.Status {
+		case "new":
+			t.AppendRow(table.Row{c.File, c.Engine, "-", fmt.Sprintf("%.2f%%", c.Ratio), "-", "-", "-", c.Lossless, status})
+		case "missing":
+			t.AppendRow(table.Row{c.File, c.Engine, fmt.Sprintf("%.2f%%", c.BaselineRatio), "-", "-", "-", "-", c.BaselineLossless, status})
+		default:
+			t.AppendRow(table.Row{c.File, c.Engine, fmt.Sprintf("%.2f%%", c.BaselineRatio), fmt.Sprintf("%.2f%%", c.Ratio),
+				fmt.Sprintf("%+.2f", c.RatioDelta), fmt.Sprintf("%+.1f%%", c.CompressSpeedDelta), fmt.Sprintf("%+.1f%%", c.DecompressSpeedDelta),
+				c.Lossless, status})
+		}
+	}
+	t.Render()
+}
+
+func percentChange(old float64, new float64) float64 {
+	if old <= 0 {
+		return 0
+	}
+	return (new - old) / old * 100
+}
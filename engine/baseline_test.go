@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCompareResults(t *testing.T) {
+	baseline := []Result{
+		{CompressionEngine: "flate", File: "test.txt", Ratio: 50, Lossless: true, CompressSpeed: 10, DecompressSpeed: 10},
+		{CompressionEngine: "huffman", File: "test.txt", Ratio: 60, Lossless: true, CompressSpeed: 10, DecompressSpeed: 10},
+		{CompressionEngine: "lzss", File: "test.txt", Ratio: 70, Lossless: true, CompressSpeed: 10, DecompressSpeed: 10},
+		{CompressionEngine: "gzip", File: "test.txt", Ratio: 55, Lossless: true},
+	}
+	results := []Result{
+		{CompressionEngine: "flate", File: "test.txt", Ratio: 51, Lossless: true, CompressSpeed: 9.5, DecompressSpeed: 12},
+		{CompressionEngine: "huffman", File: "test.txt", Ratio: 70, Lossless: true, CompressSpeed: 5, DecompressSpeed: 10},
+		{CompressionEngine: "lzss", File: "test.txt", Ratio: 70, Lossless: false, CompressSpeed: 10, DecompressSpeed: 10},
+		{CompressionEngine: "dmc", File: "test.txt", Ratio: 40},
+	}
+
+	comparisons := CompareResults(baseline, results, 10)
+	statuses := make(map[string][]string)
+	for _, c := range comparisons {
+		statuses[c.Engine] = append([]string{c.Status}, c.Regressions...)
+	}
+	expected := map[string][]string{
+		"flate":   {"ok"},
+		"huffman": {"regressed", "ratio", "compress speed"},
+		"lzss":    {"regressed", "lossless"},
+		"dmc":     {"new"},
+		"gzip":    {"missing"},
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Expected %v, got %v", expected, statuses)
+	}
+	if !Regressed(comparisons) || Regressed(comparisons[:1]) {
+		t.Errorf("Regressed didn't match the comparison statuses")
+	}
+}
+
+func TestSaveLoadBaseline(t *testing.T) {
+	file, err := ioutil.TempFile("", "raisin-baseline-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	if err := SaveBaseline(file.Name(), reportResults); err != nil {
+		t.Fatalf("SaveBaseline errored: %s", err)
+	}
+	loaded, err := LoadBaseline(file.Name())
+	if err != nil {
+		t.Fatalf("LoadBaseline errored: %s", err)
+	}
+	if !reflect.DeepEqual(loaded, reportResults) {
+		t.Errorf("Expected %+v, got %+v", reportResults, loaded)
+	}
+}
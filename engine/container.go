@@ -0,0 +1,274 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	huffman "github.com/go-compression/raisin/compressor/huffman"
+	xxhash "github.com/go-compression/raisin/compressor/xxhash"
+	"hash/crc32"
+	"strings"
+)
+
+// Magic is the byte sequence every raisin container starts with.
+var Magic = []byte{'R', 'S', 'N', 0x1a}
+
+// ContainerVersion is the version of the container format written by Pack.
+// Version 1 containers have no algorithm chain and are still readable. Version 3 adds the ID of a preset dictionary,
+// containers compressed without one are still written as version 2 so older versions of raisin can read them.
+const ContainerVersion = 3
+
+// ChecksumType represents the checksum algorithm stored in a container header.
+type ChecksumType byte
+
+const (
+	// ChecksumNone stores no checksum, decompressed data is not verified.
+	ChecksumNone ChecksumType = iota
+	// ChecksumCRC32 stores the IEEE CRC32 of the original data.
+	ChecksumCRC32
+	// ChecksumXXHash stores the 64-bit xxHash of the original data.
+	ChecksumXXHash
+)
+
+// Checksums is a map of checksum names usable from the CLI to their ChecksumType.
+var Checksums = map[string]ChecksumType{"none": ChecksumNone, "crc32": ChecksumCRC32, "xxhash": ChecksumXXHash}
+
+func (c ChecksumType) String() string {
+	for name, checksum := range Checksums {
+		if checksum == c {
+			return name
+		}
+	}
+	return fmt.Sprintf("unknown(%d)", byte(c))
+}
+
+func (c ChecksumType) size() int {
+	switch c {
+	case ChecksumCRC32:
+		return 4
+	case ChecksumXXHash:
+		return 8
+	}
+	return 0
+}
+
+func (c ChecksumType) sum(data []byte) uint64 {
+	switch c {
+	case ChecksumCRC32:
+		return uint64(crc32.ChecksumIEEE(data))
+	case ChecksumXXHash:
+		return xxhash.Sum64(data)
+	}
+	return 0
+}
+
+// ErrChecksumMismatch is returned when decompressed data does not match the checksum stored in its container.
+var ErrChecksumMismatch = errors.New("raisin: checksum mismatch, compressed data is corrupt")
+
+// ErrNoContainer is returned when data does not start with the container magic bytes.
+var ErrNoContainer = errors.New("raisin: data is not a raisin container")
+
+// Header represents the metadata stored in front of the compressed payload of a container.
+type Header struct {
+	Version      int
+	Checksum     ChecksumType
+	Sum          uint64
+	OriginalSize int64
+	Algorithms   []string
+	// DictionaryID is the DictionaryID of the preset dictionary the payload was compressed with, 0 if there wasn't one
+	DictionaryID uint32
+}
+
+// Pack compresses content with the given algorithms and wraps the result in a container recording the checksum of the original content.
+func Pack(content []byte, algorithms []string, checksum ChecksumType) []byte {
+	return PackDict(content, algorithms, checksum, nil)
+}
+
+// PackDict is like Pack but compresses with a preset dictionary, which the algorithms in DictionaryAlgorithms use as history to compress small
+// contents better. Only the dictionary's ID is stored, the same dictionary has to be given to UnpackDict.
+func PackDict(content []byte, algorithms []string, checksum ChecksumType, dict []byte) []byte {
+	header := Header{Version: 2, Checksum: checksum, OriginalSize: int64(len(content)), Algorithms: algorithms}
+	if len(dict) > 0 {
+		header.Version = ContainerVersion
+		header.DictionaryID = DictionaryID(dict)
+	}
+	header.Sum = checksum.sum(content)
+	compressed, err := compressDict(context.Background(), content, algorithms, dict)
+	check(err)
+	return append(header.encode(), compressed...)
+}
+
+// Unpack reads a container, decompresses its payload and verifies the stored checksum.
+// The algorithm chain recorded in the container takes precedence over the given algorithms, which are only used for containers that don't record one.
+// Data without a container header is decompressed as-is and cannot be verified.
+func Unpack(container []byte, algorithms []string) ([]byte, Header, error) {
+	return UnpackLimit(container, algorithms, 0)
+}
+
+// UnpackLimit is like Unpack but fails with an error wrapping ErrTooLarge rather than decompressing any layer to more than limit bytes.
+// Containers recording an original size over the limit are rejected before anything is decompressed, a limit of 0 means no limit.
+func UnpackLimit(container []byte, algorithms []string, limit int) ([]byte, Header, error) {
+	return UnpackDict(container, algorithms, limit, nil)
+}
+
+// UnpackDict is like UnpackLimit but takes the preset dictionaries a container may have been compressed with.
+// The one whose ID the container records is used, and an error wrapping ErrMissingDictionary is returned if none of them match.
+// Data without a container header is decompressed with the first dictionary, if any.
+func UnpackDict(container []byte, algorithms []string, limit int, dictionaries [][]byte) ([]byte, Header, error) {
+	header, payload, err := ReadHeader(container)
+	if err == ErrNoContainer {
+		var dict []byte
+		if len(dictionaries) > 0 {
+			dict = dictionaries[0]
+		}
+		decompressed, err := safeDecompress(container, algorithms, limit, dict, true)
+		return decompressed, Header{}, err
+	} else if err != nil {
+		return nil, header, err
+	}
+	var dict []byte
+	if header.DictionaryID != 0 {
+		if dict, err = findDictionary(header.DictionaryID, dictionaries); err != nil {
+			return nil, header, err
+		}
+	}
+	if limit > 0 && header.OriginalSize > int64(limit) {
+		return nil, header, fmt.Errorf("raisin: container records %d bytes, over the %d byte limit: %w", header.OriginalSize, limit, ErrTooLarge)
+	}
+	if len(header.Algorithms) > 0 {
+		algorithms = header.Algorithms
+	}
+
+	decompressed, err := safeDecompress(payload, algorithms, limit, dict, header.Version == 1)
+	if err != nil {
+		return nil, header, err
+	}
+	if err := header.Verify(decompressed); err != nil {
+		return decompressed, header, err
+	}
+	return decompressed, header, nil
+}
+
+// Verify checks the decompressed content against the size and checksum recorded in the header.
+// The size is always checked, containers written with ChecksumNone only skip the checksum.
+func (h Header) Verify(decompressed []byte) error {
+	if int64(len(decompressed)) != h.OriginalSize {
+		return fmt.Errorf("raisin: size mismatch, expected %d bytes but got %d", h.OriginalSize, len(decompressed))
+	}
+	if h.Checksum == ChecksumNone {
+		return nil
+	}
+	if h.Checksum.sum(decompressed) != h.Sum {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// ReadHeader parses the container header at the start of data and returns it along with the remaining payload.
+func ReadHeader(data []byte) (Header, []byte, error) {
+	var header Header
+	if !bytes.HasPrefix(data, Magic) {
+		return header, data, ErrNoContainer
+	}
+	data = data[len(Magic):]
+	if len(data) < 2 {
+		return header, nil, errors.New("raisin: truncated container header")
+	}
+	header.Version = int(data[0])
+	header.Checksum = ChecksumType(data[1])
+	data = data[2:]
+	if header.Version < 1 || header.Version > ContainerVersion {
+		return header, nil, fmt.Errorf("raisin: unsupported container version: %d", header.Version)
+	}
+	if header.Checksum > ChecksumXXHash {
+		return header, nil, fmt.Errorf("raisin: unknown checksum type: %d", header.Checksum)
+	}
+
+	size, n := binary.Uvarint(data)
+	if n <= 0 {
+		return header, nil, errors.New("raisin: invalid original size in container header")
+	}
+	header.OriginalSize = int64(size)
+	data = data[n:]
+
+	sumSize := header.Checksum.size()
+	if len(data) < sumSize {
+		return header, nil, errors.New("raisin: truncated container checksum")
+	}
+	for i := sumSize - 1; i >= 0; i-- {
+		header.Sum = header.Sum<<8 | uint64(data[i])
+	}
+	data = data[sumSize:]
+
+	if header.Version >= 2 {
+		if len(data) < 1 {
+			return header, nil, errors.New("raisin: truncated algorithm chain")
+		}
+		layers := int(data[0])
+		data = data[1:]
+		for i := 0; i < layers; i++ {
+			if len(data) < 1 || len(data) < 1+int(data[0]) {
+				return header, nil, errors.New("raisin: truncated algorithm chain")
+			}
+			header.Algorithms = append(header.Algorithms, string(data[1:1+int(data[0])]))
+			data = data[1+int(data[0]):]
+		}
+	}
+	if header.Version >= 3 {
+		id, n := binary.Uvarint(data)
+		if n <= 0 || id > 0xffffffff {
+			return header, nil, errors.New("raisin: invalid dictionary ID in container header")
+		}
+		header.DictionaryID = uint32(id)
+		data = data[n:]
+	}
+	return header, data, nil
+}
+
+func (h Header) encode() []byte {
+	encoded := append([]byte{}, Magic...)
+	encoded = append(encoded, byte(h.Version), byte(h.Checksum))
+
+	size := make([]byte, binary.MaxVarintLen64)
+	encoded = append(encoded, size[:binary.PutUvarint(size, uint64(h.OriginalSize))]...)
+
+	for i := 0; i < h.Checksum.size(); i++ {
+		encoded = append(encoded, byte(h.Sum>>(8*uint(i))))
+	}
+
+	encoded = append(encoded, byte(len(h.Algorithms)))
+	for _, algorithm := range h.Algorithms {
+		encoded = append(encoded, byte(len(algorithm)))
+		encoded = append(encoded, algorithm...)
+	}
+	if h.Version >= 3 {
+		encoded = append(encoded, size[:binary.PutUvarint(size, uint64(h.DictionaryID))]...)
+	}
+	return encoded
+}
+
+// safeDecompress decompresses content with a size limit and converts any panic raised by a decoder into an error.
+// legacy is set for headerless data and version 1 containers, whose huffman layers code UTF-8 runes rather than bytes.
+func safeDecompress(content []byte, algorithms []string, limit int, dict []byte, legacy bool) (decompressed []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("raisin: %s failed to decompress: %v", strings.Join(algorithms, ","), r)
+		}
+	}()
+	if !legacy {
+		return decompressDict(context.Background(), content, algorithms, limit, dict)
+	}
+	for i := len(algorithms) - 1; i >= 0; i-- {
+		if algorithms[i] != "huffman" {
+			content, err = decompressDict(context.Background(), content, algorithms[i:i+1], limit, dict)
+		} else if content, err = huffman.DecompressRunes(content, limit); err != nil {
+			err = fmt.Errorf("raisin: %s failed to decompress: %w", algorithms[i], err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestPackUnpack(t *testing.T) {
+	contents := []byte("I AM SAM. I AM SAM. SAM I AM.")
+	for name, checksum := range Checksums {
+		packed := Pack(contents, []string{"huffman", "flate"}, checksum)
+		unpacked, header, err := Unpack(packed, []string{"huffman", "flate"})
+		if err != nil {
+			t.Fatalf("Unpack with %s checksum errored: %s", name, err)
+		}
+		if header.Checksum != checksum || header.OriginalSize != int64(len(contents)) {
+			t.Errorf("Got header %+v for %s checksum", header, name)
+		}
+		if !reflect.DeepEqual(unpacked, contents) {
+			t.Errorf("Unpack with %s checksum was not lossless", name)
+		}
+	}
+}
+
+func TestUnpackCorrupt(t *testing.T) {
+	contents := []byte("I AM SAM. I AM SAM. SAM I AM.")
+	packed := Pack(contents, []string{"lzss"}, ChecksumXXHash)
+	packed[len(packed)-1] ^= 0xff
+
+	if _, _, err := Unpack(packed, []string{"lzss"}); err == nil {
+		t.Errorf("Expected corrupted container to fail verification")
+	}
+}
+
+func TestUnpackWithoutContainer(t *testing.T) {
+	contents := []byte("I AM SAM. I AM SAM. SAM I AM.")
+	unpacked, header, err := Unpack(compress(contents, []string{"flate"}), []string{"flate"})
+	if err != nil {
+		t.Fatalf("Unpack of raw data errored: %s", err)
+	}
+	if header.Checksum != ChecksumNone || !reflect.DeepEqual(unpacked, contents) {
+		t.Errorf("Expected raw data to decompress without verification")
+	}
+}
+
+func TestUnpackSizeMismatch(t *testing.T) {
+	contents := []byte("I AM SAM. I AM SAM. SAM I AM.")
+	packed := Pack(contents, []string{"flate"}, ChecksumNone)
+	header, payload, err := ReadHeader(packed)
+	if err != nil {
+		t.Fatalf("ReadHeader errored: %s", err)
+	}
+	// Containers without a checksum still record the size, which catches truncated or garbled payloads
+	header.OriginalSize++
+	if _, _, err := Unpack(append(header.encode(), payload...), []string{"flate"}); err == nil {
+		t.Errorf("Expected a container without a checksum to fail on a size mismatch")
+	}
+}
+
+func TestUnpackRuneHuffman(t *testing.T) {
+	// Written by an older version of raisin, whose huffman coded UTF-8 runes rather than bytes
+	compressed, err := ioutil.ReadFile("testdata/runes.huffman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := []byte("I AM SAM. Ich bin Sam. Je suis Sam, ça va ? ☕\n")
+	unpacked, _, err := Unpack(compressed, []string{"huffman"})
+	if err != nil || !reflect.DeepEqual(unpacked, contents) {
+		t.Errorf("Headerless huffman data was not decompressed as runes: %q, %v", unpacked, err)
+	}
+	header := Header{Version: 1, Checksum: ChecksumCRC32, OriginalSize: int64(len(contents)), Sum: ChecksumCRC32.sum(contents)}
+	unpacked, _, err = Unpack(append(header.encode(), compressed...), []string{"huffman"})
+	if err != nil || !reflect.DeepEqual(unpacked, contents) {
+		t.Errorf("Version 1 container was not decompressed as runes: %q, %v", unpacked, err)
+	}
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	xxhash "github.com/go-compression/raisin/compressor/xxhash"
+	"io/ioutil"
+)
+
+// DictionaryAlgorithms are the algorithms that make use of the preset dictionary of a CompressedFile, any other algorithm ignores it.
+var DictionaryAlgorithms = []string{"arithmetic", "flate", "lzss", "zlib"}
+
+// ErrMissingDictionary is returned when unpacking a container compressed with a dictionary that wasn't supplied.
+var ErrMissingDictionary = errors.New("raisin: container was compressed with a dictionary that wasn't supplied")
+
+// SupportsDictionary returns whether algorithm makes use of a preset dictionary.
+func SupportsDictionary(algorithm string) bool {
+	for _, a := range DictionaryAlgorithms {
+		if a == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// DictionaryID returns the ID a container stores to record which dictionary it was compressed with, taken from the dictionary's xxHash.
+// IDs are never 0, which containers use to mean there's no dictionary.
+func DictionaryID(dict []byte) uint32 {
+	id := uint32(xxhash.Sum64(dict))
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
+
+// findDictionary returns the dictionary with the given ID out of dictionaries.
+func findDictionary(id uint32, dictionaries [][]byte) ([]byte, error) {
+	for _, dict := range dictionaries {
+		if DictionaryID(dict) == id {
+			return dict, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %08x", ErrMissingDictionary, id)
+}
+
+// LoadDictionaries reads every dictionary file in paths.
+func LoadDictionaries(paths []string) ([][]byte, error) {
+	var dictionaries [][]byte
+	for _, path := range paths {
+		dict, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		dictionaries = append(dictionaries, dict)
+	}
+	return dictionaries, nil
+}
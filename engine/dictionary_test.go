@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+var testDictionary = []byte(`{"user_id": 1, "name": "user1", "email": "user1@example.com", "active": true, "roles": ["reader", "writer"]}
+{"user_id": 2, "name": "user2", "email": "user2@example.com", "active": false, "roles": ["reader"]}`)
+
+var testMessage = []byte(`{"user_id": 999, "name": "user999", "email": "user999@example.com", "active": true, "roles": ["writer"]}`)
+
+func TestPackUnpackDict(t *testing.T) {
+	for _, algorithm := range DictionaryAlgorithms {
+		algorithms := []string{algorithm}
+		packed := PackDict(testMessage, algorithms, ChecksumCRC32, testDictionary)
+		plain := Pack(testMessage, algorithms, ChecksumCRC32)
+		if len(packed) >= len(plain) {
+			t.Errorf("%s with a dictionary took %d bytes, without one %d", algorithm, len(packed), len(plain))
+		}
+
+		other := []byte("another dictionary")
+		unpacked, header, err := UnpackDict(packed, algorithms, 0, [][]byte{other, testDictionary})
+		if err != nil {
+			t.Fatalf("%s UnpackDict errored: %s", algorithm, err)
+		}
+		if header.Version != ContainerVersion || header.DictionaryID != DictionaryID(testDictionary) {
+			t.Errorf("%s got header %+v", algorithm, header)
+		}
+		if !bytes.Equal(unpacked, testMessage) {
+			t.Errorf("%s with a dictionary was not lossless", algorithm)
+		}
+
+		if _, _, err := UnpackDict(packed, algorithms, 0, [][]byte{other}); !errors.Is(err, ErrMissingDictionary) {
+			t.Errorf("%s without its dictionary got error %v, expected ErrMissingDictionary", algorithm, err)
+		}
+	}
+}
+
+func TestDictContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, algorithm := range DictionaryAlgorithms {
+		// A dictionary mustn't take precedence over cancellation
+		file := CompressedFile{CompressionEngine: algorithm, Context: ctx, Dictionary: testDictionary}
+		if _, err := file.Write(testMessage); !errors.Is(err, context.Canceled) {
+			t.Errorf("[%s] Expected context.Canceled compressing with a dictionary, got %v", algorithm, err)
+		}
+		compressed, err := compressDict(context.Background(), testMessage, []string{algorithm}, testDictionary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decompressLayer(ctx, compressed, algorithm, 0, testDictionary); !errors.Is(err, context.Canceled) {
+			t.Errorf("[%s] Expected context.Canceled decompressing with a dictionary, got %v", algorithm, err)
+		}
+	}
+}
+
+func TestPackWithoutDict(t *testing.T) {
+	packed := PackDict(testMessage, []string{"lzss"}, ChecksumCRC32, nil)
+	header, _, err := ReadHeader(packed)
+	if err != nil {
+		t.Fatalf("ReadHeader errored: %s", err)
+	}
+	// Containers without a dictionary stay readable by versions that predate dictionaries
+	if header.Version != 2 || header.DictionaryID != 0 {
+		t.Errorf("Got header %+v for a container without a dictionary", header)
+	}
+	unpacked, _, err := UnpackDict(packed, []string{"lzss"}, 0, [][]byte{testDictionary})
+	if err != nil || !bytes.Equal(unpacked, testMessage) {
+		t.Errorf("Unpacking without a dictionary failed: %v", err)
+	}
+}
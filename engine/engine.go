@@ -6,12 +6,22 @@ import (
 	gzip "compress/gzip"
 	lzw "compress/lzw"
 	zlib "compress/zlib"
+	"context"
+	"errors"
 	"fmt"
+	ans "github.com/go-compression/raisin/compressor/ans"
 	arithmetic "github.com/go-compression/raisin/compressor/arithmetic"
+	bzip2 "github.com/go-compression/raisin/compressor/bzip2"
+	codec "github.com/go-compression/raisin/compressor/codec"
+	deflate "github.com/go-compression/raisin/compressor/deflate"
 	dmc "github.com/go-compression/raisin/compressor/dmc"
 	huffman "github.com/go-compression/raisin/compressor/huffman"
 	lz "github.com/go-compression/raisin/compressor/lz"
+	lz4 "github.com/go-compression/raisin/compressor/lz4"
+	rlzw "github.com/go-compression/raisin/compressor/lzw"
 	mcc "github.com/go-compression/raisin/compressor/mcc"
+	zstd "github.com/go-compression/raisin/compressor/zstd"
+	templates "github.com/go-compression/raisin/templates"
 	"github.com/jedib0t/go-pretty/v6/table"
 	ent "github.com/kzahedi/goent/discrete"
 	"html/template"
@@ -30,10 +40,10 @@ import (
 )
 
 // Engines is a slice of strings representing possible algorithms.
-var Engines = [...]string{"all", "suite", "lzss", "dmc", "huffman", "mcc", "flate", "gzip", "lzw", "zlib", "arithmetic"}
+var Engines = [...]string{"all", "suite", "auto", "lzss", "lz77", "lz78", "dmc", "huffman", "mcc", "flate", "deflate", "gzip", "lzw", "rlzw", "lz4", "bzip2", "zstd", "ans", "lzss-ans", "zlib", "arithmetic"}
 
 // Suites is a map of strings to strings representing a suite name and the contained algorithms.
-var Suites = map[string][]string{"all": Engines[2:], "suite": {"lzss", "dmc", "huffman", "mcc", "flate", "gzip", "lzw", "zlib", "arithmetic"}}
+var Suites = map[string][]string{"all": Engines[3:], "suite": {"lzss", "lz77", "lz78", "dmc", "huffman", "mcc", "flate", "deflate", "gzip", "lzw", "rlzw", "lz4", "bzip2", "ans", "lzss-ans", "zlib", "arithmetic"}}
 
 // CompressedFile is a struct used to read a compressed file or write to a compressed file.
 type CompressedFile struct {
@@ -42,44 +52,131 @@ type CompressedFile struct {
 	Decompressed          []byte
 	pos                   int
 	MaxSearchBufferLength int
+	// Context is passed to the algorithms in Writers and Readers so that long running compression and decompression can be aborted
+	Context context.Context
+	// MaxDecompressedSize is the most bytes Read will decompress before failing with ErrTooLarge, 0 means no limit
+	MaxDecompressedSize int
+	// Dictionary is a preset dictionary passed to the algorithms in DictionaryAlgorithms, the same one has to be used to decompress
+	Dictionary []byte
 }
 
-// Readers represents a map of algorithm names to their NewReader interfaces.
-var Readers = map[string]interface{}{
-	"lzss":       lz.NewReader,
-	"dmc":        dmc.NewReader,
-	"mcc":        mcc.NewReader,
-	"huffman":    huffman.NewReader,
-	"arithmetic": arithmetic.NewReader,
-	"zlib":       zlib.NewReader,
-	"flate":      flate.NewReader,
-	"gzip":       gzip.NewReader,
-	"lzw":        lzw.NewReader,
+// Readers represents a map of algorithm names to their NewReader functions. They decompress with the preset dictionary in opts
+// when they support one and fail once their output exceeds opts.Limit, algorithms that can't stop part way through decompressing
+// are stopped between reads by CompressedFile.Read and cut off as their output is read instead.
+var Readers = map[string]func(ctx context.Context, r io.Reader, opts codec.Options) io.Reader{
+	"lzss":       lz.NewReaderContext,
+	"lz77":       lz.NewLZ77ReaderContext,
+	"lz78":       lz.NewLZ78ReaderContext,
+	"dmc":        plainReader(dmc.NewReader),
+	"mcc":        mcc.NewReaderContext,
+	"huffman":    huffman.NewReaderContext,
+	"arithmetic": arithmetic.NewReaderContext,
+	"zlib": func(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+		z, err := zlib.NewReaderDict(r, opts.Dict)
+		if err != nil {
+			return &errReader{err}
+		}
+		return z
+	},
+	"flate": func(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+		return flate.NewReaderDict(r, opts.Dict)
+	},
+	"deflate": plainReader(deflate.NewReader),
+	"gzip": func(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+		z, err := gzip.NewReader(r)
+		if err != nil {
+			return &errReader{err}
+		}
+		return z
+	},
+	"lzw": func(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+		// LZW requires special parameters for lzw
+		return lzw.NewReader(r, lzw.MSB, 8)
+	},
+	"rlzw":  rlzw.NewReaderContext,
+	"lz4":   lz4.NewReaderContext,
+	"bzip2": bzip2.NewReaderContext,
+	"zstd":  zstd.NewReaderContext,
+	"ans":   ans.NewReaderContext,
+	"lzss-ans": func(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+		return lz.NewLiteralsReaderContext(ctx, r, ansLiterals, opts)
+	},
+}
+
+// plainReader adapts the NewReader of an algorithm that takes neither a context nor a limit to Readers.
+func plainReader(newReader func(io.Reader) io.Reader) func(context.Context, io.Reader, codec.Options) io.Reader {
+	return func(ctx context.Context, r io.Reader, opts codec.Options) io.Reader {
+		return newReader(r)
+	}
+}
+
+// errReader fails every read with err, standing in for the reader of an algorithm whose NewReader failed.
+type errReader struct {
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	return 0, e.err
+}
+
+// ErrReadOnly is returned when compressing with an algorithm that is in Readers but not Writers, such as zstd.
+var ErrReadOnly = errors.New("raisin: algorithm can only decompress")
+
+// IsReadOnly returns whether algorithm can decompress but not compress.
+func IsReadOnly(algorithm string) bool {
+	_, reads := Readers[algorithm]
+	_, writes := Writers[algorithm]
+	return reads && !writes
+}
+
+// ErrTooLarge is returned when decompressing a file would produce more than its size limit, protecting against decompression bombs.
+var ErrTooLarge = errors.New("raisin: decompressed size exceeds limit")
+
+// sizeLimitReader reads from r and fails with ErrTooLarge once more than remaining bytes have been read.
+type sizeLimitReader struct {
+	r         io.Reader
+	remaining int
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	// Read at most one byte past the limit, enough to tell whether it was exceeded
+	if len(p) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= n
+	if l.remaining < 0 {
+		return n + l.remaining, ErrTooLarge
+	}
+	return n, err
+}
+
+// context returns the Context to compress and decompress with, which is never done when none was set.
+func (f *CompressedFile) context() context.Context {
+	if f.Context == nil {
+		return context.Background()
+	}
+	return f.Context
 }
 
 func (f *CompressedFile) Read(content []byte) (int, error) {
 	if f.Decompressed == nil {
-		newReader := Readers[f.CompressionEngine]
-		var r io.Reader
-		var b io.Reader
-		b = bytes.NewReader(f.Compressed)
-		var err error
-		switch f.CompressionEngine {
-		default:
-			r = newReader.(func(io.Reader) io.Reader)(b)
-		case "zlib":
-			r, err = newReader.(func(r io.Reader) (io.ReadCloser, error))(b)
-		case "flate":
-			r = newReader.(func(r io.Reader) io.ReadCloser)(b)
-		case "gzip":
-			r, err = newReader.(func(r io.Reader) (*gzip.Reader, error))(b)
-		case "lzw":
-			// LZW requires special parameters for lzw
-			r = newReader.(func(io.Reader, lzw.Order, int) io.ReadCloser)(b, lzw.MSB, 8)
+		newReader, ok := Readers[f.CompressionEngine]
+		if !ok {
+			return 0, fmt.Errorf("raisin: unknown algorithm: %s", f.CompressionEngine)
 		}
-		check(err)
-		f.Decompressed, err = ioutil.ReadAll(r)
-		check(err)
+		r := newReader(f.context(), bytes.NewReader(f.Compressed), codec.Options{Limit: f.MaxDecompressedSize, Dict: f.Dictionary})
+		if f.Context != nil {
+			r = &contextReader{f.Context, r}
+		}
+		if f.MaxDecompressedSize > 0 {
+			r = &sizeLimitReader{r, f.MaxDecompressedSize}
+		}
+		decompressed, err := ioutil.ReadAll(r)
+		if err != nil {
+			return 0, err
+		}
+		f.Decompressed = decompressed
 	}
 	bytesToWriteOut := len(f.Decompressed[f.pos:])
 	if len(content) < bytesToWriteOut {
@@ -97,41 +194,133 @@ func (f *CompressedFile) Read(content []byte) (int, error) {
 	return bytesToWriteOut, err
 }
 
-// Writers represents a map of algorithm names to their NewWriter interfaces.
-var Writers = map[string]interface{}{
-	"lzss":       lz.NewWriter,
-	"dmc":        dmc.NewWriter,
-	"mcc":        mcc.NewWriter,
-	"huffman":    huffman.NewWriter,
-	"arithmetic": arithmetic.NewWriter,
-	"zlib":       zlib.NewWriter,
-	"flate":      flate.NewWriter,
-	"gzip":       gzip.NewWriter,
-	"lzw":        lzw.NewWriter,
+// Writers represents a map of algorithm names to their NewWriter functions. They compress with the preset dictionary in opts when
+// they support one, algorithms that can't stop part way through compressing are handed the content a chunk at a time and stop
+// between chunks instead.
+var Writers = map[string]func(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser{
+	"lzss": lz.NewWriterContext,
+	"lz77": func(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
+		return lz.NewLZ77WriterContext(ctx, w, lz.NewParseSettings())
+	},
+	"lz78":       lz.NewLZ78WriterContext,
+	"dmc":        dmc.NewWriterContext,
+	"mcc":        mcc.NewWriterContext,
+	"huffman":    huffman.NewWriterContext,
+	"arithmetic": arithmetic.NewWriterContext,
+	// Go's flate only looks back into the dictionary at the best compression level, so zlib compresses at it with one.
+	// Neither returns an error for a valid level.
+	"zlib": func(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
+		if len(opts.Dict) > 0 {
+			z, _ := zlib.NewWriterLevelDict(w, zlib.BestCompression, opts.Dict)
+			return &contextWriter{ctx, z}
+		}
+		return &contextWriter{ctx, zlib.NewWriter(w)}
+	},
+	"flate": func(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
+		if len(opts.Dict) > 0 {
+			z, _ := flate.NewWriterDict(w, 9, opts.Dict)
+			return &contextWriter{ctx, z}
+		}
+		z, _ := flate.NewWriter(w, 9)
+		return &contextWriter{ctx, z}
+	},
+	"deflate": deflate.NewWriterContext,
+	"gzip": func(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
+		return &contextWriter{ctx, gzip.NewWriter(w)}
+	},
+	"lzw": func(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
+		// LZW requires special parameters for lzw
+		return &contextWriter{ctx, lzw.NewWriter(w, lzw.MSB, 8)}
+	},
+	"rlzw":  rlzw.NewWriterContext,
+	"lz4":   chunkedWriter(lz4.NewWriter),
+	"bzip2": bzip2.NewWriterContext,
+	"ans":   chunkedWriter(ans.NewWriter),
+	"lzss-ans": func(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
+		return lz.NewLiteralsWriterContext(ctx, w, lz.NewParseSettings(), ansLiterals)
+	},
+}
+
+// chunkedWriter adapts the NewWriter of an algorithm that takes no context to Writers, stopping it between chunks.
+func chunkedWriter(newWriter func(io.Writer) io.WriteCloser) func(context.Context, io.Writer, codec.Options) io.WriteCloser {
+	return func(ctx context.Context, w io.Writer, opts codec.Options) io.WriteCloser {
+		return &contextWriter{ctx, newWriter(w)}
+	}
+}
+
+// ansLiterals codes the literals of lzss-ans with rANS, while its matches are stored as varints
+var ansLiterals = lz.LiteralCoder{
+	Encode: func(literals []byte) []byte { return ans.Compress(literals, ans.NewSettings()) },
+	Decode: ans.DecompressLimit,
+}
+
+// contextChunkSize is how many bytes contextWriter passes on to an algorithm between checks of its context.
+const contextChunkSize = 64 * 1024
+
+// contextWriter passes writes on to w a chunk at a time, failing with the context's error once ctx is done.
+type contextWriter struct {
+	ctx context.Context
+	w   io.WriteCloser
+}
+
+func (c *contextWriter) Write(p []byte) (int, error) {
+	written := 0
+	// Some algorithms write a header even for an empty write, so there's always at least one
+	for first := true; first || len(p) > 0; first = false {
+		if err := c.ctx.Err(); err != nil {
+			return written, err
+		}
+		chunk := p
+		if len(chunk) > contextChunkSize {
+			chunk = chunk[:contextChunkSize]
+		}
+		n, err := c.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, c.ctx.Err()
+}
+
+func (c *contextWriter) Close() error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	return c.w.Close()
+}
+
+// contextReader reads from r, failing with the context's error once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
 }
 
 func (f *CompressedFile) Write(content []byte) (int, error) {
 	var compressed []byte
-	newWriter := Writers[f.CompressionEngine]
+	newWriter, ok := Writers[f.CompressionEngine]
+	if IsReadOnly(f.CompressionEngine) {
+		return 0, fmt.Errorf("%w: %s", ErrReadOnly, f.CompressionEngine)
+	} else if !ok {
+		return 0, fmt.Errorf("raisin: unknown algorithm: %s", f.CompressionEngine)
+	}
 	var b bytes.Buffer
-	var w io.WriteCloser
-	var err error
-	switch f.CompressionEngine {
-	default:
-		w = newWriter.(func(io.Writer) io.WriteCloser)(&b)
-	case "zlib":
-		w = newWriter.(func(r io.Writer) *zlib.Writer)(&b)
-	case "flate":
-		w, err = newWriter.(func(w io.Writer, level int) (*flate.Writer, error))(&b, 9)
-	case "gzip":
-		w = newWriter.(func(w io.Writer) *gzip.Writer)(&b)
-	case "lzw":
-		// LZW requires special parameters for lzw
-		w = newWriter.(func(w io.Writer, order lzw.Order, litWidth int) io.WriteCloser)(&b, lzw.MSB, 8)
+	w := newWriter(f.context(), &b, codec.Options{Dict: f.Dictionary})
+	_, err := w.Write(content)
+	if err != nil {
+		return 0, err
+	}
+	if err = w.Close(); err != nil {
+		return 0, err
 	}
-	check(err)
-	w.Write(content)
-	w.Close()
 	compressed = b.Bytes()
 
 	f.Compressed = append(f.Compressed, compressed...)
@@ -146,20 +335,49 @@ func GetCompressedFileFromPath(path string) (CompressedFile, error) {
 	return cf, err
 }
 
+// FileSettings represents an object that can be used to modify the settings when compressing files with CompressFile
+// Auto is used to choose the algorithm chain of every file when the algorithms are just "auto".
+// Dictionary is a preset dictionary for the algorithms in DictionaryAlgorithms, nil compresses without one.
+type FileSettings struct {
+	Checksum   ChecksumType
+	Auto       AutoSettings
+	Dictionary []byte
+}
+
+// NewFileSettings returns the default settings used when compressing files as a FileSettings object
+func NewFileSettings() FileSettings {
+	s := FileSettings{}
+	s.Checksum = ChecksumCRC32
+	s.Auto = NewAutoSettings()
+	return s
+}
+
 // CompressFiles takes a set of compression algorithms as a string and multiple file paths as a slice and writes out the files in the same path with the extension appended.
-func CompressFiles(algorithms []string, files []string, extension string) {
+func CompressFiles(algorithms []string, files []string, extension string, settings FileSettings) {
 	for _, file := range files {
-		CompressFile(algorithms, file, file+extension)
+		CompressFile(algorithms, file, file+extension, settings)
 	}
 }
 
 // CompressFile takes a set of compression algorithms as a string and a path to a file and writes out the file  in the same path with .compressed appended to the end.
-func CompressFile(algorithms []string, path string, output string) []byte {
+// The output is wrapped in a container storing the checksum selected in settings so it can be verified on decompression.
+// With "auto" the smallest chain on the sample that also round-trips the whole file is used.
+func CompressFile(algorithms []string, path string, output string, settings FileSettings) []byte {
 	fileContents, err := ioutil.ReadFile(path)
 	check(err)
+	if IsAuto(algorithms) {
+		fmt.Printf("Choosing algorithms...\n")
+		var candidates []Candidate
+		_, candidates, err = ChooseAlgorithms(context.Background(), fileContents, settings.Auto)
+		check(err)
+		// The candidates were only tried on a sample, so the chain is checked against the whole file before it's used
+		algorithms, err = verifyChoice(context.Background(), fileContents, candidates, settings.Dictionary)
+		check(err)
+		fmt.Printf("Chose algorithm chain: %s\n", strings.Join(algorithms, ","))
+	}
 	fmt.Printf("Compressing...\n")
 
-	compressed := compress(fileContents, algorithms)
+	compressed := PackDict(fileContents, algorithms, settings.Checksum, settings.Dictionary)
 
 	err = ioutil.WriteFile(output, compressed, 0644)
 
@@ -171,52 +389,166 @@ func CompressFile(algorithms []string, path string, output string) []byte {
 	return compressed
 }
 
+// DecompressSettings represents an object that can be used to modify the settings when decompressing files with DecompressFile
+// MaxSize is the most bytes any layer may decompress to, protecting against decompression bombs, 0 means no limit.
+// Dictionaries are the preset dictionaries files may have been compressed with, each file picks the one whose ID it records.
+type DecompressSettings struct {
+	MaxSize      int
+	Dictionaries [][]byte
+}
+
+// DefaultMaxDecompressedSize is the default limit on the size of a decompressed file, 1 GiB.
+const DefaultMaxDecompressedSize = 1 << 30
+
+// NewDecompressSettings returns the default settings used when decompressing files as a DecompressSettings object
+func NewDecompressSettings() DecompressSettings {
+	s := DecompressSettings{}
+	s.MaxSize = DefaultMaxDecompressedSize
+	return s
+}
+
 // DecompressFiles takes a set of compression algorithms as a string and and multiple file paths as a slice and writes out the decompressed files in the same path with .decompressed appended to the end.
-func DecompressFiles(algorithms []string, files []string, extension string) {
+// It stops at the first file that fails to decompress and returns its error.
+func DecompressFiles(algorithms []string, files []string, extension string, settings DecompressSettings) error {
 	for _, file := range files {
 		path := file + extension
 		if strings.TrimSpace(extension) == "" {
 			ext := filepath.Ext(file)
 			path = strings.TrimSuffix(file, ext)
 		}
-		DecompressFile(algorithms, file, path)
+		if _, err := DecompressFile(algorithms, file, path, settings); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // DecompressFile takes a set of compression algorithms as a string and a path to a file and writes out the decompressed file in the same path with .decompressed appended to the end.
-func DecompressFile(algorithms []string, path string, output string) []byte {
+// If the file carries a checksum the decompressed contents are verified before anything is written out.
+func DecompressFile(algorithms []string, path string, output string, settings DecompressSettings) ([]byte, error) {
 	fileContents, err := ioutil.ReadFile(path)
-	check(err)
+	if err != nil {
+		return nil, err
+	}
 	fmt.Printf("Decompressing...\n")
 
-	decompressed := decompress(fileContents, algorithms)
+	decompressed, _, err := UnpackDict(fileContents, algorithms, settings.MaxSize, settings.Dictionaries)
+	if err != nil {
+		return nil, err
+	}
 
-	err = ioutil.WriteFile(output, decompressed, 0644)
-	check(err)
+	if err := ioutil.WriteFile(output, decompressed, 0644); err != nil {
+		return nil, err
+	}
+	return decompressed, nil
+}
+
+// TestFile takes a set of compression algorithms as a string and a path to a compressed file and verifies that it decompresses to the checksummed contents without writing anything out.
+// An error is returned if the file cannot be decompressed, does not match its checksum, or has no checksum to verify against.
+func TestFile(algorithms []string, path string, settings DecompressSettings) error {
+	fileContents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
 
-	return decompressed
+	_, header, err := UnpackDict(fileContents, algorithms, settings.MaxSize, settings.Dictionaries)
+	if err != nil {
+		return err
+	}
+	if header.Checksum == ChecksumNone {
+		return fmt.Errorf("raisin: %s has no checksum to verify against", path)
+	}
+	return nil
 }
 
 // Result is an intermediary object used to represent the benchmarked results of a certain file and algorithm.
+// Speeds are measured in megabytes of original data per second.
 type Result struct {
-	CompressionEngine string
-	TimeTaken         string
-	Ratio             float32
-	ActualEntropy     float32
-	Entropy           float64
-	Lossless          bool
-	Failed            bool
-}
-
-// BenchmarkSuite takes a set of files and algorithms and returns the result as an html table if generateHTML is set.
-// The result is also outputted to stdout.
-func BenchmarkSuite(files []string, algorithms [][]string, generateHTML bool) (string, []Result) {
+	CompressionEngine string        `json:"engine"`
+	File              string        `json:"file"`
+	TimeTaken         string        `json:"time_taken"`
+	Ratio             float32       `json:"ratio"`
+	ActualEntropy     float32       `json:"actual_entropy"`
+	Entropy           float64       `json:"entropy"`
+	Lossless          bool          `json:"lossless"`
+	Failed            bool          `json:"failed"`
+	OriginalBytes     int64         `json:"original_bytes"`
+	CompressedBytes   int64         `json:"compressed_bytes"`
+	CompressTime      time.Duration `json:"compress_ns"`
+	DecompressTime    time.Duration `json:"decompress_ns"`
+	CompressSpeed     float64       `json:"compress_mb_per_s"`
+	DecompressSpeed   float64       `json:"decompress_mb_per_s"`
+	CompressStats     TimingStats   `json:"compress_stats"`
+	DecompressStats   TimingStats   `json:"decompress_stats"`
+	CompressMemory    MemoryStats   `json:"compress_memory"`
+	DecompressMemory  MemoryStats   `json:"decompress_memory"`
+	PeakRSSBytes      int64         `json:"peak_rss_bytes"`
+}
+
+// BenchmarkSettings represents an object that can be used to modify the settings when benchmarking with BenchmarkSuite
+// Runs, WarmupRuns and MeasureMemory are passed on to BenchmarkFile for every file and algorithm.
+// Runner replaces BenchmarkFileContext when set, for example to run every benchmark in an isolated process.
+// Timeout limits how long each algorithm may take on a file, zero disables it.
+// MaxConcurrency limits how many algorithms are benchmarked at once, zero runs them all at once.
+// Template replaces the embedded html report template when set, see LoadTemplate.
+// Dictionary is passed on to BenchmarkFile for every file and algorithm.
+type BenchmarkSettings struct {
+	GenerateHTML   bool
+	Format         string
+	Output         io.Writer
+	Runs           int
+	WarmupRuns     int
+	MeasureMemory  bool
+	Runner         func(ctx context.Context, algorithms []string, fileString string, settings Settings) (Result, error)
+	Timeout        time.Duration
+	MaxConcurrency int
+	Template       *template.Template
+	Dictionary     []byte
+}
+
+// LoadTemplate reads and parses an html report template from path for BenchmarkSettings.Template.
+// The template is executed with the report's Tables and Created time, like templates/benchmark.html.
+func LoadTemplate(path string) (*template.Template, error) {
+	return template.ParseFiles(path)
+}
+
+// NewBenchmarkSettings returns the default settings for BenchmarkSuite as a BenchmarkSettings object
+func NewBenchmarkSettings() BenchmarkSettings {
+	s := BenchmarkSettings{}
+	s.Format = "table"
+	s.Output = os.Stdout
+	s.Runs = 1
+	s.MeasureMemory = true
+	s.Runner = BenchmarkFileContext
+	s.Timeout = 1 * time.Minute
+	return s
+}
+
+// BenchmarkSuite takes a set of files, algorithms and settings and returns the result as an html table if settings.GenerateHTML is set.
+// Progress and, for the "table" format, the result tables are written to settings.Output.
+// Any other format is left to the caller through WriteResults.
+func BenchmarkSuite(files []string, algorithms [][]string, settings BenchmarkSettings) (string, []Result) {
+	return BenchmarkSuiteContext(context.Background(), files, algorithms, settings)
+}
+
+// BenchmarkSuiteContext is like BenchmarkSuite but stops once ctx is done.
+// Algorithms still running are aborted and reported as cancelled, remaining files are skipped.
+func BenchmarkSuiteContext(ctx context.Context, files []string, algorithms [][]string, settings BenchmarkSettings) (string, []Result) {
 	var html string
 	var allResults []Result
-	timeout := 1 * time.Minute
+	out := settings.Output
+
+	var semaphore chan struct{}
+	if settings.MaxConcurrency > 0 {
+		semaphore = make(chan struct{}, settings.MaxConcurrency)
+	}
 
 	for i, fileString := range files {
-		fmt.Printf("Compressing file %d/%d - %s\n", i+1, len(files), fileString)
+		if ctx.Err() != nil {
+			fmt.Fprintf(out, "Benchmark cancelled, skipping %d remaining file(s)\n", len(files)-i)
+			break
+		}
+		fmt.Fprintf(out, "Compressing file %d/%d - %s\n", i+1, len(files), fileString)
 		results := make([]Result, 0)
 		failedResults := make([]Result, 0)
 
@@ -225,41 +557,63 @@ func BenchmarkSuite(files []string, algorithms [][]string, generateHTML bool) (s
 		fileSize := int64(len(fileContents))
 
 		t := table.NewWriter()
-		t.SetOutputMirror(os.Stdout)
+		if settings.Format == "table" {
+			t.SetOutputMirror(out)
+		}
 		t.SetStyle(table.StyleLight)
-		t.AppendHeader(table.Row{"engine", "time taken", "compression ratio", "actual entropy", "theoretical entropy", "lossless"})
+		t.AppendHeader(table.Row{"engine", "time taken", "compress (median)", "decompress (median)", "peak heap (c/d)", "compression ratio", "actual entropy", "theoretical entropy", "lossless"})
 
 		resultChans := make(map[string]chan Result)
 		var wg sync.WaitGroup
 
 		for _, algorithmsInLayer := range algorithms {
 			algorithmsString := strings.Join(algorithmsInLayer[:], ",")
-			fmt.Println("Benchmarking", algorithmsString)
+			fmt.Fprintln(out, "Benchmarking", algorithmsString)
 
 			resultChannel := make(chan Result, 1)
 			resultChans[algorithmsString] = resultChannel
 
+			fileSettings := NewSuiteSettings()
+			fileSettings.Output = out
+			fileSettings.Runs = settings.Runs
+			fileSettings.WarmupRuns = settings.WarmupRuns
+			fileSettings.MeasureMemory = settings.MeasureMemory
+			fileSettings.Dictionary = settings.Dictionary
+
+			runner := settings.Runner
+			if runner == nil {
+				runner = BenchmarkFileContext
+			}
+
 			wg.Add(1)
-			go AsyncBenchmarkFile(resultChannel, &wg, algorithmsInLayer, fileString)
+			go func(algorithmsInLayer []string, resultChannel chan Result) {
+				if semaphore != nil {
+					select {
+					case semaphore <- struct{}{}:
+						defer func() { <-semaphore }()
+					case <-ctx.Done():
+					}
+				}
+				runCtx, cancel := context.WithCancel(ctx)
+				if settings.Timeout > 0 {
+					runCtx, cancel = context.WithTimeout(ctx, settings.Timeout)
+				}
+				defer cancel()
+				asyncBenchmark(runCtx, runner, resultChannel, &wg, algorithmsInLayer, fileString, fileSettings)
+			}(algorithmsInLayer, resultChannel)
 		}
 
-		waitTimeout(&wg, timeout)
+		wg.Wait()
 
-		for compressionEngineName, resultChan := range resultChans {
-			select {
-			case result := <-resultChan:
-				if result.Failed {
-					failedResults = append(failedResults, result)
-				} else {
-					results = append(results, result)
+		for _, resultChan := range resultChans {
+			result := <-resultChan
+			if result.Failed {
+				if result.OriginalBytes == 0 {
+					result.OriginalBytes = fileSize
 				}
-			default:
-				result := Result{}
-				result.CompressionEngine = compressionEngineName
-				result.TimeTaken = fmt.Sprintf(">%s", timeout)
-				result.Lossless = false
-				result.Failed = true
 				failedResults = append(failedResults, result)
+			} else {
+				results = append(results, result)
 			}
 		}
 
@@ -276,92 +630,200 @@ func BenchmarkSuite(files []string, algorithms [][]string, generateHTML bool) (s
 		})
 
 		for _, result := range results {
-			t.AppendRow([]interface{}{result.CompressionEngine, result.TimeTaken, fmt.Sprintf("%.2f%%", result.Ratio), fmt.Sprintf("%.2f", result.ActualEntropy), fmt.Sprintf("%.2f", result.Entropy), result.Lossless})
+			t.AppendRow([]interface{}{result.CompressionEngine, result.TimeTaken, result.CompressStats.String(), result.DecompressStats.String(), memoryColumn(result), fmt.Sprintf("%.2f%%", result.Ratio), fmt.Sprintf("%.2f", result.ActualEntropy), fmt.Sprintf("%.2f", result.Entropy), result.Lossless})
 			allResults = append(allResults, result)
 		}
 
 		t.AppendSeparator()
 		for _, result := range failedResults {
-			t.AppendRow([]interface{}{result.CompressionEngine, result.TimeTaken, "DNF", "DNF", "DNF", result.Lossless})
+			t.AppendRow([]interface{}{result.CompressionEngine, result.TimeTaken, "DNF", "DNF", "DNF", "DNF", "DNF", "DNF", result.Lossless})
 			allResults = append(allResults, result)
 		}
 		t.AppendSeparator()
 		t.AppendRow(table.Row{"File", fileString, "Size", ByteCountSI(fileSize)})
 
 		t.Render()
-		if generateHTML {
-			html = html + "<br>" + t.RenderHTML()
+		if settings.GenerateHTML {
+			html = html + string(htmlSection(fileString, fileSize, append(results, failedResults...)))
 		}
 	}
-	if generateHTML {
-		tmpl := template.Must(template.ParseFiles("templates/benchmark.html"))
+	if settings.GenerateHTML {
+		tmpl := settings.Template
+		if tmpl == nil {
+			tmpl = template.Must(template.New("benchmark.html").Parse(templates.Benchmark))
+		}
 		var b bytes.Buffer
-		tmpl.Execute(&b, struct {
+		err := tmpl.Execute(&b, struct {
 			Tables  template.HTML
 			Created string
 		}{Tables: template.HTML(html), Created: strconv.FormatInt(time.Now().Unix(), 10)})
+		check(err)
 		return b.String(), allResults
 	}
 	return "", allResults
 }
 
-// AsyncBenchmarkFile takes a channel to push the result, a waitgroup, engines, a file string and runs the benchmark.
+// AsyncBenchmarkFile takes a channel to push the result, a waitgroup, engines, a file string, a settings object and runs the benchmark.
 // The function will push the result to the channel or push a failed result if it is able to catch an error during execution.
-func AsyncBenchmarkFile(resultChannel chan Result, wg *sync.WaitGroup, compressionEngines []string, fileString string) {
+func AsyncBenchmarkFile(resultChannel chan Result, wg *sync.WaitGroup, compressionEngines []string, fileString string, settings Settings) {
+	asyncBenchmark(context.Background(), BenchmarkFileContext, resultChannel, wg, compressionEngines, fileString, settings)
+}
+
+// abortGracePeriod is how long asyncBenchmark waits for a benchmark to stop after its context is done.
+const abortGracePeriod = 1 * time.Second
+
+// asyncBenchmark runs the benchmark in its own goroutine so it can return as soon as ctx is done,
+// the benchmark itself stops at the next point it checks ctx.
+func asyncBenchmark(ctx context.Context, runner func(context.Context, []string, string, Settings) (Result, error), resultChannel chan Result, wg *sync.WaitGroup, compressionEngines []string, fileString string, settings Settings) {
 	defer wg.Done()
 
 	algorithmsString := strings.Join(compressionEngines[:], ",")
-
-	errorHandler := func() {
-		if r := recover(); r != nil {
-			fmt.Printf("%s errored during execution, continuing\n", algorithmsString)
-			fmt.Println("Err:", r)
-			fmt.Println(string(debug.Stack()))
-			fmt.Println("Continuing")
-			result := Result{}
-			result.CompressionEngine = algorithmsString
-			result.TimeTaken = "failed"
-			result.Lossless = false
-			result.Failed = true
-			resultChannel <- result
-		}
+	out := settings.Output
+	if out == nil {
+		out = os.Stdout
 	}
 
-	defer errorHandler()
+	type outcome struct {
+		result Result
+		err    error
+	}
+	done := make(chan outcome, 1)
 	start := time.Now()
-	result := BenchmarkFile(compressionEngines, fileString, NewSuiteSettings())
-	duration := time.Since(start)
-	result.TimeTaken = fmt.Sprintf("%s", duration.Round(10*time.Microsecond).String())
 
-	fmt.Printf("%s finished benchmarking\n", algorithmsString)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Fprintf(out, "%s errored during execution, continuing\n", algorithmsString)
+				fmt.Fprintln(out, "Err:", r)
+				fmt.Fprintln(out, string(debug.Stack()))
+				fmt.Fprintln(out, "Continuing")
+				done <- outcome{err: fmt.Errorf("%v", r)}
+			}
+		}()
+		result, err := runner(ctx, compressionEngines, fileString, settings)
+		done <- outcome{result, err}
+	}()
+
+	var err error
+	select {
+	case finished := <-done:
+		if finished.err == nil {
+			result := finished.result
+			result.TimeTaken = fmt.Sprintf("%s", time.Since(start).Round(10*time.Microsecond).String())
+			fmt.Fprintf(out, "%s finished benchmarking\n", algorithmsString)
+			resultChannel <- result
+			return
+		}
+		err = finished.err
+	case <-ctx.Done():
+		err = ctx.Err()
+		// Give the benchmark a moment to notice, so that for example isolated worker processes are killed before we return
+		select {
+		case <-done:
+		case <-time.After(abortGracePeriod):
+		}
+	}
 
+	result := Result{}
+	result.CompressionEngine = algorithmsString
+	result.File = fileString
+	result.Lossless = false
+	result.Failed = true
+	if errors.Is(err, context.DeadlineExceeded) {
+		timeout := time.Since(start)
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = deadline.Sub(start)
+		}
+		result.TimeTaken = fmt.Sprintf(">%s", timeout.Round(time.Millisecond))
+		fmt.Fprintf(out, "%s timed out, aborting\n", algorithmsString)
+	} else if errors.Is(err, context.Canceled) {
+		result.TimeTaken = "cancelled"
+		fmt.Fprintf(out, "%s cancelled\n", algorithmsString)
+	} else {
+		result.TimeTaken = "failed"
+		fmt.Fprintf(out, "%s failed: %s\n", algorithmsString, err)
+	}
 	resultChannel <- result
 }
 
 // Settings represents an object that can be used to modify the settings when benchmarking files with BenchmarkFile
+// Output is where status and stats are printed, it defaults to stdout when unset.
+// Runs is the number of timed runs (at least one) and WarmupRuns the number of untimed runs done beforehand.
+// MeasureMemory adds an untimed run of each phase to record its memory usage.
+// Dictionary is a preset dictionary for the algorithms in DictionaryAlgorithms to compress and decompress with.
 type Settings struct {
 	WriteOutFiles bool
 	PrintStats    bool
 	PrintStatus   bool
+	Output        io.Writer
+	Runs          int
+	WarmupRuns    int
+	MeasureMemory bool
+	Dictionary    []byte
 }
 
 // NewSuiteSettings returns common settings for a testing suite as a Settings object
 func NewSuiteSettings() Settings {
 	s := Settings{}
 	s.PrintStatus = true
+	s.Output = os.Stdout
+	s.Runs = 1
+	s.MeasureMemory = true
 	return s
 }
 
 // BenchmarkFile takes a set of algorithms, a file path, and a settings object.
 // It benchmarks the file and returns the result as a Result object.
+// Compression and decompression are timed separately over settings.Runs runs, the reported times are the medians.
 func BenchmarkFile(algorithms []string, fileString string, settings Settings) Result {
-	fileContents, err := ioutil.ReadFile(fileString)
+	result, err := BenchmarkFileContext(context.Background(), algorithms, fileString, settings)
 	check(err)
+	return result
+}
+
+// BenchmarkFileContext is like BenchmarkFile but gives up and returns the context's error once ctx is done.
+// Errors reading the file are returned rather than panicking.
+// A read-only algorithm such as zstd on its own can't compress, so the file is taken to be compressed with it already
+// and only decompression is timed, which lets files written by other tools be compared against raisin's own algorithms.
+func BenchmarkFileContext(ctx context.Context, algorithms []string, fileString string, settings Settings) (Result, error) {
+	fileContents, err := ioutil.ReadFile(fileString)
+	if err != nil {
+		return Result{}, err
+	}
+
+	readOnly := len(algorithms) == 1 && IsReadOnly(algorithms[0])
+	compressOnce := func() ([]byte, error) {
+		return compressDict(ctx, fileContents, algorithms, settings.Dictionary)
+	}
+	if readOnly {
+		compressedContents := fileContents
+		if fileContents, err = decompressContext(ctx, compressedContents, algorithms); err != nil {
+			return Result{}, err
+		}
+		compressOnce = func() ([]byte, error) {
+			return compressedContents, nil
+		}
+	}
+
+	out := settings.Output
+	if out == nil {
+		out = os.Stdout
+	}
 
 	algorithmsString := strings.Join(algorithms[:], ",")
+	if IsAuto(algorithms) {
+		if settings.PrintStatus {
+			fmt.Fprintf(out, "%s Choosing algorithms...\n", algorithmsString)
+		}
+		algorithms, _, err = ChooseAlgorithms(ctx, fileContents, NewAutoSettings())
+		if err != nil {
+			return Result{}, err
+		}
+		algorithmsString = fmt.Sprintf("auto (%s)", strings.Join(algorithms, ","))
+	}
 
 	if settings.PrintStatus {
-		fmt.Printf("%s Compressing...\n", algorithmsString)
+		fmt.Fprintf(out, "%s Compressing...\n", algorithmsString)
 	}
 
 	symbolFrequencies := make(map[byte]int)
@@ -376,11 +838,38 @@ func BenchmarkFile(algorithms []string, fileString string, settings Settings) Re
 		i++
 	}
 
+	for i := 0; i < settings.WarmupRuns; i++ {
+		compressed, err := compressOnce()
+		if err != nil {
+			return Result{}, err
+		}
+		_, err = decompressDict(ctx, compressed, algorithms, 0, settings.Dictionary)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	runs := settings.Runs
+	if runs < 1 {
+		runs = 1
+	}
+	compressTimes := make([]time.Duration, runs)
+	decompressTimes := make([]time.Duration, runs)
+
 	start := time.Now()
 
 	content := fileContents
 
-	content = compress(content, algorithms)
+	for run := 0; run < runs; run++ {
+		compressStart := time.Now()
+		content, err = compressOnce()
+		if err != nil {
+			return Result{}, err
+		}
+		if !readOnly {
+			compressTimes[run] = time.Since(compressStart)
+		}
+	}
 
 	if settings.WriteOutFiles {
 		var compressedFilePath = filepath.Base(fileString) + ".compressed"
@@ -390,10 +879,17 @@ func BenchmarkFile(algorithms []string, fileString string, settings Settings) Re
 	compressed := content
 
 	if settings.PrintStatus {
-		fmt.Printf("%s Decompressing...\n", algorithmsString)
+		fmt.Fprintf(out, "%s Decompressing...\n", algorithmsString)
 	}
 
-	content = decompress(content, algorithms)
+	for run := 0; run < runs; run++ {
+		decompressStart := time.Now()
+		content, err = decompressDict(ctx, compressed, algorithms, 0, settings.Dictionary)
+		if err != nil {
+			return Result{}, err
+		}
+		decompressTimes[run] = time.Since(decompressStart)
+	}
 
 	if settings.WriteOutFiles {
 		var decompressedFilePath = filepath.Base(fileString) + ".decompressed"
@@ -405,6 +901,17 @@ func BenchmarkFile(algorithms []string, fileString string, settings Settings) Re
 
 	duration := time.Since(start)
 
+	var compressMemory, decompressMemory MemoryStats
+	if settings.MeasureMemory {
+		if !readOnly {
+			compressMemory = measureMemory(func() { compressDict(ctx, fileContents, algorithms, settings.Dictionary) })
+		}
+		decompressMemory = measureMemory(func() { decompressDict(ctx, compressed, algorithms, 0, settings.Dictionary) })
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+	}
+
 	lossless := reflect.DeepEqual(fileContents, decompressed)
 	percentageDiff := float32(len(compressed)) / float32(len(fileContents)) * 100
 	entropy := ent.Entropy(freqs, math.Log)
@@ -425,55 +932,138 @@ func BenchmarkFile(algorithms []string, fileString string, settings Settings) Re
 	timeTaken := fmt.Sprintf("%s", duration.Round(10*time.Microsecond).String())
 
 	if settings.PrintStats {
-		fmt.Printf("Lossless: %t\n", lossless)
+		fmt.Fprintf(out, "Lossless: %t\n", lossless)
 
-		fmt.Printf("Original bytes: %v\n", len(fileContents))
-		fmt.Printf("Compressed bytes: %v\n", len(compressed))
+		fmt.Fprintf(out, "Original bytes: %v\n", len(fileContents))
+		fmt.Fprintf(out, "Compressed bytes: %v\n", len(compressed))
 		if !lossless {
-			fmt.Printf("Decompressed bytes: %v\n", len(decompressed))
+			fmt.Fprintf(out, "Decompressed bytes: %v\n", len(decompressed))
 		}
-		fmt.Printf("Compression ratio: %.2f%%\n", percentageDiff)
-		fmt.Printf("Original Shannon entropy: %.2f\n", entropy)
-		fmt.Printf("Compressed Shannon entropy: %.2f\n", actualEntropy)
-		fmt.Printf("Time taken: %s\n", timeTaken)
+		fmt.Fprintf(out, "Compression ratio: %.2f%%\n", percentageDiff)
+		fmt.Fprintf(out, "Original Shannon entropy: %.2f\n", entropy)
+		fmt.Fprintf(out, "Compressed Shannon entropy: %.2f\n", actualEntropy)
+		fmt.Fprintf(out, "Time taken: %s\n", timeTaken)
 	}
-	return Result{algorithmsString, timeTaken, percentageDiff, actualEntropy, entropy, lossless, false}
+	compressStats := NewTimingStats(compressTimes)
+	decompressStats := NewTimingStats(decompressTimes)
+	return Result{
+		CompressionEngine: algorithmsString,
+		File:              fileString,
+		TimeTaken:         timeTaken,
+		Ratio:             percentageDiff,
+		ActualEntropy:     actualEntropy,
+		Entropy:           entropy,
+		Lossless:          lossless,
+		OriginalBytes:     int64(len(fileContents)),
+		CompressedBytes:   int64(len(compressed)),
+		CompressTime:      compressStats.Median,
+		DecompressTime:    decompressStats.Median,
+		CompressSpeed:     megabytesPerSecond(len(fileContents), compressStats.Median),
+		DecompressSpeed:   megabytesPerSecond(len(fileContents), decompressStats.Median),
+		CompressStats:     compressStats,
+		DecompressStats:   decompressStats,
+		CompressMemory:    compressMemory,
+		DecompressMemory:  decompressMemory,
+	}, nil
+}
+
+func memoryColumn(result Result) string {
+	column := fmt.Sprintf("%s / %s", result.CompressMemory, result.DecompressMemory)
+	if result.PeakRSSBytes > 0 {
+		column += fmt.Sprintf(" (rss %s)", ByteCountSI(result.PeakRSSBytes))
+	}
+	return column
+}
+
+func megabytesPerSecond(bytes int, duration time.Duration) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return float64(bytes) / 1e6 / duration.Seconds()
 }
 
 func compress(content []byte, algorithms []string) []byte {
+	content, err := compressContext(context.Background(), content, algorithms)
+	check(err)
+	return content
+}
+
+// compressContext compresses content with each algorithm in turn, stopping with the context's error once ctx is done.
+// Algorithms that take a context are aborted part way through coding, any other algorithm between the chunks it's written.
+func compressContext(ctx context.Context, content []byte, algorithms []string) ([]byte, error) {
+	return compressDict(ctx, content, algorithms, nil)
+}
+
+// compressDict is like compressContext but passes dict to every algorithm that supports a preset dictionary.
+func compressDict(ctx context.Context, content []byte, algorithms []string, dict []byte) ([]byte, error) {
 	for _, algorithm := range algorithms {
-		file := CompressedFile{MaxSearchBufferLength: 4096}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		file := CompressedFile{MaxSearchBufferLength: 4096, Context: ctx, Dictionary: dict}
 		file.CompressionEngine = algorithm
-		file.Write(content)
+		_, err := file.Write(content)
+		if err != nil {
+			return nil, err
+		}
 
 		content = file.Compressed
 	}
-	return content
+	return content, nil
 }
 
 func decompress(content []byte, algorithms []string) []byte {
-	for i := len(algorithms) - 1; i >= 0; i-- {
-		algorithm := algorithms[i]
-		file := CompressedFile{}
-		file.Compressed = content
-		file.CompressionEngine = algorithm
+	content, err := decompressContext(context.Background(), content, algorithms)
+	check(err)
+	return content
+}
 
-		stream := make([]byte, 0)
-		out := make([]byte, 512)
-		for {
-			n, err := file.Read(out)
-			if err != nil && err != io.EOF {
-				panic(err)
-			} else {
-				stream = append(stream, out[0:n]...)
-			}
+// decompressContext is the inverse of compressContext, aborting algorithms that take a context part way and any other between reads.
+func decompressContext(ctx context.Context, content []byte, algorithms []string) ([]byte, error) {
+	return decompressLimit(ctx, content, algorithms, 0)
+}
 
-			if err == io.EOF {
-				break
-			}
+// decompressLimit is like decompressContext but fails with an error wrapping ErrTooLarge or the algorithm's own error
+// once any layer decompresses to more than limit bytes, a limit of 0 means no limit.
+func decompressLimit(ctx context.Context, content []byte, algorithms []string, limit int) ([]byte, error) {
+	return decompressDict(ctx, content, algorithms, limit, nil)
+}
+
+// decompressDict is like decompressLimit for content compressed by compressDict with the dictionary dict.
+func decompressDict(ctx context.Context, content []byte, algorithms []string, limit int, dict []byte) ([]byte, error) {
+	for i := len(algorithms) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var err error
+		content, err = decompressLayer(ctx, content, algorithms[i], limit, dict)
+		if err != nil {
+			return nil, fmt.Errorf("raisin: %s failed to decompress: %w", algorithms[i], err)
+		}
+	}
+	return content, nil
+}
+
+func decompressLayer(ctx context.Context, content []byte, algorithm string, limit int, dict []byte) ([]byte, error) {
+	file := CompressedFile{Context: ctx, Dictionary: dict}
+	file.Compressed = content
+	file.CompressionEngine = algorithm
+	file.MaxDecompressedSize = limit
+
+	stream := make([]byte, 0)
+	out := make([]byte, 512)
+	for {
+		n, err := file.Read(out)
+		if err != nil && err != io.EOF {
+			return nil, err
+		} else {
+			stream = append(stream, out[0:n]...)
 		}
 
-		content = file.Decompressed
+		if err == io.EOF {
+			break
+		}
 	}
-	return content
+
+	return file.Decompressed, nil
 }
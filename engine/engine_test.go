@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	corpus "github.com/go-compression/raisin/corpus"
+)
+
+func TestBenchmarkSuiteTimeout(t *testing.T) {
+	file, err := ioutil.TempFile("", "raisin-benchmark-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("I AM SAM. I AM SAM. SAM I AM.")
+	file.Close()
+
+	settings := NewBenchmarkSettings()
+	settings.Output = ioutil.Discard
+	settings.Timeout = 50 * time.Millisecond
+	settings.MaxConcurrency = 1
+	settings.Runner = func(ctx context.Context, algorithms []string, fileString string, settings Settings) (Result, error) {
+		if algorithms[0] == "slow" {
+			<-ctx.Done()
+			return Result{}, ctx.Err()
+		}
+		return BenchmarkFileContext(ctx, algorithms, fileString, settings)
+	}
+
+	start := time.Now()
+	_, results := BenchmarkSuite([]string{file.Name()}, [][]string{{"slow"}, {"flate"}}, settings)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Timed out benchmark took %s to give up", elapsed)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	for _, result := range results {
+		switch result.CompressionEngine {
+		case "slow":
+			if !result.Failed || !strings.HasPrefix(result.TimeTaken, ">") {
+				t.Errorf("Expected slow to time out, got %+v", result)
+			}
+		case "flate":
+			if result.Failed || !result.Lossless {
+				t.Errorf("Expected flate to succeed, got %+v", result)
+			}
+		}
+	}
+}
+
+func TestBenchmarkSuiteTimeoutSlowCodec(t *testing.T) {
+	// dmc takes far longer than the timeout on this much text, so the benchmark only returns promptly if dmc stops
+	content, _ := corpus.Generate("text", 1<<20, 1)
+	file, err := ioutil.TempFile("", "raisin-benchmark-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.Write(content)
+	file.Close()
+
+	settings := NewBenchmarkSettings()
+	settings.Output = ioutil.Discard
+	settings.MeasureMemory = false
+	settings.Timeout = 50 * time.Millisecond
+	goroutines := runtime.NumGoroutine()
+
+	start := time.Now()
+	_, results := BenchmarkSuite([]string{file.Name()}, [][]string{{"dmc"}}, settings)
+	if elapsed := time.Since(start); elapsed >= abortGracePeriod {
+		t.Errorf("Expected dmc to stop within the grace period, took %s", elapsed)
+	}
+	if len(results) != 1 || !results[0].Failed {
+		t.Fatalf("Expected dmc to time out, got %+v", results)
+	}
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > goroutines && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("Expected the benchmark's goroutines to exit, %d are left of %d", n, goroutines)
+	}
+}
+
+func TestContextCancelledMidLayer(t *testing.T) {
+	content, _ := corpus.Generate("text", 16*1024, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for algorithm := range Writers {
+		// compressContext checks the context before every layer, so the layer is written to directly
+		file := CompressedFile{CompressionEngine: algorithm, Context: ctx}
+		if _, err := file.Write(content); !errors.Is(err, context.Canceled) {
+			t.Errorf("[%s] Expected context.Canceled compressing, got %v", algorithm, err)
+		}
+	}
+	for algorithm := range Readers {
+		// Decompression stops before reading anything, so read-only algorithms and dmc, which can't decompress yet, are given the content as it is
+		compressed := content
+		if !IsReadOnly(algorithm) && algorithm != "dmc" {
+			compressed = compress(content, []string{algorithm})
+		}
+		if _, err := decompressLayer(ctx, compressed, algorithm, 0, nil); !errors.Is(err, context.Canceled) {
+			t.Errorf("[%s] Expected context.Canceled decompressing, got %v", algorithm, err)
+		}
+	}
+}
+
+func TestBenchmarkSuiteCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	settings := NewBenchmarkSettings()
+	settings.Output = ioutil.Discard
+	_, results := BenchmarkSuiteContext(ctx, []string{"does-not-exist"}, [][]string{{"flate"}}, settings)
+	if len(results) != 0 {
+		t.Errorf("Expected a cancelled suite to skip every file, got %d results", len(results))
+	}
+}
+
+func TestBenchmarkSuiteHTML(t *testing.T) {
+	file, err := ioutil.TempFile("", "raisin-benchmark-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("I AM SAM. I AM SAM. SAM I AM.")
+	file.Close()
+
+	// Run from a directory without templates/benchmark.html to make sure the embedded template is used
+	wd, _ := os.Getwd()
+	os.Chdir(os.TempDir())
+	defer os.Chdir(wd)
+
+	settings := NewBenchmarkSettings()
+	settings.Output = ioutil.Discard
+	settings.GenerateHTML = true
+	html, _ := BenchmarkSuite([]string{file.Name()}, [][]string{{"flate"}}, settings)
+	if !strings.Contains(html, "Raisin Benchmark Results") || !strings.Contains(html, "<svg") {
+		t.Errorf("Expected an html report with a chart, got %s", html)
+	}
+}
+
+func TestBenchmarkReadOnly(t *testing.T) {
+	// A zstd frame holding a single raw block, as zstd can't be compressed with
+	content := []byte("I AM SAM. I AM SAM. SAM I AM.")
+	frame := append([]byte{0x28, 0xb5, 0x2f, 0xfd, 0x20, byte(len(content)), byte(len(content)<<3 | 1), 0, 0}, content...)
+	file, err := ioutil.TempFile("", "raisin-benchmark-*.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.Write(frame)
+	file.Close()
+
+	result, err := BenchmarkFileContext(context.Background(), []string{"zstd"}, file.Name(), Settings{Output: ioutil.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Lossless || result.OriginalBytes != int64(len(content)) || result.CompressedBytes != int64(len(frame)) || result.CompressTime != 0 {
+		t.Errorf("Expected the file to be benchmarked as already compressed, got %+v", result)
+	}
+
+	if _, err := compressContext(context.Background(), content, []string{"zstd"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected compressing with zstd to fail with ErrReadOnly, got %v", err)
+	}
+	if unpacked, _, err := Unpack(frame, []string{"zstd"}); err != nil || !bytes.Equal(unpacked, content) {
+		t.Errorf("Failed to decompress a zstd frame without a container: %v", err)
+	}
+}
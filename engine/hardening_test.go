@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	lz "github.com/go-compression/raisin/compressor/lz"
+)
+
+// decompressNoPanic runs decompressLimit on a single algorithm, turning a panic into an error that mentions it.
+func decompressNoPanic(content []byte, algorithm string, limit int) (out []byte, panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			panicked = true
+		}
+	}()
+	out, err = decompressLimit(context.Background(), content, []string{algorithm}, limit)
+	return out, false, err
+}
+
+func TestDecompressGarbage(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	inputs := [][]byte{
+		{},
+		{0},
+		{0, 0, 0, 0},
+		[]byte("<"),
+		[]byte("<1,"),
+		[]byte("a<9,9>"),
+		[]byte("a<1,9>"),
+		[]byte("a<-1,1>"),
+		[]byte("a<x,1>"),
+		[]byte("\\"),
+		[]byte("\\\n"),
+		[]byte("1|a\\\n"),
+		[]byte("1|a\\\n\x09\xff"),
+		[]byte("9,9,9\\"),
+		[]byte("0,0,0"),
+	}
+	for i := 0; i < 50; i++ {
+		garbage := make([]byte, r.Intn(256))
+		r.Read(garbage)
+		inputs = append(inputs, garbage)
+	}
+	for _, algorithm := range sortedReaders() {
+		for _, input := range inputs {
+			if _, panicked, err := decompressNoPanic(input, algorithm, 1<<20); panicked {
+				t.Errorf("%s panicked decompressing %q: %v", algorithm, input, err)
+			}
+		}
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	content := bytes.Repeat([]byte(roundTripText), 40)
+	for _, algorithm := range []string{"lzss", "huffman", "arithmetic", "mcc", "flate", "gzip", "zlib", "lzw"} {
+		compressed := compress(content, []string{algorithm})
+		if _, err := decompressLimit(context.Background(), compressed, []string{algorithm}, 1000); err == nil {
+			t.Errorf("%s decompressed %d bytes past a limit of 1000", algorithm, len(content))
+		}
+		out, err := decompressLimit(context.Background(), compressed, []string{algorithm}, len(content))
+		if err != nil || !bytes.Equal(out, content) {
+			t.Errorf("%s failed to decompress with a limit of exactly its size: %v", algorithm, err)
+		}
+	}
+
+	_, err := decompressLimit(context.Background(), compress(content, []string{"flate"}), []string{"flate"}, 1000)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected flate to fail with ErrTooLarge, got %v", err)
+	}
+	_, err = decompressLimit(context.Background(), compress(content, []string{"lzss"}), []string{"lzss"}, 1000)
+	if !errors.Is(err, lz.ErrTooLarge) {
+		t.Errorf("Expected lzss to fail with lz.ErrTooLarge, got %v", err)
+	}
+}
+
+func TestUnpackLimit(t *testing.T) {
+	content := make([]byte, 1<<20)
+	packed := Pack(content, []string{"flate"}, ChecksumCRC32)
+	if _, _, err := UnpackLimit(packed, nil, 1<<10); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected a container over the limit to fail with ErrTooLarge, got %v", err)
+	}
+	if unpacked, _, err := UnpackLimit(packed, nil, 1<<20); err != nil || !bytes.Equal(unpacked, content) {
+		t.Errorf("Failed to unpack a container at the limit: %v", err)
+	}
+
+	// Without a container there is no recorded size so the decoder itself has to stop
+	bomb := compress(content, []string{"flate"})
+	if _, _, err := UnpackLimit(bomb, []string{"flate"}, 1<<10); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected a raw stream over the limit to fail with ErrTooLarge, got %v", err)
+	}
+}
+
+// FuzzDecompress checks that no algorithm panics or exceeds its limit on arbitrary input, picking the algorithm with choice.
+func FuzzDecompress(f *testing.F) {
+	algorithms := sortedWriters()
+	for _, content := range edgeCases() {
+		for choice, algorithm := range algorithms {
+			seed := content
+			if !knownLossy(algorithm) {
+				seed = compress(content, []string{algorithm})
+			}
+			f.Add(seed, byte(choice))
+		}
+	}
+	const limit = 1 << 16
+	f.Fuzz(func(t *testing.T, content []byte, choice byte) {
+		algorithm := algorithms[int(choice)%len(algorithms)]
+		out, panicked, err := decompressNoPanic(content, algorithm, limit)
+		if panicked {
+			t.Fatalf("%s panicked: %v", algorithm, err)
+		}
+		if len(out) > limit {
+			t.Fatalf("%s decompressed %d bytes past the limit of %d", algorithm, len(out), limit)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"sort"
+)
+
+// ParetoFront takes the results for a single file and returns the lossless results that no other result beats on both
+// compression ratio and compression speed, sorted from the fastest to the smallest output.
+func ParetoFront(results []Result) []Result {
+	front := make([]Result, 0)
+	for i, result := range results {
+		if result.Failed || !result.Lossless {
+			continue
+		}
+		dominated := false
+		for j, other := range results {
+			if i == j || other.Failed || !other.Lossless {
+				continue
+			}
+			if other.Ratio <= result.Ratio && other.CompressSpeed >= result.CompressSpeed &&
+				(other.Ratio < result.Ratio || other.CompressSpeed > result.CompressSpeed) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			front = append(front, result)
+		}
+	}
+	sort.Slice(front, func(i, j int) bool {
+		return front[i].CompressSpeed > front[j].CompressSpeed
+	})
+	return front
+}
+
+const (
+	chartWidth  = 640
+	chartHeight = 360
+	chartLeft   = 60
+	chartRight  = 20
+	chartTop    = 20
+	chartBottom = 45
+)
+
+// scatterChart returns an inline svg plotting the compression ratio of every successful result against its compression speed.
+// Speed uses a log scale since the algorithms differ by orders of magnitude, the Pareto front is highlighted and joined by a line.
+func scatterChart(results []Result, front []Result) template.HTML {
+	points := make([]Result, 0, len(results))
+	for _, result := range results {
+		if !result.Failed && result.CompressSpeed > 0 {
+			points = append(points, result)
+		}
+	}
+	if len(points) == 0 {
+		return ""
+	}
+
+	minSpeed, maxSpeed := math.Inf(1), math.Inf(-1)
+	maxRatio := 0.0
+	for _, result := range points {
+		minSpeed = math.Min(minSpeed, result.CompressSpeed)
+		maxSpeed = math.Max(maxSpeed, result.CompressSpeed)
+		maxRatio = math.Max(maxRatio, float64(result.Ratio))
+	}
+	// Pad the axes so that no point sits on the edge of the chart
+	minLog, maxLog := math.Log10(minSpeed)-0.2, math.Log10(maxSpeed)+0.2
+	maxRatio = math.Max(maxRatio*1.1, 1)
+
+	plotWidth := float64(chartWidth - chartLeft - chartRight)
+	plotHeight := float64(chartHeight - chartTop - chartBottom)
+	x := func(speed float64) float64 {
+		return chartLeft + (math.Log10(speed)-minLog)/(maxLog-minLog)*plotWidth
+	}
+	y := func(ratio float64) float64 {
+		return chartTop + plotHeight - ratio/maxRatio*plotHeight
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg class="chart" xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`,
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.0f" height="%.0f" fill="none" stroke="#d1d5da"/>`, chartLeft, chartTop, plotWidth, plotHeight)
+
+	for exponent := math.Ceil(minLog); exponent <= maxLog; exponent++ {
+		tickX := x(math.Pow(10, exponent))
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%.0f" stroke="#eaecef"/>`, tickX, chartTop, tickX, chartTop+plotHeight)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.0f" text-anchor="middle">%g</text>`, tickX, chartTop+plotHeight+15, math.Pow(10, exponent))
+	}
+	for i := 0; i <= 4; i++ {
+		ratio := maxRatio * float64(i) / 4
+		tickY := y(ratio)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%.0f" y2="%.1f" stroke="#eaecef"/>`, chartLeft, tickY, chartLeft+plotWidth, tickY)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%.0f%%</text>`, chartLeft-5, tickY, ratio)
+	}
+	fmt.Fprintf(&b, `<text x="%.0f" y="%d" text-anchor="middle">compression speed (MB/s, log scale)</text>`, chartLeft+plotWidth/2, chartHeight-8)
+	fmt.Fprintf(&b, `<text transform="translate(14 %.0f) rotate(-90)" text-anchor="middle">compression ratio (lower is better)</text>`, chartTop+plotHeight/2)
+
+	if len(front) > 1 {
+		b.WriteString(`<polyline fill="none" stroke="#d73a49" stroke-dasharray="4 3" points="`)
+		for _, result := range front {
+			fmt.Fprintf(&b, "%.1f,%.1f ", x(result.CompressSpeed), y(float64(result.Ratio)))
+		}
+		b.WriteString(`"/>`)
+	}
+
+	onFront := make(map[string]bool, len(front))
+	for _, result := range front {
+		onFront[result.CompressionEngine] = true
+	}
+	for _, result := range points {
+		color := "#0366d6"
+		if onFront[result.CompressionEngine] {
+			color = "#d73a49"
+		} else if !result.Lossless {
+			color = "#959da5"
+		}
+		pointX, pointY := x(result.CompressSpeed), y(float64(result.Ratio))
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="4" fill="%s"><title>%s: %.2f%%, %.2f MB/s</title></circle>`,
+			pointX, pointY, color, template.HTMLEscapeString(result.CompressionEngine), result.Ratio, result.CompressSpeed)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" fill="%s">%s</text>`, pointX+6, pointY-6, color, template.HTMLEscapeString(result.CompressionEngine))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// resultsTable returns a table of the results that can be sorted by clicking its headers,
+// every numeric cell carries its raw value in data-value for the sorting script in the report template.
+func resultsTable(results []Result, front []Result) template.HTML {
+	onFront := make(map[string]bool, len(front))
+	for _, result := range front {
+		onFront[result.CompressionEngine] = true
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`<table class="sortable"><thead><tr>`)
+	for _, header := range []string{"engine", "time taken", "ratio", "compress", "decompress", "compress (median)", "decompress (median)", "peak heap (c/d)", "lossless", "pareto"} {
+		fmt.Fprintf(&b, "<th>%s</th>", header)
+	}
+	b.WriteString("</tr></thead><tbody>")
+	for _, result := range results {
+		b.WriteString("<tr>")
+		fmt.Fprintf(&b, "<td>%s</td><td>%s</td>", template.HTMLEscapeString(result.CompressionEngine), template.HTMLEscapeString(result.TimeTaken))
+		if result.Failed {
+			b.WriteString(`<td data-value="Infinity">DNF</td><td data-value="0">DNF</td><td data-value="0">DNF</td>` +
+				`<td data-value="Infinity">DNF</td><td data-value="Infinity">DNF</td><td data-value="Infinity">DNF</td>`)
+		} else {
+			fmt.Fprintf(&b, `<td data-value="%f">%.2f%%</td>`, result.Ratio, result.Ratio)
+			fmt.Fprintf(&b, `<td data-value="%f">%.2f MB/s</td>`, result.CompressSpeed, result.CompressSpeed)
+			fmt.Fprintf(&b, `<td data-value="%f">%.2f MB/s</td>`, result.DecompressSpeed, result.DecompressSpeed)
+			fmt.Fprintf(&b, `<td data-value="%d">%s</td>`, result.CompressTime, result.CompressStats)
+			fmt.Fprintf(&b, `<td data-value="%d">%s</td>`, result.DecompressTime, result.DecompressStats)
+			fmt.Fprintf(&b, `<td data-value="%d">%s</td>`, result.CompressMemory.PeakHeapBytes, template.HTMLEscapeString(memoryColumn(result)))
+		}
+		fmt.Fprintf(&b, "<td>%t</td><td>%t</td>", result.Lossless, onFront[result.CompressionEngine])
+		b.WriteString("</tr>")
+	}
+	b.WriteString("</tbody></table>")
+	return template.HTML(b.String())
+}
+
+// htmlSection returns the part of the html report for a single file: a heading, the ratio against speed chart,
+// the Pareto front and a sortable table of every result.
+func htmlSection(fileString string, fileSize int64, results []Result) template.HTML {
+	front := ParetoFront(results)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<h2>%s</h2><p>Size: %s</p>", template.HTMLEscapeString(fileString), ByteCountSI(fileSize))
+	b.WriteString(string(scatterChart(results, front)))
+	if len(front) > 0 {
+		b.WriteString("<p>Pareto front (no other algorithm is both smaller and faster to compress): ")
+		for i, result := range front {
+			if i > 0 {
+				b.WriteString(" → ")
+			}
+			fmt.Fprintf(&b, "<strong>%s</strong> (%.2f%%, %.2f MB/s)", template.HTMLEscapeString(result.CompressionEngine), result.Ratio, result.CompressSpeed)
+		}
+		b.WriteString("</p>")
+	}
+	b.WriteString(string(resultsTable(results, front)))
+	return template.HTML(b.String())
+}
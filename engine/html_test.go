@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var paretoResults = []Result{
+	{CompressionEngine: "fast", Ratio: 60, CompressSpeed: 100, Lossless: true},
+	{CompressionEngine: "small", Ratio: 30, CompressSpeed: 1, Lossless: true},
+	{CompressionEngine: "balanced", Ratio: 40, CompressSpeed: 20, Lossless: true},
+	{CompressionEngine: "dominated", Ratio: 50, CompressSpeed: 10, Lossless: true},
+	{CompressionEngine: "lossy", Ratio: 10, CompressSpeed: 1000, Lossless: false},
+	{CompressionEngine: "failed", Failed: true},
+}
+
+func TestParetoFront(t *testing.T) {
+	var engines []string
+	for _, result := range ParetoFront(paretoResults) {
+		engines = append(engines, result.CompressionEngine)
+	}
+	expected := []string{"fast", "balanced", "small"}
+	if !reflect.DeepEqual(engines, expected) {
+		t.Errorf("Expected %v, got %v", expected, engines)
+	}
+}
+
+func TestScatterChart(t *testing.T) {
+	chart := string(scatterChart(paretoResults, ParetoFront(paretoResults)))
+	decoder := xml.NewDecoder(strings.NewReader(chart))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Chart is not valid svg: %s", err)
+		}
+	}
+	if strings.Count(chart, "<circle") != 5 {
+		t.Errorf("Expected a point for every result that didn't fail, got %d", strings.Count(chart, "<circle"))
+	}
+}
@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	arithmetic "github.com/go-compression/raisin/compressor/arithmetic"
+	huffman "github.com/go-compression/raisin/compressor/huffman"
+	lz "github.com/go-compression/raisin/compressor/lz"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Inspectors represents a map of algorithm names to functions summarizing the headers of their compressed output.
+var Inspectors = map[string]func(compressed []byte) (map[string]interface{}, error){
+	"huffman": func(compressed []byte) (map[string]interface{}, error) {
+		freqs, codes, tableSize, err := huffman.CodeTable(compressed)
+		if err != nil {
+			return nil, err
+		}
+		shortest, longest := 0, 0
+		for _, code := range codes {
+			if shortest == 0 || len(code) < shortest {
+				shortest = len(code)
+			}
+			if len(code) > longest {
+				longest = len(code)
+			}
+		}
+		return map[string]interface{}{
+			"symbols":            len(freqs),
+			"table_bytes":        tableSize,
+			"shortest_code_bits": shortest,
+			"longest_code_bits":  longest,
+		}, nil
+	},
+	"arithmetic": func(compressed []byte) (map[string]interface{}, error) {
+		precision, maxFrequency, symbols := arithmetic.ModelConfig(compressed)
+		return map[string]interface{}{
+			"precision_bits": precision,
+			"max_frequency":  maxFrequency,
+			"symbols":        symbols,
+			"model":          "adaptive order-0",
+		}, nil
+	},
+	"lzss": func(compressed []byte) (map[string]interface{}, error) {
+		references, literals, maxPointer := lz.Stats(compressed)
+		return map[string]interface{}{
+			"references":    references,
+			"literal_bytes": literals,
+			"max_pointer":   maxPointer,
+		}, nil
+	},
+}
+
+// LayerInfo represents a single layer of the algorithm chain of a compressed file.
+type LayerInfo struct {
+	Algorithm string                 `json:"algorithm"`
+	Size      int64                  `json:"size"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// Info represents everything that can be learned about a compressed file by inspecting it.
+type Info struct {
+	Path           string      `json:"path"`
+	Container      bool        `json:"container"`
+	Version        int         `json:"version,omitempty"`
+	Algorithms     []string    `json:"algorithms"`
+	Layers         []LayerInfo `json:"layers"`
+	OriginalSize   int64       `json:"original_size"`
+	CompressedSize int64       `json:"compressed_size"`
+	Ratio          float32     `json:"ratio"`
+	Checksum       string      `json:"checksum"`
+	ChecksumStatus string      `json:"checksum_status"`
+	Dictionary     string      `json:"dictionary,omitempty"`
+}
+
+// InspectFile takes a set of compression algorithms and a path to a compressed file and returns an Info object describing it.
+// The algorithms are only used when the file doesn't record its own algorithm chain, and the dictionaries only when it was compressed with one.
+func InspectFile(algorithms []string, path string, dictionaries ...[]byte) (Info, error) {
+	fileContents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Info{}, err
+	}
+	info, err := Inspect(fileContents, algorithms, dictionaries...)
+	info.Path = path
+	return info, err
+}
+
+// Inspect takes the contents of a compressed file and a set of fallback algorithms and returns an Info object describing it.
+// Every layer is decompressed in turn so its size can be measured and the checksum verified, which for a container compressed
+// with a preset dictionary needs that dictionary to be among dictionaries.
+func Inspect(contents []byte, algorithms []string, dictionaries ...[]byte) (Info, error) {
+	info := Info{CompressedSize: int64(len(contents)), Checksum: ChecksumNone.String()}
+
+	header, payload, err := ReadHeader(contents)
+	if err == nil {
+		info.Container = true
+		info.Version = header.Version
+		info.Checksum = header.Checksum.String()
+		if len(header.Algorithms) > 0 {
+			algorithms = header.Algorithms
+		}
+		if header.DictionaryID != 0 {
+			info.Dictionary = fmt.Sprintf("%08x", header.DictionaryID)
+		}
+	} else if err != ErrNoContainer {
+		return info, err
+	}
+	info.Algorithms = algorithms
+
+	info.Layers = make([]LayerInfo, len(algorithms))
+	content := payload
+	failed := false
+	var dict []byte
+	var dictErr error
+	if header.DictionaryID != 0 {
+		dict, dictErr = findDictionary(header.DictionaryID, dictionaries)
+	}
+	for i := len(algorithms) - 1; i >= 0; i-- {
+		layer := LayerInfo{Algorithm: algorithms[i], Size: int64(len(content))}
+		if inspector, ok := Inspectors[algorithms[i]]; ok {
+			details, err := inspector(content)
+			if err != nil {
+				layer.Error = err.Error()
+			}
+			layer.Details = details
+		}
+
+		decompressed, err := safeDecompress(content, algorithms[i:i+1], DefaultMaxDecompressedSize, dict, header.Version <= 1)
+		if dictErr != nil && SupportsDictionary(algorithms[i]) {
+			err = dictErr
+		}
+		if err != nil {
+			layer.Error = err.Error()
+			info.Layers[i] = layer
+			failed = true
+			break
+		}
+		info.Layers[i] = layer
+		content = decompressed
+	}
+
+	for i, layer := range info.Layers {
+		if layer.Algorithm == "" {
+			info.Layers[i] = LayerInfo{Algorithm: algorithms[i], Size: -1, Error: "not reached"}
+		}
+	}
+
+	if failed {
+		info.ChecksumStatus = "decompression failed"
+		info.OriginalSize = header.OriginalSize
+	} else if !info.Container {
+		info.ChecksumStatus = "unverified"
+		info.OriginalSize = int64(len(content))
+	} else if err := header.Verify(content); err != nil {
+		info.ChecksumStatus = "mismatch"
+		info.OriginalSize = header.OriginalSize
+	} else if header.Checksum == ChecksumNone {
+		info.ChecksumStatus = "unverified"
+		info.OriginalSize = header.OriginalSize
+	} else {
+		info.ChecksumStatus = "ok"
+		info.OriginalSize = header.OriginalSize
+	}
+
+	if info.OriginalSize > 0 {
+		info.Ratio = float32(info.CompressedSize) / float32(info.OriginalSize) * 100
+	}
+	return info, nil
+}
+
+// WriteInfo writes out an Info object in the given format, either "text" or "json".
+func WriteInfo(w io.Writer, info Info, format string) error {
+	switch format {
+	case "json":
+		encoded, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(encoded))
+		return err
+	case "text":
+		fmt.Fprintf(w, "File: %s\n", info.Path)
+		if info.Container {
+			fmt.Fprintf(w, "Container version: %d\n", info.Version)
+		} else {
+			fmt.Fprintf(w, "Container: none (raw compressed data)\n")
+		}
+		fmt.Fprintf(w, "Algorithms: %s\n", strings.Join(info.Algorithms, ","))
+		fmt.Fprintf(w, "Original bytes: %v\n", info.OriginalSize)
+		fmt.Fprintf(w, "Compressed bytes: %v\n", info.CompressedSize)
+		fmt.Fprintf(w, "Compression ratio: %.2f%%\n", info.Ratio)
+		fmt.Fprintf(w, "Checksum: %s (%s)\n", info.Checksum, info.ChecksumStatus)
+		if info.Dictionary != "" {
+			fmt.Fprintf(w, "Dictionary: %s\n", info.Dictionary)
+		}
+		for i, layer := range info.Layers {
+			if layer.Size < 0 {
+				fmt.Fprintf(w, "Layer %d: %s, %s\n", i+1, layer.Algorithm, layer.Error)
+				continue
+			}
+			fmt.Fprintf(w, "Layer %d: %s, %v bytes\n", i+1, layer.Algorithm, layer.Size)
+			keys := make([]string, 0, len(layer.Details))
+			for key := range layer.Details {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Fprintf(w, "\t%s: %v\n", key, layer.Details[key])
+			}
+			if layer.Error != "" {
+				fmt.Fprintf(w, "\terror: %s\n", layer.Error)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("raisin: unknown info format: %s", format)
+}
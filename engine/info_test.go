@@ -0,0 +1,25 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	contents := []byte("I AM SAM. I AM SAM. SAM I AM.")
+	packed := Pack(contents, []string{"lzss", "arithmetic"}, ChecksumCRC32)
+
+	info, err := Inspect(packed, []string{"flate"})
+	if err != nil {
+		t.Fatalf("Inspect errored: %s", err)
+	}
+	if !reflect.DeepEqual(info.Algorithms, []string{"lzss", "arithmetic"}) {
+		t.Errorf("Got algorithms %v but wanted the chain stored in the container", info.Algorithms)
+	}
+	if info.ChecksumStatus != "ok" || info.OriginalSize != int64(len(contents)) {
+		t.Errorf("Got checksum status %s and original size %d", info.ChecksumStatus, info.OriginalSize)
+	}
+	if len(info.Layers) != 2 || info.Layers[1].Details["precision_bits"] == nil {
+		t.Errorf("Expected arithmetic layer details, got %+v", info.Layers)
+	}
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"runtime"
+	"time"
+)
+
+// MemoryStats represents the memory used by a single compression or decompression phase.
+// Measurements are taken from the Go runtime and include anything else allocating at the same time,
+// so they are only exact when a single algorithm is benchmarked per process.
+type MemoryStats struct {
+	PeakHeapBytes  uint64 `json:"peak_heap_bytes"`
+	AllocatedBytes uint64 `json:"allocated_bytes"`
+}
+
+// memorySampleInterval is how often the heap is sampled while looking for its peak.
+const memorySampleInterval = time.Millisecond
+
+// measureMemory runs f and returns the total bytes it allocated and the peak heap growth observed while it ran.
+func measureMemory(f func()) MemoryStats {
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	done := make(chan struct{})
+	peak := make(chan uint64)
+	go func() {
+		var stats runtime.MemStats
+		var max uint64
+		ticker := time.NewTicker(memorySampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				peak <- max
+				return
+			case <-ticker.C:
+				runtime.ReadMemStats(&stats)
+				if stats.HeapAlloc > max {
+					max = stats.HeapAlloc
+				}
+			}
+		}
+	}()
+
+	f()
+
+	close(done)
+	max := <-peak
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	if after.HeapAlloc > max {
+		max = after.HeapAlloc
+	}
+
+	stats := MemoryStats{AllocatedBytes: after.TotalAlloc - before.TotalAlloc}
+	if max > before.HeapAlloc {
+		stats.PeakHeapBytes = max - before.HeapAlloc
+	}
+	return stats
+}
+
+func (stats MemoryStats) String() string {
+	return ByteCountSI(int64(stats.PeakHeapBytes))
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Formats is a slice of strings representing the possible output formats of a benchmark.
+var Formats = [...]string{"table", "json", "csv", "markdown"}
+
+var resultColumns = []string{
+	"file", "engine", "original_bytes", "compressed_bytes", "ratio",
+	"compress_ns", "decompress_ns", "compress_mb_per_s", "decompress_mb_per_s",
+	"entropy", "actual_entropy", "lossless", "failed", "runs",
+	"compress_min_ns", "compress_p95_ns", "compress_stddev_ns",
+	"decompress_min_ns", "decompress_p95_ns", "decompress_stddev_ns",
+	"compress_peak_heap_bytes", "compress_alloc_bytes",
+	"decompress_peak_heap_bytes", "decompress_alloc_bytes", "peak_rss_bytes",
+}
+
+func resultRow(result Result) []string {
+	return []string{
+		result.File,
+		result.CompressionEngine,
+		strconv.FormatInt(result.OriginalBytes, 10),
+		strconv.FormatInt(result.CompressedBytes, 10),
+		strconv.FormatFloat(float64(result.Ratio), 'f', 4, 32),
+		strconv.FormatInt(result.CompressTime.Nanoseconds(), 10),
+		strconv.FormatInt(result.DecompressTime.Nanoseconds(), 10),
+		strconv.FormatFloat(result.CompressSpeed, 'f', 4, 64),
+		strconv.FormatFloat(result.DecompressSpeed, 'f', 4, 64),
+		strconv.FormatFloat(result.Entropy, 'f', 4, 64),
+		strconv.FormatFloat(float64(result.ActualEntropy), 'f', 4, 32),
+		strconv.FormatBool(result.Lossless),
+		strconv.FormatBool(result.Failed),
+		strconv.Itoa(result.CompressStats.Runs),
+		strconv.FormatInt(result.CompressStats.Min.Nanoseconds(), 10),
+		strconv.FormatInt(result.CompressStats.P95.Nanoseconds(), 10),
+		strconv.FormatInt(result.CompressStats.StdDev.Nanoseconds(), 10),
+		strconv.FormatInt(result.DecompressStats.Min.Nanoseconds(), 10),
+		strconv.FormatInt(result.DecompressStats.P95.Nanoseconds(), 10),
+		strconv.FormatInt(result.DecompressStats.StdDev.Nanoseconds(), 10),
+		strconv.FormatUint(result.CompressMemory.PeakHeapBytes, 10),
+		strconv.FormatUint(result.CompressMemory.AllocatedBytes, 10),
+		strconv.FormatUint(result.DecompressMemory.PeakHeapBytes, 10),
+		strconv.FormatUint(result.DecompressMemory.AllocatedBytes, 10),
+		strconv.FormatInt(result.PeakRSSBytes, 10),
+	}
+}
+
+// WriteResults writes benchmark results to w in a machine-readable format, one of "json", "csv" or "markdown".
+func WriteResults(w io.Writer, results []Result, format string) error {
+	switch format {
+	case "json":
+		encoded, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(encoded))
+		return err
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write(resultColumns)
+		for _, result := range results {
+			writer.Write(resultRow(result))
+		}
+		writer.Flush()
+		return writer.Error()
+	case "markdown":
+		fmt.Fprintf(w, "| %s |\n", strings.Join(resultColumns, " | "))
+		fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(resultColumns)))
+		for _, result := range results {
+			_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(resultRow(result), " | "))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("raisin: unknown benchmark format: %s", format)
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var reportResults = []Result{
+	{CompressionEngine: "flate", File: "test.txt", Ratio: 50, Lossless: true, OriginalBytes: 100, CompressedBytes: 50, CompressTime: time.Millisecond},
+	{CompressionEngine: "lzss,huffman", File: "test.txt", Ratio: 75, Lossless: true, OriginalBytes: 100, CompressedBytes: 75},
+}
+
+func TestWriteResultsJSON(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteResults(&b, reportResults, "json"); err != nil {
+		t.Fatalf("WriteResults errored: %s", err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatalf("Output is not valid json: %s", err)
+	}
+	if len(decoded) != 2 || decoded[0]["compress_ns"] != float64(time.Millisecond) {
+		t.Errorf("Got %v", decoded)
+	}
+}
+
+func TestWriteResultsCSV(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteResults(&b, reportResults, "csv"); err != nil {
+		t.Fatalf("WriteResults errored: %s", err)
+	}
+	records, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatalf("Output is not valid csv: %s", err)
+	}
+	if len(records) != 3 || records[2][1] != "lzss,huffman" || records[1][2] != "100" {
+		t.Errorf("Got %v", records)
+	}
+}
+
+func TestWriteResultsUnknownFormat(t *testing.T) {
+	if err := WriteResults(&bytes.Buffer{}, reportResults, "xml"); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// knownLossy returns whether algorithm is expected to lose information. dmc is experimental and never round trips.
+func knownLossy(algorithm string) bool {
+	return algorithm == "dmc"
+}
+
+// chainLossy returns whether any layer of algorithms is known to be lossy.
+func chainLossy(algorithms []string) bool {
+	for _, algorithm := range algorithms {
+		if knownLossy(algorithm) {
+			return true
+		}
+	}
+	return false
+}
+
+const roundTripText = "I AM SAM. I AM SAM. SAM I AM.\nTHAT SAM-I-AM! THAT SAM-I-AM! I DO NOT LIKE THAT SAM-I-AM!\n"
+
+func edgeCases() map[string][]byte {
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	return map[string][]byte{
+		"empty":        {},
+		"single byte":  {'a'},
+		"zero byte":    {0},
+		"all bytes":    all,
+		"repeated <":   bytes.Repeat([]byte("<"), 1000),
+		"repeated \\":  bytes.Repeat([]byte("\\"), 1000),
+		"escapes":      []byte("<\\<<\\\\<a<\\b\\<\\\\\\>><<<"),
+		"huffman meta": []byte("1|a|\\n\\\n2||3|\\\\\n"),
+		"text":         []byte(roundTripText),
+	}
+}
+
+func sortedWriters() []string {
+	algorithms := make([]string, 0, len(Writers))
+	for algorithm := range Writers {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	return algorithms
+}
+
+// sortedReaders is like sortedWriters but includes read-only algorithms.
+func sortedReaders() []string {
+	algorithms := make([]string, 0, len(Readers))
+	for algorithm := range Readers {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	return algorithms
+}
+
+// roundTrip compresses and decompresses content with algorithms, turning a panic into an error.
+func roundTrip(content []byte, algorithms []string) (out []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return decompress(compress(content, algorithms), algorithms), nil
+}
+
+func checkRoundTrip(t *testing.T, content []byte, algorithms []string) {
+	t.Helper()
+	if chainLossy(algorithms) {
+		return
+	}
+	out, err := roundTrip(content, algorithms)
+	if err != nil {
+		t.Fatalf("%v on %d bytes: %v", algorithms, len(content), err)
+	}
+	if !bytes.Equal(out, content) {
+		t.Fatalf("%v was not lossless on %d bytes, got %d bytes back", algorithms, len(content), len(out))
+	}
+}
+
+func TestRoundTripEdgeCases(t *testing.T) {
+	for _, algorithm := range sortedWriters() {
+		for name, content := range edgeCases() {
+			t.Run(algorithm+"/"+name, func(t *testing.T) {
+				checkRoundTrip(t, content, []string{algorithm})
+			})
+		}
+	}
+}
+
+// randomInput returns one of a few kinds of input that exercise different paths of the codecs.
+func randomInput(r *rand.Rand) []byte {
+	size := r.Intn(4096)
+	content := make([]byte, size)
+	switch r.Intn(4) {
+	case 0:
+		r.Read(content)
+	case 1:
+		alphabet := []byte("<\\ab\n|")
+		for i := range content {
+			content[i] = alphabet[r.Intn(len(alphabet))]
+		}
+	case 2:
+		for i := range content {
+			content[i] = roundTripText[r.Intn(len(roundTripText))]
+		}
+	case 3:
+		word := make([]byte, 1+r.Intn(16))
+		r.Read(word)
+		for i := range content {
+			content[i] = word[i%len(word)]
+		}
+	}
+	return content
+}
+
+func TestRoundTripRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	algorithms := sortedWriters()
+	for _, algorithm := range algorithms {
+		for i := 0; i < 10; i++ {
+			checkRoundTrip(t, randomInput(r), []string{algorithm})
+		}
+	}
+}
+
+func TestRoundTripRandomChains(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	algorithms := sortedWriters()
+	for i := 0; i < 40; i++ {
+		chain := make([]string, 2+r.Intn(2))
+		for j := range chain {
+			chain[j] = algorithms[r.Intn(len(algorithms))]
+		}
+		checkRoundTrip(t, randomInput(r), chain)
+	}
+}
+
+// maxFuzzSize keeps fuzz inputs small, lzss takes around a second on a few kilobytes of a repeated pattern.
+const maxFuzzSize = 1024
+
+// FuzzRoundTrip checks that a chain of up to two registered algorithms is lossless. first picks the first layer,
+// second picks the layer after it or, when it's 0 modulo one more than the number of algorithms, leaves the chain at one layer.
+func FuzzRoundTrip(f *testing.F) {
+	for _, content := range edgeCases() {
+		for choice := 0; choice < len(Writers); choice++ {
+			f.Add(content, byte(choice), byte(choice))
+		}
+	}
+	algorithms := sortedWriters()
+	f.Fuzz(func(t *testing.T, content []byte, first byte, second byte) {
+		if len(content) > maxFuzzSize {
+			return
+		}
+		chain := []string{algorithms[int(first)%len(algorithms)]}
+		if next := int(second) % (len(algorithms) + 1); next > 0 {
+			chain = append(chain, algorithms[next-1])
+		}
+		checkRoundTrip(t, content, chain)
+	})
+}
@@ -0,0 +1,328 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TrainSettings represents an object that can be used to modify the settings when training dictionaries with TrainDictionary
+// Size is the most bytes the dictionary may take up, SegmentSize the length of the pieces of samples it's built from
+// and DmerSize the length of the substrings (at most 8) whose frequencies decide which pieces are picked.
+// HoldOut is the percentage of samples to keep back from training with SplitSamples and measure the dictionary on.
+type TrainSettings struct {
+	Size        int
+	SegmentSize int
+	DmerSize    int
+	HoldOut     int
+}
+
+// NewTrainSettings returns the default settings for TrainDictionary as a TrainSettings object
+func NewTrainSettings() TrainSettings {
+	s := TrainSettings{}
+	s.Size = 16 * 1024
+	s.SegmentSize = 128
+	s.DmerSize = 6
+	s.HoldOut = 10
+	return s
+}
+
+// MaxDmerSize is the longest substring TrainDictionary counts, since substrings are packed into an integer.
+const MaxDmerSize = 8
+
+// ErrNoSamples is returned when training a dictionary without any samples long enough to learn from.
+var ErrNoSamples = errors.New("raisin: no samples to train a dictionary on")
+
+// TrainDictionary builds a dictionary out of the segments of samples that hold the most common substrings, the way zstd's COVER does.
+// Every substring of DmerSize bytes (a dmer) is scored by how many samples contain it. The samples are split into epochs and each
+// epoch gives up the segment whose distinct dmers score highest, after which those dmers score nothing so the next segments cover
+// something new. This repeats over the epochs until the dictionary is full or nothing scores.
+// The first segments picked are placed at the end of the dictionary, closest to the data, where coders with a small window like lzss still see them.
+func TrainDictionary(samples [][]byte, settings TrainSettings) ([]byte, error) {
+	d := settings.DmerSize
+	if d < 1 || d > MaxDmerSize {
+		return nil, fmt.Errorf("raisin: dmer size must be between 1 and %d", MaxDmerSize)
+	}
+	k := settings.SegmentSize
+	if k < d {
+		return nil, errors.New("raisin: segment size must be at least the dmer size")
+	}
+	if settings.Size <= 0 {
+		return nil, errors.New("raisin: dictionary size must be positive")
+	}
+
+	t := newCoverTrainer(samples, d)
+	if len(t.freqs) == 0 {
+		return nil, ErrNoSamples
+	}
+
+	epochs := settings.Size / k
+	if epochs < 1 {
+		epochs = 1
+	}
+	epochSize := len(t.dmers) / epochs
+	if epochSize < k {
+		epochSize = k
+		epochs = (len(t.dmers) + k - 1) / k
+	}
+
+	dict := make([]byte, settings.Size)
+	tail := settings.Size
+	for tail > 0 {
+		picked := false
+		for epoch := 0; epoch < epochs && tail > 0; epoch++ {
+			begin := epoch * epochSize
+			end := begin + epochSize
+			if epoch == epochs-1 || end > len(t.dmers) {
+				end = len(t.dmers)
+			}
+			segment := t.bestSegment(begin, end, k)
+			if segment == nil {
+				continue
+			}
+			if len(segment) > tail {
+				segment = segment[len(segment)-tail:]
+			}
+			tail -= copy(dict[tail-len(segment):tail], segment)
+			picked = true
+		}
+		if !picked {
+			break
+		}
+	}
+	return dict[tail:], nil
+}
+
+// coverTrainer holds the samples joined together along with the dmer starting at every position and how many samples hold each dmer.
+type coverTrainer struct {
+	data  []byte
+	d     int
+	dmers []uint64
+	// valid is false for positions whose dmer would run into the next sample
+	valid []bool
+	freqs map[uint64]int
+}
+
+func newCoverTrainer(samples [][]byte, d int) *coverTrainer {
+	t := &coverTrainer{d: d, freqs: make(map[uint64]int)}
+	for _, sample := range samples {
+		t.data = append(t.data, sample...)
+	}
+	t.dmers = make([]uint64, len(t.data))
+	t.valid = make([]bool, len(t.data))
+
+	seen := make(map[uint64]bool)
+	pos := 0
+	for _, sample := range samples {
+		for key := range seen {
+			delete(seen, key)
+		}
+		for i := 0; i+d <= len(sample); i++ {
+			var dmer uint64
+			for _, b := range sample[i : i+d] {
+				dmer = dmer<<8 | uint64(b)
+			}
+			t.dmers[pos+i] = dmer
+			t.valid[pos+i] = true
+			if !seen[dmer] {
+				seen[dmer] = true
+				t.freqs[dmer]++
+			}
+		}
+		pos += len(sample)
+	}
+	return t
+}
+
+// bestSegment returns the k bytes starting between begin and end whose distinct dmers have the highest total frequency,
+// trimmed of dmers that score nothing at either end, and stops those dmers from scoring again. It returns nil if nothing scores.
+func (t *coverTrainer) bestSegment(begin, end, k int) []byte {
+	// A segment of k bytes starting at i holds the dmers starting from i to i+window-1
+	window := k - t.d + 1
+	active := make(map[uint64]int)
+	score, bestScore, bestBegin := 0, 0, 0
+	for i := begin; i < end; i++ {
+		if t.valid[i] {
+			if active[t.dmers[i]]++; active[t.dmers[i]] == 1 {
+				score += t.freqs[t.dmers[i]]
+			}
+		}
+		if out := i - window; out >= begin && t.valid[out] {
+			if active[t.dmers[out]]--; active[t.dmers[out]] == 0 {
+				score -= t.freqs[t.dmers[out]]
+				delete(active, t.dmers[out])
+			}
+		}
+		if score > bestScore {
+			bestScore, bestBegin = score, i-window+1
+			if bestBegin < begin {
+				bestBegin = begin
+			}
+		}
+	}
+	if bestScore == 0 {
+		return nil
+	}
+
+	last := bestBegin + window - 1
+	if last >= end {
+		last = end - 1
+	}
+	for bestBegin < last && !t.scores(bestBegin) {
+		bestBegin++
+	}
+	for last > bestBegin && !t.scores(last) {
+		last--
+	}
+	for i := bestBegin; i <= last; i++ {
+		if t.valid[i] {
+			t.freqs[t.dmers[i]] = 0
+		}
+	}
+	stop := last + t.d
+	if stop > len(t.data) {
+		stop = len(t.data)
+	}
+	return t.data[bestBegin:stop]
+}
+
+func (t *coverTrainer) scores(i int) bool {
+	return t.valid[i] && t.freqs[t.dmers[i]] > 0
+}
+
+// SampleFiles returns every regular file under each of paths in sorted order, paths that are files are returned as they are.
+func SampleFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// SplitSamples splits files into those to train a dictionary on and holdOut percent of them, spread evenly, to measure it on.
+// At least one file is held out whenever holdOut is positive and there are two or more files.
+func SplitSamples(files []string, holdOut int) (train []string, test []string) {
+	for i, file := range files {
+		if (i+1)*holdOut/100 > i*holdOut/100 {
+			test = append(test, file)
+		} else {
+			train = append(train, file)
+		}
+	}
+	if holdOut > 0 && len(test) == 0 && len(train) > 1 {
+		test, train = train[len(train)-1:], train[:len(train)-1]
+	}
+	return train, test
+}
+
+// TrainFiles reads the files in paths and trains a dictionary on them with TrainDictionary.
+func TrainFiles(paths []string, settings TrainSettings) ([]byte, error) {
+	var samples [][]byte
+	for _, path := range paths {
+		sample, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	return TrainDictionary(samples, settings)
+}
+
+// DictionaryResult represents how well an algorithm chain compressed a set of files with and without a dictionary.
+type DictionaryResult struct {
+	Algorithms         string `json:"algorithms"`
+	Files              int    `json:"files"`
+	OriginalBytes      int64  `json:"original_bytes"`
+	WithoutBytes       int64  `json:"without_bytes"`
+	WithBytes          int64  `json:"with_bytes"`
+	Lossless           bool   `json:"lossless"`
+	SupportsDictionary bool   `json:"supports_dictionary"`
+}
+
+// Improvement returns how much smaller the dictionary made the files as a percentage of their size without it.
+func (r DictionaryResult) Improvement() float64 {
+	if r.WithoutBytes == 0 {
+		return 0
+	}
+	return (1 - float64(r.WithBytes)/float64(r.WithoutBytes)) * 100
+}
+
+// EvaluateDictionary benchmarks every algorithm chain on files with BenchmarkSuite, once without and once with dict,
+// and adds up the results of each chain over all of the files. settings.Dictionary is ignored.
+// Chains that fail on any file are left out, since their totals wouldn't be comparable.
+func EvaluateDictionary(files []string, algorithms [][]string, dict []byte, settings BenchmarkSettings) []DictionaryResult {
+	settings.Dictionary = nil
+	_, without := BenchmarkSuite(files, algorithms, settings)
+	settings.Dictionary = dict
+	_, with := BenchmarkSuite(files, algorithms, settings)
+
+	results := make([]DictionaryResult, len(algorithms))
+	totals := make(map[string]*DictionaryResult)
+	failed := make(map[string]bool)
+	for i, chain := range algorithms {
+		supported := false
+		for _, algorithm := range chain {
+			supported = supported || SupportsDictionary(algorithm)
+		}
+		results[i] = DictionaryResult{Algorithms: strings.Join(chain, ","), Lossless: true, SupportsDictionary: supported}
+		totals[results[i].Algorithms] = &results[i]
+	}
+	add := func(benchmarked []Result, withDict bool) {
+		for _, result := range benchmarked {
+			total, ok := totals[result.CompressionEngine]
+			if !ok || result.Failed {
+				failed[result.CompressionEngine] = true
+				continue
+			}
+			total.Lossless = total.Lossless && result.Lossless
+			if withDict {
+				total.WithBytes += result.CompressedBytes
+			} else {
+				total.Files++
+				total.OriginalBytes += result.OriginalBytes
+				total.WithoutBytes += result.CompressedBytes
+			}
+		}
+	}
+	add(without, false)
+	add(with, true)
+
+	var evaluated []DictionaryResult
+	for _, result := range results {
+		if !failed[result.Algorithms] {
+			evaluated = append(evaluated, result)
+		}
+	}
+	return evaluated
+}
+
+// WriteDictionaryResults writes results to w as a table of the total size of the files with and without the dictionary.
+func WriteDictionaryResults(w io.Writer, results []DictionaryResult) {
+	fmt.Fprintf(w, "%-24s %8s %12s %12s %12s %12s\n", "algorithms", "files", "original", "without", "with", "improvement")
+	for _, result := range results {
+		improvement := fmt.Sprintf("%.2f%%", result.Improvement())
+		if !result.SupportsDictionary {
+			improvement = "unused"
+		} else if !result.Lossless {
+			improvement = "not lossless"
+		}
+		fmt.Fprintf(w, "%-24s %8d %12d %12d %12d %12s\n", result.Algorithms, result.Files, result.OriginalBytes, result.WithoutBytes, result.WithBytes, improvement)
+	}
+}
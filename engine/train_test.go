@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// trainingSamples returns small JSON records that share their keys and most of their values, like the messages a dictionary is for
+func trainingSamples(n int) [][]byte {
+	r := rand.New(rand.NewSource(1))
+	roles := []string{"reader", "writer", "admin", "owner"}
+	var samples [][]byte
+	for i := 0; i < n; i++ {
+		id := r.Intn(100000)
+		samples = append(samples, []byte(fmt.Sprintf(`{"user_id": %d, "name": "user%d", "email": "user%d@example.com", "active": %t, "roles": ["%s"], "created_at": "2021-0%d-1%dT10:00:00Z"}`,
+			id, id, id, r.Intn(2) == 0, roles[r.Intn(len(roles))], 1+r.Intn(9), r.Intn(10))))
+	}
+	return samples
+}
+
+func TestTrainDictionary(t *testing.T) {
+	samples := trainingSamples(200)
+	settings := NewTrainSettings()
+	settings.Size = 1024
+	dict, err := TrainDictionary(samples[:190], settings)
+	if err != nil {
+		t.Fatalf("TrainDictionary errored: %s", err)
+	}
+	if len(dict) == 0 || len(dict) > settings.Size {
+		t.Fatalf("Got a dictionary of %d bytes for a size of %d", len(dict), settings.Size)
+	}
+	if !bytes.Contains(dict, []byte(`@example.com", "active": `)) {
+		t.Errorf("Expected the dictionary to hold the most common substring, got %q", dict)
+	}
+
+	for _, sample := range samples[190:] {
+		packed := PackDict(sample, []string{"flate"}, ChecksumNone, dict)
+		if plain := Pack(sample, []string{"flate"}, ChecksumNone); len(packed) >= len(plain) {
+			t.Errorf("Held-out sample took %d bytes with the dictionary and %d without", len(packed), len(plain))
+		}
+	}
+
+	again, _ := TrainDictionary(samples[:190], settings)
+	if !bytes.Equal(dict, again) {
+		t.Errorf("Expected training on the same samples to give the same dictionary")
+	}
+}
+
+func TestTrainDictionaryInvalid(t *testing.T) {
+	if _, err := TrainDictionary([][]byte{[]byte("abc")}, NewTrainSettings()); err != ErrNoSamples {
+		t.Errorf("Expected ErrNoSamples for samples shorter than a dmer, got %v", err)
+	}
+	settings := NewTrainSettings()
+	settings.DmerSize = MaxDmerSize + 1
+	if _, err := TrainDictionary(trainingSamples(10), settings); err == nil {
+		t.Errorf("Expected an error for a dmer size of %d", settings.DmerSize)
+	}
+	settings = NewTrainSettings()
+	settings.SegmentSize = settings.DmerSize - 1
+	if _, err := TrainDictionary(trainingSamples(10), settings); err == nil {
+		t.Errorf("Expected an error for a segment shorter than a dmer")
+	}
+}
+
+func TestSplitSamples(t *testing.T) {
+	var files []string
+	for i := 0; i < 20; i++ {
+		files = append(files, fmt.Sprintf("sample%02d", i))
+	}
+	train, test := SplitSamples(files, 10)
+	if !reflect.DeepEqual(test, []string{"sample09", "sample19"}) || len(train) != 18 {
+		t.Errorf("Got training samples %v and held-out samples %v", train, test)
+	}
+	train, test = SplitSamples(files[:2], 10)
+	if len(train) != 1 || len(test) != 1 {
+		t.Errorf("Expected one of two samples to be held out, got %v and %v", train, test)
+	}
+	if train, test = SplitSamples(files, 0); len(train) != 20 || len(test) != 0 {
+		t.Errorf("Expected nothing to be held out, got %v", test)
+	}
+}
+
+func TestEvaluateDictionary(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i, sample := range trainingSamples(60) {
+		path := filepath.Join(dir, fmt.Sprintf("sample%02d.json", i))
+		if err := ioutil.WriteFile(path, sample, 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	train, test := SplitSamples(paths, 10)
+	dict, err := TrainFiles(train, NewTrainSettings())
+	if err != nil {
+		t.Fatalf("TrainFiles errored: %s", err)
+	}
+
+	settings := NewBenchmarkSettings()
+	settings.Output = ioutil.Discard
+	settings.Format = "json"
+	settings.MeasureMemory = false
+	results := EvaluateDictionary(test, [][]string{{"lzss"}, {"zlib"}, {"huffman"}}, dict, settings)
+	if len(results) != 3 {
+		t.Fatalf("Expected results for 3 algorithms, got %+v", results)
+	}
+	for _, result := range results {
+		if result.Files != len(test) || !result.Lossless {
+			t.Errorf("Got %+v", result)
+		}
+		if result.SupportsDictionary != (result.Algorithms != "huffman") {
+			t.Errorf("%s reported dictionary support %t", result.Algorithms, result.SupportsDictionary)
+		}
+		if result.SupportsDictionary && result.Improvement() <= 20 {
+			t.Errorf("%s only improved by %.2f%% with the dictionary", result.Algorithms, result.Improvement())
+		}
+	}
+}
@@ -2,7 +2,8 @@ package engine
 
 import (
 	"fmt"
-	"sync"
+	"math"
+	"sort"
 	"time"
 )
 
@@ -12,20 +13,6 @@ func check(e error) {
 	}
 }
 
-func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
-	c := make(chan struct{})
-	go func() {
-		defer close(c)
-		wg.Wait()
-	}()
-	select {
-	case <-c:
-		return false // completed normally
-	case <-time.After(timeout):
-		return true // timed out
-	}
-}
-
 // ByteCountSI takes the number of bytes as an int64 and returns a human readable string in the largest significant units possible.
 func ByteCountSI(b int64) string {
 	const unit = 1000
@@ -40,3 +27,55 @@ func ByteCountSI(b int64) string {
 	return fmt.Sprintf("%.1f %cB",
 		float64(b)/float64(div), "kMGTPE"[exp])
 }
+
+// TimingStats represents summary statistics of the durations of repeated benchmark runs.
+type TimingStats struct {
+	Runs   int           `json:"runs"`
+	Min    time.Duration `json:"min_ns"`
+	Median time.Duration `json:"median_ns"`
+	P95    time.Duration `json:"p95_ns"`
+	Mean   time.Duration `json:"mean_ns"`
+	StdDev time.Duration `json:"stddev_ns"`
+}
+
+// NewTimingStats takes the durations of a set of runs and returns their summary statistics.
+// Percentiles use the nearest-rank method and the standard deviation is the population standard deviation.
+func NewTimingStats(durations []time.Duration) TimingStats {
+	stats := TimingStats{Runs: len(durations)}
+	if len(durations) == 0 {
+		return stats
+	}
+
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	stats.Min = sorted[0]
+	if len(sorted)%2 == 1 {
+		stats.Median = sorted[len(sorted)/2]
+	} else {
+		stats.Median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+	stats.P95 = sorted[int(math.Ceil(0.95*float64(len(sorted))))-1]
+
+	var sum float64
+	for _, duration := range sorted {
+		sum += float64(duration)
+	}
+	mean := sum / float64(len(sorted))
+	var variance float64
+	for _, duration := range sorted {
+		variance += (float64(duration) - mean) * (float64(duration) - mean)
+	}
+	stats.Mean = time.Duration(mean)
+	stats.StdDev = time.Duration(math.Sqrt(variance / float64(len(sorted))))
+	return stats
+}
+
+func (stats TimingStats) String() string {
+	median := stats.Median.Round(10 * time.Microsecond).String()
+	if stats.Runs <= 1 {
+		return median
+	}
+	return fmt.Sprintf("%s ±%s", median, stats.StdDev.Round(10*time.Microsecond).String())
+}
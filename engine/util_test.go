@@ -1,7 +1,9 @@
 package engine
 
 import (
+	"math"
 	"testing"
+	"time"
 )
 
 func TestByteCountSI(t *testing.T) {
@@ -15,3 +17,19 @@ func TestByteCountSI(t *testing.T) {
 		t.Errorf("Got %s but wanted 10 B", got)
 	}
 }
+
+func TestNewTimingStats(t *testing.T) {
+	durations := []time.Duration{5, 1, 4, 2, 3}
+	stats := NewTimingStats(durations)
+	if stats.Runs != 5 || stats.Min != 1 || stats.Median != 3 || stats.P95 != 5 || stats.Mean != 3 {
+		t.Errorf("Got %+v", stats)
+	}
+	if stats.StdDev != time.Duration(math.Sqrt(2)) {
+		t.Errorf("Got standard deviation %d but wanted %d", stats.StdDev, time.Duration(math.Sqrt(2)))
+	}
+
+	stats = NewTimingStats([]time.Duration{4, 2})
+	if stats.Median != 3 {
+		t.Errorf("Got median %d but wanted 3", stats.Median)
+	}
+}
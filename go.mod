@@ -1,6 +1,6 @@
 module github.com/go-compression/raisin
 
-go 1.15
+go 1.18
 
 require (
 	github.com/cheggaaa/pb/v3 v3.0.5
@@ -8,5 +8,14 @@ require (
 	github.com/icza/huffman v0.0.0-20200205092023-031fa85c57cf
 	github.com/jedib0t/go-pretty/v6 v6.0.4
 	github.com/kzahedi/goent v0.0.0-20190403094137-49773660fa36
+)
+
+require (
+	github.com/VividCortex/ewma v1.1.1 // indirect
+	github.com/fatih/color v1.7.0 // indirect
+	github.com/mattn/go-colorable v0.1.2 // indirect
+	github.com/mattn/go-isatty v0.0.12 // indirect
+	github.com/mattn/go-runewidth v0.0.9 // indirect
+	golang.org/x/sys v0.0.0-20200116001909-b77594299b42 // indirect
 	gonum.org/v1/gonum v0.8.0 // indirect
 )
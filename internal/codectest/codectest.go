@@ -0,0 +1,113 @@
+// Package codectest holds the inputs and checks shared by the codec tests, so that each codec's tests only spell out
+// the cases particular to its format.
+package codectest
+
+import (
+	"bytes"
+	"context"
+	codec "github.com/go-compression/raisin/compressor/codec"
+	"github.com/go-compression/raisin/corpus"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// Text is a paragraph of English that the test vectors written by the command line tools were compressed from.
+const Text = `It was the best of times, it was the worst of times, it was the age of wisdom, it was the age of foolishness,
+it was the epoch of belief, it was the epoch of incredulity, it was the season of Light, it was the season of Darkness,
+it was the spring of hope, it was the winter of despair, we had everything before us, we had nothing before us,
+we were all going direct to Heaven, we were all going direct the other way - in short, the period was so far like the
+present period, that some of its noisiest authorities insisted on its being received, for good or for evil, in the
+superlative degree of comparison only.
+`
+
+// Generate returns size bytes of the corpus class generated from seed 1, panicking on an unknown class.
+func Generate(class string, size int) []byte {
+	content, err := corpus.Generate(class, size, 1)
+	if err != nil {
+		panic(err)
+	}
+	return content
+}
+
+// Inputs returns the inputs every codec is tested on by name: no input, a single byte, and a run of zeros,
+// corpus text and random bytes that are each size bytes long.
+func Inputs(size int) map[string][]byte {
+	return map[string][]byte{
+		"empty":       {},
+		"single byte": {'a'},
+		"run":         make([]byte, size),
+		"text":        Generate("text", size),
+		"random":      Generate("random", size),
+	}
+}
+
+// Writer writes content to the writer returned by newWriter step bytes at a time, checks that reading it back through
+// newReader gives content and returns what was written.
+func Writer(t *testing.T, content []byte, step int, newWriter func(io.Writer) io.WriteCloser, newReader func(io.Reader) io.Reader) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := newWriter(&b)
+	for i := 0; i < len(content); i += step {
+		end := i + step
+		if end > len(content) {
+			end = len(content)
+		}
+		if _, err := w.Write(content[i:end]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	compressed := append([]byte{}, b.Bytes()...)
+	decompressed, err := ioutil.ReadAll(newReader(&b))
+	if err != nil || !bytes.Equal(decompressed, content) {
+		t.Errorf("Writer split into writes of %d bytes was not lossless: %v", step, err)
+	}
+	return compressed
+}
+
+// Limit checks that decompressLimit returns tooLarge with a limit one byte short of content and gives back content
+// with a limit of exactly its length.
+func Limit(t *testing.T, content, compressed []byte, tooLarge error, decompressLimit func([]byte, int) ([]byte, error)) {
+	t.Helper()
+	if _, err := decompressLimit(compressed, len(content)-1); err != tooLarge {
+		t.Errorf("Expected %v one byte under the limit, got %v", tooLarge, err)
+	}
+	decompressed, err := decompressLimit(compressed, len(content))
+	if err != nil || !bytes.Equal(decompressed, content) {
+		t.Errorf("Failed to decompress at exactly the limit: %v", err)
+	}
+}
+
+// ReaderLimit is like Limit for the reader returned by newReaderContext with the limit set in its options.
+func ReaderLimit(t *testing.T, content, compressed []byte, tooLarge error, newReaderContext func(context.Context, io.Reader, codec.Options) io.Reader) {
+	t.Helper()
+	Limit(t, content, compressed, tooLarge, func(compressed []byte, limit int) ([]byte, error) {
+		return ioutil.ReadAll(newReaderContext(context.Background(), bytes.NewReader(compressed), codec.Options{Limit: limit}))
+	})
+}
+
+// Corrupt checks that decompress returns want for every one of cases.
+func Corrupt(t *testing.T, cases map[string][]byte, want error, decompress func([]byte) ([]byte, error)) {
+	t.Helper()
+	for name, content := range cases {
+		if _, err := decompress(content); err != want {
+			t.Errorf("Decompressing %s returned %v, expected %v", name, err, want)
+		}
+	}
+}
+
+// Flips flips a bit of every step-th byte of compressed from start in turn and checks that decompress either fails
+// or still gives back content, so that no damage goes unnoticed and none of it panics.
+func Flips(t *testing.T, content, compressed []byte, start, step int, decompress func([]byte) ([]byte, error)) {
+	t.Helper()
+	for i := start; i < len(compressed); i += step {
+		corrupt := append([]byte{}, compressed...)
+		corrupt[i] ^= 1 << uint(i%8)
+		if decompressed, err := decompress(corrupt); err == nil && !bytes.Equal(decompressed, content) {
+			t.Errorf("Flipping a bit of byte %d went unnoticed", i)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// Package templates embeds the html templates used by raisin so the binary works outside of the repository.
+package templates
+
+import (
+	_ "embed" // Needed for go:embed
+)
+
+// Benchmark is the default template of the html benchmark report, it is executed with the report's Tables and Created time.
+//
+//go:embed benchmark.html
+var Benchmark string